@@ -213,6 +213,22 @@ func (f *fakeDb) GetGame(_ string) (tracker.GameTime, error) {
 	return tracker.GameTime{}, nil
 }
 
+func (f *fakeDb) RenameGame(_ string, _ string) error {
+	return nil
+}
+
+func (f *fakeDb) UpdateArcadeSet(_ tracker.ArcadeSet) error {
+	return nil
+}
+
+func (f *fakeDb) AddSession(_ tracker.Session) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeDb) UpdateSession(_ tracker.Session) error {
+	return nil
+}
+
 func (f *fakeDb) NoResults(_ error) bool {
 	return true
 }
@@ -295,6 +311,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = games.LoadUserSystems(config.UserSystemsFile)
+	if err != nil {
+		logger.Error("error loading user systems: %s", err)
+		fmt.Println("Error loading user systems:", err)
+	}
+
 	svc, err := service.NewService(service.ServiceArgs{
 		Name:   appName,
 		Logger: logger,
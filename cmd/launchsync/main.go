@@ -9,7 +9,7 @@ import (
 
 	"github.com/wizzomafizzo/mrext/pkg/config"
 	"github.com/wizzomafizzo/mrext/pkg/games"
-	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/index"
 	"github.com/wizzomafizzo/mrext/pkg/mister"
 )
 
@@ -19,7 +19,7 @@ import (
 
 const appName = "launchsync"
 
-func testSyncFile(cfg *config.UserConfig, path string) {
+func testSyncFile(cfg *config.UserConfig, idx index.Index, path string) {
 	sf, err := readSyncFile(path)
 	if err != nil {
 		fmt.Printf("Error reading %s: %s\n", path, err)
@@ -54,7 +54,7 @@ func testSyncFile(cfg *config.UserConfig, path string) {
 	}
 
 	fmt.Print("Building games index... ")
-	err = makeIndex(cfg, []syncFile{sf})
+	err = makeIndex(idx, []syncFile{sf})
 	if err != nil {
 		fmt.Printf("error generating index: %s\n", err)
 		os.Exit(1)
@@ -78,7 +78,7 @@ func testSyncFile(cfg *config.UserConfig, path string) {
 
 		for _, match := range game.matches {
 			fmt.Printf("- %s\n", match[4:])
-			results, err := gamesdb.SearchNamesRegexp([]games.System{*game.system}, match)
+			results, err := idx.SearchRegexp([]games.System{*game.system}, match)
 			if err != nil {
 				fmt.Printf("  error: %s\n", err)
 				continue
@@ -126,8 +126,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = games.LoadUserSystems(config.UserSystemsFile)
+	if err != nil {
+		fmt.Println("Error loading user systems:", err)
+	}
+
+	idx, err := index.New(cfg)
+	if err != nil {
+		fmt.Println("Error opening games index:", err)
+		os.Exit(1)
+	}
+
 	if *test != "" {
-		testSyncFile(cfg, *test)
+		testSyncFile(cfg, idx, *test)
 		return
 	}
 
@@ -173,7 +184,7 @@ func main() {
 	if *verbose || !*update {
 		fmt.Print("Building games index... ")
 	}
-	err = makeIndex(cfg, syncs)
+	err = makeIndex(idx, syncs)
 	if err != nil {
 		if *verbose || !*update {
 			fmt.Printf("error generating index: %s\n", err)
@@ -207,7 +218,7 @@ func main() {
 			if *verbose || !*update {
 				fmt.Print("- " + game.name + "... ")
 			}
-			file, found, err := tryLinkGame(cfg, sync, game)
+			file, found, err := tryLinkGame(cfg, idx, sync, game)
 			if *verbose || !*update {
 				if err != nil {
 					fmt.Printf("error: %s\n", err)
@@ -7,11 +7,13 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/wizzomafizzo/mrext/pkg/config"
 	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/index"
 	"github.com/wizzomafizzo/mrext/pkg/mister"
 
 	"gopkg.in/ini.v1"
@@ -214,7 +216,7 @@ func updateSyncFile(sync syncFile) (syncFile, bool, error) {
 	}
 }
 
-func makeIndex(cfg *config.UserConfig, syncs []syncFile) error {
+func makeIndex(idx index.Index, syncs []syncFile) error {
 	// restrict index to necessary systems
 	var systems []games.System
 	for _, sync := range syncs {
@@ -227,7 +229,7 @@ func makeIndex(cfg *config.UserConfig, syncs []syncFile) error {
 		return nil
 	}
 
-	_, err := gamesdb.NewNamesIndex(cfg, systems, func(status gamesdb.IndexStatus) {})
+	_, err := idx.Update(systems, func(status gamesdb.IndexStatus) {})
 	if err != nil {
 		return err
 	}
@@ -296,7 +298,7 @@ func notFoundFilename(folder string, game syncFileGame) string {
 	return filepath.Join(folder, game.folder, game.name+" [NOT FOUND].mgl")
 }
 
-func tryLinkGame(cfg *config.UserConfig, sync syncFile, game syncFileGame) (string, bool, error) {
+func tryLinkGame(cfg *config.UserConfig, idx index.Index, sync syncFile, game syncFileGame) (string, bool, error) {
 	var match gamesdb.SearchResult
 
 	for _, m := range game.matches {
@@ -312,13 +314,13 @@ func tryLinkGame(cfg *config.UserConfig, sync syncFile, game syncFileGame) (stri
 			if m[1:] == "" {
 				continue
 			}
-			results, err = gamesdb.SearchNamesRegexp([]games.System{*game.system}, "(?i)"+m[1:])
+			results, err = idx.SearchRegexp([]games.System{*game.system}, "(?i)"+m[1:])
 			if err != nil {
 				return "", false, err
 			}
 		} else {
 			// partial match
-			results, err = gamesdb.SearchNamesPartial([]games.System{*game.system}, m)
+			results, err = idx.SearchRegexp([]games.System{*game.system}, "(?i)"+regexp.QuoteMeta(m))
 			if err != nil {
 				return "", false, err
 			}
@@ -351,11 +353,14 @@ func tryLinkGame(cfg *config.UserConfig, sync syncFile, game syncFileGame) (stri
 	notFoundFn := notFoundFilename(sync.folder, game)
 
 	if match.Name != "" {
-		// found a match
-		// TODO: don't write if it's the same file
-		_, err := mister.CreateLauncher(cfg, game.system, match.Path, launcherFolder, game.name)
-		if err != nil {
-			return "", false, err
+		// found a match, existing launchers for the same game are kept so
+		// any edits to them aren't lost
+		mgl, err := mister.ReadMgl(launcherFn)
+		if err != nil || mister.ResolvePath(mgl.GameFile()) != match.Path {
+			_, err := mister.CreateLauncher(cfg, game.system, match.Path, launcherFolder, game.name)
+			if err != nil {
+				return "", false, err
+			}
 		}
 
 		_ = os.Remove(notFoundFn)
@@ -95,9 +95,21 @@ func main() {
 	testMdns := flag.Bool("test-mdns", false, "test mDNS service")
 	getUboot := flag.Bool("get-uboot", false, "get uboot params")
 	genDb := flag.Bool("generate-db", false, "generate database")
+	updateDb := flag.Bool("update-db", false, "update database with changed files only")
+	hashDb := flag.Bool("hash", false, "calculate file hashes when generating or updating database")
 	searchDb := flag.String("search-db", "", "search database")
+	findHash := flag.String("find-hash", "", "find games in database by crc32, md5 or sha1 hash")
+	importDats := flag.Bool("import-dats", false, "match games in database against dat files")
+	importMetadata := flag.Bool("import-metadata", false, "match games in database against metadata files")
+	importMedia := flag.Bool("import-media", false, "find box art and screenshots for games in database")
+	importArcade := flag.Bool("import-arcade", false, "read mra files in database and match them against the arcade db")
 	flag.Parse()
 
+	err := games.LoadUserSystems(config.UserSystemsFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading user systems:", err)
+	}
+
 	start := time.Now()
 
 	var selectedSystems []games.System
@@ -204,8 +216,19 @@ func main() {
 		for key, value := range params {
 			fmt.Printf("%s=%s\n", key, value)
 		}
-	} else if *genDb {
-		count, err := gamesdb.NewNamesIndex(&config.UserConfig{}, selectedSystems, func(status gamesdb.IndexStatus) {
+	} else if *genDb || *updateDb {
+		index := gamesdb.NewNamesIndex
+		if *updateDb {
+			index = gamesdb.UpdateNamesIndex
+		}
+
+		cfg := &config.UserConfig{}
+		cfg.GamesDb.HashFiles = *hashDb
+
+		var added, removed, unchanged, hashed int
+		count, err := index(cfg, selectedSystems, func(status gamesdb.IndexStatus) {
+			added, removed, unchanged = status.Added, status.Removed, status.Unchanged
+			hashed = status.Hashed
 			if status.Step == 1 {
 				fmt.Printf("searching games paths for %d systems\n", status.Total-2)
 			} else if status.Step == status.Total {
@@ -225,7 +248,58 @@ func main() {
 			os.Exit(1)
 		}
 
-		fmt.Printf("indexed %d games\n", count)
+		fmt.Printf(
+			"indexed %d games (%d added, %d removed, %d unchanged)\n",
+			count,
+			added,
+			removed,
+			unchanged,
+		)
+		if *hashDb {
+			fmt.Printf("hashed %d games\n", hashed)
+		}
+	} else if *importDats {
+		count, err := gamesdb.ImportDats(&config.UserConfig{}, selectedSystems)
+		if err != nil {
+			fmt.Printf("error importing dats: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("matched %d games\n", count)
+	} else if *importMetadata {
+		count, err := gamesdb.ImportMetadata(&config.UserConfig{}, selectedSystems)
+		if err != nil {
+			fmt.Printf("error importing metadata: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("matched %d games\n", count)
+	} else if *importMedia {
+		count, err := gamesdb.ImportMedia(&config.UserConfig{}, selectedSystems)
+		if err != nil {
+			fmt.Printf("error importing media: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("found media for %d games\n", count)
+	} else if *importArcade {
+		count, err := gamesdb.ImportArcade()
+		if err != nil {
+			fmt.Printf("error importing arcade info: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("read %d mra files\n", count)
+	} else if *findHash != "" {
+		files, err := gamesdb.FindByHash(selectedSystems, *findHash)
+		if err != nil {
+			fmt.Printf("error searching database: %s\n", err)
+			os.Exit(1)
+		}
+
+		for _, file := range files {
+			fmt.Printf("%s\n", file.Path)
+		}
 	} else if *searchDb != "" {
 		files, err := gamesdb.SearchNamesWords(selectedSystems, *searchDb)
 		if err != nil {
@@ -20,6 +20,7 @@ import (
 	"github.com/wizzomafizzo/mrext/pkg/input"
 
 	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
 	"github.com/wizzomafizzo/mrext/pkg/service"
 
 	"github.com/clausecker/nfc/v2"
@@ -31,7 +32,6 @@ import (
 // TODO: would it be possible to unlock the OSD with a card?
 // TODO: create a test web nfc reader in separate github repo, hosted on pages
 // TODO: use a tag to signal that that next tag should have the active game written to it
-// TODO: if it exists, use search.db instead of on demand index for random
 
 const (
 	appName              = "nfc"
@@ -770,6 +770,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = games.LoadUserSystems(config.UserSystemsFile)
+	if err != nil {
+		logger.Error("error loading user systems: %s", err)
+		fmt.Println("Error loading user systems:", err)
+	}
+
 	svc, err := service.NewService(service.ServiceArgs{
 		Name:   appName,
 		Logger: logger,
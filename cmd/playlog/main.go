@@ -4,11 +4,17 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/wizzomafizzo/mrext/pkg/tracker"
 
 	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
 	"github.com/wizzomafizzo/mrext/pkg/mister"
+	"github.com/wizzomafizzo/mrext/pkg/playlog"
 	"github.com/wizzomafizzo/mrext/pkg/service"
 	"github.com/wizzomafizzo/mrext/pkg/utils"
 )
@@ -21,16 +27,92 @@ import (
 
 const appName = "playlog"
 
+// How often to check if another process has stopped recording play times.
+const lockRetryInterval = 10 * time.Second
+
 func startService(logger *service.Logger, cfg *config.UserConfig) (func() error, error) {
-	db, err := openPlayLogDb()
+	db, err := playlog.Open(config.PlayLogDbFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remote records play times itself when PlayLog isn't running
+	locked, err := db.LockRecording()
 	if err != nil {
+		db.Close()
 		return nil, err
+	} else if locked {
+		stop, err := startTracker(logger, cfg, db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return stop, nil
 	}
 
+	// keep running and take over once Remote stops, so PlayLog ends up
+	// recording no matter which of them started first
+	logger.Info("play times are being recorded by another process, waiting for it to stop")
+
+	var mu sync.Mutex
+	var stopTracker func() error
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(lockRetryInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			locked, err := db.LockRecording()
+			if err != nil {
+				logger.Error("error locking playlog db: %s", err)
+				continue
+			} else if !locked {
+				continue
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			logger.Info("taking over recording play times")
+			stopTracker, err = startTracker(logger, cfg, db)
+			if err != nil {
+				logger.Error("error starting tracker: %s", err)
+			}
+
+			return
+		}
+	}()
+
+	return func() error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		close(done)
+		if stopTracker != nil {
+			return stopTracker()
+		}
+
+		return db.Close()
+	}, nil
+}
+
+func startTracker(logger *service.Logger, cfg *config.UserConfig, db *playlog.Db) (func() error, error) {
 	tr, err := tracker.NewTracker(logger, cfg, db)
 	if err != nil {
-		logger.Error("error starting tracker: %s", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("error creating tracker: %s", err)
 	}
 
 	tr.LoadCore()
@@ -43,8 +125,7 @@ func startService(logger *service.Logger, cfg *config.UserConfig) (func() error,
 
 	watcher, err := tracker.StartFileWatch(tr)
 	if err != nil {
-		tr.Logger.Error("error starting file watch: %s", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("error starting file watch: %s", err)
 	}
 
 	interval := 0
@@ -88,8 +169,66 @@ func tryAddStartup() error {
 	return nil
 }
 
+// Exports ending in .json are a single JSON file, anything else is a folder
+// of CSV files.
+func isJsonExport(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+func exportPlayLog(path string) error {
+	db, err := playlog.Open(config.PlayLogDbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	e, err := db.Export()
+	if err != nil {
+		return err
+	}
+
+	if isJsonExport(path) {
+		return playlog.WriteJson(path, e)
+	} else {
+		return playlog.WriteCsv(path, e)
+	}
+}
+
+func importPlayLog(path string) (playlog.ImportResult, error) {
+	var e playlog.Export
+	var err error
+
+	if isJsonExport(path) {
+		e, err = playlog.ReadJson(path)
+	} else {
+		e, err = playlog.ReadCsv(path)
+	}
+	if err != nil {
+		return playlog.ImportResult{}, err
+	}
+
+	db, err := playlog.Open(config.PlayLogDbFile)
+	if err != nil {
+		return playlog.ImportResult{}, err
+	}
+	defer db.Close()
+
+	// a running tracker would overwrite imported play times
+	locked, err := db.LockRecording()
+	if err != nil {
+		return playlog.ImportResult{}, err
+	} else if !locked {
+		return playlog.ImportResult{}, fmt.Errorf("play times are being recorded by another process, stop PlayLog and Remote first")
+	}
+
+	return db.Import(e)
+}
+
 func main() {
 	svcOpt := flag.String("service", "", "manage playlog service (start, stop, restart, status)")
+	reportOpt := flag.String("report", "", "show a session report (day, week, month, streaks, longest, arcade)")
+	exportOpt := flag.String("export", "", "export stats to a .json file or a folder of .csv files")
+	importOpt := flag.String("import", "", "merge stats from a .json file or a folder of .csv files")
 	flag.Parse()
 
 	logger := service.NewLogger(appName)
@@ -105,6 +244,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = games.LoadUserSystems(config.UserSystemsFile)
+	if err != nil {
+		logger.Error("error loading user systems: %s", err)
+		fmt.Println("Error loading user systems:", err)
+	}
+
 	svc, err := service.NewService(service.ServiceArgs{
 		Name:   appName,
 		Logger: logger,
@@ -118,6 +263,41 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *exportOpt != "" {
+		err := exportPlayLog(*exportOpt)
+		if err != nil {
+			logger.Error("error exporting stats: %s", err)
+			fmt.Println("Error exporting stats:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Exported stats to:", *exportOpt)
+		os.Exit(0)
+	}
+
+	if *importOpt != "" {
+		// the running tracker would overwrite imported play times
+		if svc.Running() {
+			fmt.Println("PlayLog must be stopped before importing. Run: playlog -service stop")
+			os.Exit(1)
+		}
+
+		result, err := importPlayLog(*importOpt)
+		if err != nil {
+			logger.Error("error importing stats: %s", err)
+			fmt.Println("Error importing stats:", err)
+			os.Exit(1)
+		}
+		fmt.Printf(
+			"Imported %d games, %d cores, %d arcade sets, %d new sessions and %d new events.\n",
+			result.Games,
+			result.Cores,
+			result.ArcadeSets,
+			result.Sessions,
+			result.Events,
+		)
+		os.Exit(0)
+	}
+
 	recents, err := mister.RecentsOptionEnabled()
 	if err != nil {
 		logger.Error("error checking recents option: %s", err)
@@ -145,14 +325,24 @@ func main() {
 		}
 	}
 
-	db, err := openPlayLogDb()
+	db, err := playlog.Open(config.PlayLogDbFile)
 	if err != nil {
 		logger.Error("error opening db: %s", err)
 		fmt.Println("Error opening database:", err)
 		os.Exit(1)
 	}
 
-	cores, err := db.topCores(10)
+	if *reportOpt != "" {
+		err := runReport(db, *reportOpt)
+		if err != nil {
+			logger.Error("error running report: %s", err)
+			fmt.Println("Error running report:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cores, err := db.TopCores(10)
 	if err != nil {
 		logger.Error("error getting top cores: %s", err)
 		fmt.Println("Error getting top cores:", err)
@@ -165,14 +355,14 @@ func main() {
 		}
 	}
 
-	games, err := db.topGames(10)
+	top, err := db.TopGames(10)
 	if err != nil {
 		logger.Error("error getting top games: %s", err)
 		fmt.Println("Error getting top games:", err)
 		os.Exit(1)
 	}
 	maxGameLen := 0
-	for _, game := range games {
+	for _, game := range top {
 		if len(game.Name) > maxGameLen {
 			maxGameLen = len(game.Name)
 		}
@@ -187,7 +377,7 @@ func main() {
 	}
 	fmt.Println()
 	fmt.Println("Top played games:")
-	for _, game := range games {
+	for _, game := range top {
 		hours := game.Time / 3600
 		minutes := (game.Time % 3600) / 60
 		fmt.Printf("%-*s  %dh %dm\n", maxGameLen, game.Name, hours, minutes)
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/playlog"
+	"github.com/wizzomafizzo/mrext/pkg/tracker"
+)
+
+const dateFormat = "2006-01-02"
+
+func formatDuration(seconds int) string {
+	hours := seconds / 3600
+	minutes := (seconds % 3600) / 60
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+func reportDays(db *playlog.Db, days int) error {
+	since := playlog.StartOfDay(time.Now()).AddDate(0, 0, -(days - 1))
+	sessions, err := db.SessionsBetween(since, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	periods := playlog.GroupSessions(sessions, playlog.StartOfDay)
+	if len(periods) == 0 {
+		fmt.Printf("No sessions in the last %d days.\n", days)
+		return nil
+	}
+
+	for i, p := range periods {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		fmt.Printf("%s (%s):\n", p.Start.Format("Monday "+dateFormat), formatDuration(p.Total))
+		for _, s := range p.Sessions {
+			fmt.Printf("  %s  %-8s  %s\n", s.Start.Local().Format("15:04"), formatDuration(s.Duration), playlog.SessionName(s))
+		}
+	}
+
+	return nil
+}
+
+func topName(p playlog.Period) string {
+	top := playlog.MostPlayed(p.Sessions, 1)
+	if len(top) == 0 {
+		return ""
+	}
+	return top[0].Name()
+}
+
+func reportPeriods(db *playlog.Db, label string, since time.Time, periodStart func(time.Time) time.Time, format string) error {
+	sessions, err := db.SessionsBetween(since, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	periods := playlog.GroupSessions(sessions, periodStart)
+	if len(periods) == 0 {
+		fmt.Printf("No sessions since %s.\n", since.Format(dateFormat))
+		return nil
+	}
+
+	fmt.Printf("Play time by %s:\n", label)
+	for _, p := range periods {
+		fmt.Printf(
+			"%-10s  %-8s  %3d sessions  %s\n",
+			p.Start.Format(format),
+			formatDuration(p.Total),
+			len(p.Sessions),
+			topName(p),
+		)
+	}
+
+	return nil
+}
+
+type streak struct {
+	Start time.Time
+	Days  int
+}
+
+// findStreaks returns the longest run of consecutive days with at least one
+// session, and the run which includes today or yesterday.
+func findStreaks(sessions []tracker.Session, now time.Time) (longest streak, current streak) {
+	var run streak
+
+	for _, p := range playlog.GroupSessions(sessions, playlog.StartOfDay) {
+		if run.Days > 0 && run.Start.AddDate(0, 0, run.Days).Equal(p.Start) {
+			run.Days++
+		} else {
+			run = streak{Start: p.Start, Days: 1}
+		}
+
+		if run.Days > longest.Days {
+			longest = run
+		}
+	}
+
+	if run.Days > 0 {
+		last := run.Start.AddDate(0, 0, run.Days-1)
+		if !last.Before(playlog.StartOfDay(now).AddDate(0, 0, -1)) {
+			current = run
+		}
+	}
+
+	return longest, current
+}
+
+func reportStreaks(db *playlog.Db) error {
+	sessions, err := db.SessionsBetween(time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	longest, current := findStreaks(sessions, time.Now())
+
+	fmt.Printf("Current streak: %d days\n", current.Days)
+	if longest.Days > 0 {
+		end := longest.Start.AddDate(0, 0, longest.Days-1)
+		fmt.Printf(
+			"Longest streak: %d days (%s to %s)\n",
+			longest.Days,
+			longest.Start.Format(dateFormat),
+			end.Format(dateFormat),
+		)
+	} else {
+		fmt.Println("Longest streak: 0 days")
+	}
+
+	return nil
+}
+
+func reportLongest(db *playlog.Db, n int) error {
+	sessions, err := db.LongestSessions(n)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Longest sessions:")
+	for _, s := range sessions {
+		fmt.Printf(
+			"%s  %-8s  %s\n",
+			s.Start.Local().Format(dateFormat+" 15:04"),
+			formatDuration(s.Duration),
+			playlog.SessionName(s),
+		)
+	}
+
+	return nil
+}
+
+func reportArcade(db *playlog.Db) error {
+	sets, err := db.ArcadeSetTimes()
+	if err != nil {
+		return err
+	}
+
+	groups := playlog.GroupArcadeSets(sets)
+	if len(groups) == 0 {
+		fmt.Println("No arcade games played.")
+		return nil
+	}
+
+	for i, g := range groups {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		fmt.Printf("%s [%s] (%s):\n", g.Name, g.Parent, formatDuration(g.Time))
+		for _, st := range g.Sets {
+			fmt.Printf("  %-8s  %s [%s]\n", formatDuration(st.Time), st.Name, st.SetName)
+		}
+	}
+
+	return nil
+}
+
+func runReport(db *playlog.Db, report string) error {
+	now := time.Now()
+
+	switch report {
+	case "day":
+		return reportDays(db, 7)
+	case "week":
+		since := playlog.StartOfWeek(now).AddDate(0, 0, -7*11)
+		return reportPeriods(db, "week", since, playlog.StartOfWeek, dateFormat)
+	case "month":
+		since := playlog.StartOfMonth(now).AddDate(0, -11, 0)
+		return reportPeriods(db, "month", since, playlog.StartOfMonth, "2006-01")
+	case "streaks":
+		return reportStreaks(db)
+	case "longest":
+		return reportLongest(db, 10)
+	case "arcade":
+		return reportArcade(db)
+	default:
+		return fmt.Errorf("unknown report: %s", report)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/wizzomafizzo/mrext/pkg/config"
 	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/index"
 	"github.com/wizzomafizzo/mrext/pkg/mister"
 	"github.com/wizzomafizzo/mrext/pkg/utils"
 	"os"
@@ -19,9 +21,12 @@ const (
 func main() {
 	// TODO: support an ini file for default values
 
-	filter := flag.String("filter", "", "list of systems to filter (ex. gba,psx,nes)")
-	ignore := flag.String("ignore", "", "list of systems to ignore (ex. tgfx16-cd)")
-	noscan := flag.Bool("noscan", false, "don't index entire system (faster, but less random)")
+	filter := flag.String("filter", "", "list of systems or groups to filter (ex. gba,psx,nes or @handheld)")
+	ignore := flag.String("ignore", "", "list of systems or groups to ignore (ex. tgfx16-cd or @computer)")
+	noscan := flag.Bool("noscan", false, "don't index entire system when there is no games index (faster, but less random)")
+	weighted := flag.Bool("weighted", false, "pick systems by number of games, requires a games index")
+	only := flag.String("only", "", "only pick games matching search filters (ex. vertical,no_bootleg), requires a games index")
+	players := flag.Int("players", 0, "only pick games for at least this many players, requires a games index")
 	flag.Parse()
 
 	cfg, err := config.LoadUserConfig(appName, &config.UserConfig{})
@@ -30,33 +35,30 @@ func main() {
 		os.Exit(1)
 	}
 
-	filteredIds := strings.Split(*filter, ",")
-	var filteredSystems []games.System
-	for _, id := range filteredIds {
-		system, _ := games.LookupSystem(id)
-		if system != nil {
-			filteredSystems = append(filteredSystems, *system)
-		}
-	}
-
-	ignoredIds := strings.Split(*ignore, ",")
-	var ignoredSystems []games.System
-	for _, id := range ignoredIds {
-		found, _ := games.LookupSystem(id)
-		if found != nil {
-			ignoredSystems = append(ignoredSystems, *found)
-		}
+	err = games.LoadUserSystems(config.UserSystemsFile)
+	if err != nil {
+		fmt.Println("Error loading user systems:", err)
 	}
 
 	systems := games.AllSystems()
 
 	// filter systems
-	if len(filteredSystems) > 0 {
-		systems = filteredSystems
+	if *filter != "" {
+		systems, err = games.LookupSystems(*filter)
+		if err != nil {
+			fmt.Println("Error in filter:", err)
+			os.Exit(1)
+		}
 	}
 
 	// ignore systems
-	if len(ignoredSystems) > 0 {
+	if *ignore != "" {
+		ignoredSystems, err := games.LookupSystems(*ignore)
+		if err != nil {
+			fmt.Println("Error in ignore list:", err)
+			os.Exit(1)
+		}
+
 		var filtered []games.System
 		for _, system := range systems {
 			ignore := false
@@ -73,6 +75,61 @@ func main() {
 		systems = filtered
 	}
 
+	filtered := *only != "" || *players > 0
+	var searchFilter gamesdb.SearchFilter
+	if filtered {
+		searchFilter, err = gamesdb.ParseSearchFilter(cfg.Search.Regions, strings.Split(*only, ","))
+		if err != nil {
+			fmt.Println("Error in filters:", err)
+			os.Exit(1)
+		}
+		searchFilter.Players = *players
+	}
+
+	// skip arcade games with missing ROMs
+	mras := mister.NewMRAValidator(cfg)
+
+	// use the games index if one has been generated, it's much faster
+	idx, err := index.New(cfg)
+	if err != nil {
+		fmt.Println("Error loading games index:", err)
+	} else if idx.Exists() {
+		weight := gamesdb.WeightSystems
+		if *weighted {
+			weight = gamesdb.WeightGames
+		}
+
+		var game gamesdb.SearchResult
+		if filtered {
+			game, err = index.PickFilteredGame(idx, systems, weight, searchFilter, mras.Launchable)
+		} else {
+			game, err = index.PickGame(idx, systems, weight, cfg.Search.Regions, mras.Launchable)
+		}
+
+		if err == nil {
+			system, err := games.GetSystem(game.SystemId)
+			if err != nil {
+				fmt.Println("Error launching game:", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Launching %s: %s\n", system.Id, game.Path)
+			err = mister.LaunchGame(cfg, *system, game.Path)
+			if err != nil {
+				fmt.Println(err)
+			}
+			return
+		}
+
+		fmt.Println("Error picking game from index:", err)
+		if filtered {
+			os.Exit(1)
+		}
+	} else if filtered {
+		fmt.Println("No games found. Filters require a games index from Search or Remote.")
+		os.Exit(1)
+	}
+
 	results := games.GetSystemPaths(cfg, systems)
 	if len(results) == 0 {
 		fmt.Println("No games folders found.")
@@ -117,7 +174,7 @@ func main() {
 			}
 
 			game, err := mister.TryPickRandomGame(system, folder)
-			if err != nil || game == "" {
+			if err != nil || game == "" || !mras.Launchable(game) {
 				continue
 			} else {
 				// we did it
@@ -152,13 +209,16 @@ func main() {
 				continue
 			}
 
+			// only pick from one release of each game
+			files = games.Dedupe(files, cfg.Search.Regions)
+
 			system, err := games.GetSystem(systemId)
 			if err != nil {
 				continue
 			}
 
 			game, err := utils.RandomElem(files)
-			if err != nil {
+			if err != nil || !mras.Launchable(game) {
 				continue
 			} else {
 				// we did it
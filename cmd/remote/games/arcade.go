@@ -0,0 +1,99 @@
+package games
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
+	"github.com/wizzomafizzo/mrext/pkg/mister"
+	"github.com/wizzomafizzo/mrext/pkg/service"
+)
+
+type missingRom struct {
+	Zip  string `json:"zip"`
+	Part string `json:"part"`
+	Crc  string `json:"crc"`
+}
+
+type brokenArcadeGame struct {
+	Name    string       `json:"name"`
+	Path    string       `json:"path"`
+	SetName string       `json:"setname"`
+	Rbf     string       `json:"rbf"`
+	Error   string       `json:"error"`
+	Missing []missingRom `json:"missing"`
+}
+
+type brokenArcadeGames struct {
+	Data  []brokenArcadeGame `json:"data"`
+	Total int                `json:"total"`
+}
+
+// ListBrokenArcadeGames checks every indexed MRA file and returns the ones
+// which can't be read or are missing ROMs.
+func ListBrokenArcadeGames(logger *service.Logger, cfg *config.UserConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		system, err := games.GetSystem("Arcade")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("broken arcade games: getting system: %s", err)
+			return
+		}
+
+		results, _, err := IndexInstance.db.List([]games.System{*system}, 0, math.MaxInt32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("broken arcade games: listing games: %s", err)
+			return
+		}
+
+		payload := brokenArcadeGames{
+			Data: make([]brokenArcadeGame, 0),
+		}
+
+		validator := mister.NewMRAValidator(cfg)
+		for _, result := range results {
+			broken := brokenArcadeGame{
+				Name:    result.Name,
+				Path:    result.Path,
+				Missing: make([]missingRom, 0),
+			}
+
+			mra, err := metadata.ReadMra(result.Path)
+			if err != nil {
+				broken.Error = err.Error()
+				payload.Data = append(payload.Data, broken)
+				continue
+			}
+
+			missing := validator.Validate(mra)
+			if len(missing) == 0 {
+				continue
+			}
+
+			broken.SetName = mra.SetName
+			broken.Rbf = mra.Rbf
+			for _, m := range missing {
+				broken.Missing = append(broken.Missing, missingRom{
+					Zip:  m.Zip,
+					Part: m.Part,
+					Crc:  m.Crc,
+				})
+			}
+
+			payload.Data = append(payload.Data, broken)
+		}
+
+		payload.Total = len(payload.Data)
+
+		err = json.NewEncoder(w).Encode(payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("broken arcade games: encoding response: %s", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package games
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/mister"
+	"github.com/wizzomafizzo/mrext/pkg/service"
+)
+
+type coreRulesPayload struct {
+	Rules    []mister.CoreRule `json:"rules"`
+	Problems []string          `json:"problems"`
+}
+
+// ListCoreRules returns every valid rule in the core rules file, and any
+// problems with the invalid ones.
+func ListCoreRules(logger *service.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload := coreRulesPayload{
+			Rules:    make([]mister.CoreRule, 0),
+			Problems: make([]string, 0),
+		}
+
+		rules, err := mister.ReadCoreRules(config.CoreRulesFile)
+		var rulesErr *mister.CoreRulesError
+		if errors.As(err, &rulesErr) {
+			payload.Problems = rulesErr.Problems
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("list core rules: reading rules: %s", err)
+			return
+		}
+		payload.Rules = append(payload.Rules, rules...)
+
+		err = json.NewEncoder(w).Encode(payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("list core rules: encoding response: %s", err)
+			return
+		}
+	}
+}
+
+// PinCore sets the core, setname or MGL slot used to launch a single game.
+func PinCore(logger *service.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rule mister.CoreRule
+
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger.Error("pin core: decoding request: %s", err)
+			return
+		}
+
+		if rule.Path == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+
+		// only the path is matched for a single game
+		rule.Glob = ""
+		rule.Name = ""
+		rule.Hash = ""
+
+		err = mister.PinCore(config.CoreRulesFile, rule)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger.Error("pin core: %s", err)
+			return
+		}
+	}
+}
+
+// UnpinCore removes the pinned core of a single game.
+func UnpinCore(logger *service.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var args struct {
+			Path string `json:"path"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&args)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger.Error("unpin core: decoding request: %s", err)
+			return
+		}
+
+		err = mister.UnpinCore(config.CoreRulesFile, args.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("unpin core: %s", err)
+			return
+		}
+	}
+}
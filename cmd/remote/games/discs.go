@@ -0,0 +1,99 @@
+package games
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/mister"
+	"github.com/wizzomafizzo/mrext/pkg/service"
+	"github.com/wizzomafizzo/mrext/pkg/tracker"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+type disc struct {
+	Disc   int    `json:"disc"`
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Active bool   `json:"active"`
+}
+
+type discsPayload struct {
+	Path  string `json:"path"`
+	Discs []disc `json:"discs"`
+}
+
+// Return the discs of the currently playing game, or an empty list if
+// nothing is playing or the system doesn't use discs.
+func playingDiscs(tr *tracker.Tracker) discsPayload {
+	payload := discsPayload{
+		Path:  tr.ActiveGamePath,
+		Discs: make([]disc, 0),
+	}
+
+	if tr.ActiveGamePath == "" || !games.MultiDiscSystem(tr.ActiveSystem) {
+		return payload
+	}
+
+	for i, path := range games.DiscSet(tr.ActiveGamePath) {
+		payload.Discs = append(payload.Discs, disc{
+			Disc:   i + 1,
+			Name:   utils.RemoveFileExt(filepath.Base(path)),
+			Path:   path,
+			Active: path == tr.ActiveGamePath,
+		})
+	}
+
+	return payload
+}
+
+// ListDiscs returns every disc of the currently playing multi-disc game.
+func ListDiscs(logger *service.Logger, tr *tracker.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewEncoder(w).Encode(playingDiscs(tr))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("list discs: encoding response: %s", err)
+			return
+		}
+	}
+}
+
+// SwapDisc launches another disc of the currently playing multi-disc game,
+// by its number from ListDiscs.
+func SwapDisc(logger *service.Logger, cfg *config.UserConfig, tr *tracker.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var args struct {
+			Disc int `json:"disc"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&args)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger.Error("swap disc: decoding request: %s", err)
+			return
+		}
+
+		discs := playingDiscs(tr).Discs
+		if args.Disc < 1 || args.Disc > len(discs) {
+			http.Error(w, "invalid disc", http.StatusBadRequest)
+			return
+		}
+
+		system, err := games.GetSystem(tr.ActiveSystem)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("swap disc: getting system: %s", err)
+			return
+		}
+
+		err = mister.LaunchGame(cfg, *system, discs[args.Disc-1].Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("swap disc: during launch: %s", err)
+			return
+		}
+	}
+}
@@ -3,6 +3,7 @@ package games
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
 
@@ -10,6 +11,8 @@ import (
 	"github.com/wizzomafizzo/mrext/cmd/remote/systems"
 	"github.com/wizzomafizzo/mrext/cmd/remote/websocket"
 	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/index"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
 	"github.com/wizzomafizzo/mrext/pkg/service"
 
 	"github.com/wizzomafizzo/mrext/pkg/config"
@@ -19,9 +22,57 @@ import (
 const pageSize = 500
 
 type SearchResultGame struct {
-	System systems.System `json:"system"`
-	Name   string         `json:"name"`
-	Path   string         `json:"path"`
+	System      systems.System `json:"system"`
+	Name        string         `json:"name"`
+	Path        string         `json:"path"`
+	Canonical   string         `json:"canonical"`
+	Title       string         `json:"title"`
+	Regions     []string       `json:"regions"`
+	Languages   []string       `json:"languages"`
+	Revision    string         `json:"revision"`
+	Beta        bool           `json:"beta"`
+	Proto       bool           `json:"proto"`
+	Hack        bool           `json:"hack"`
+	Demo        bool           `json:"demo"`
+	Unlicensed  bool           `json:"unlicensed"`
+	Translation bool           `json:"translation"`
+	Verified    bool           `json:"verified"`
+	BadDump     bool           `json:"badDump"`
+	Genre       string         `json:"genre"`
+	Year        int            `json:"year"`
+	Developer   string         `json:"developer"`
+	Publisher   string         `json:"publisher"`
+	Players     int            `json:"players"`
+	Description string         `json:"description"`
+	Media       []string       `json:"media"`
+	Arcade      *arcadeInfo    `json:"arcade"`
+}
+
+type arcadeInfo struct {
+	SetName     string `json:"setname"`
+	Rbf         string `json:"rbf"`
+	Category    string `json:"category"`
+	Orientation string `json:"orientation"`
+	Buttons     int    `json:"buttons"`
+	Bootleg     bool   `json:"bootleg"`
+	Homebrew    bool   `json:"homebrew"`
+}
+
+// Return the arcade info of a result, or nil if it's not an arcade game.
+func newArcadeInfo(info metadata.ArcadeInfo) *arcadeInfo {
+	if info.SetName == "" && info.Rbf == "" {
+		return nil
+	}
+
+	return &arcadeInfo{
+		SetName:     info.SetName,
+		Rbf:         info.Rbf,
+		Category:    info.Category,
+		Orientation: info.Orientation,
+		Buttons:     info.Buttons,
+		Bootleg:     info.Bootleg,
+		Homebrew:    info.Homebrew,
+	}
 }
 
 type SearchResults struct {
@@ -33,6 +84,7 @@ type SearchResults struct {
 
 type Index struct {
 	mu          sync.Mutex
+	db          index.Index
 	Indexing    bool   `json:"indexing"`
 	TotalSteps  int    `json:"totalSteps"`
 	CurrentStep int    `json:"currentStep"`
@@ -42,7 +94,7 @@ type Index struct {
 func GetIndexingStatus() string {
 	status := "indexStatus:"
 
-	if gamesdb.DbExists() {
+	if IndexInstance.db != nil && IndexInstance.db.Exists() {
 		status += "y,"
 	} else {
 		status += "n,"
@@ -64,7 +116,9 @@ func GetIndexingStatus() string {
 	return status
 }
 
-func (s *Index) GenerateIndex(logger *service.Logger, cfg *config.UserConfig) {
+// GenerateIndex updates the games index in the background. Only changed
+// folders are read again, unless rebuild is set.
+func (s *Index) GenerateIndex(logger *service.Logger, cfg *config.UserConfig, rebuild bool) {
 	if s.Indexing {
 		return
 	}
@@ -77,13 +131,23 @@ func (s *Index) GenerateIndex(logger *service.Logger, cfg *config.UserConfig) {
 	go func() {
 		defer s.mu.Unlock()
 
-		_, err := gamesdb.NewNamesIndex(cfg, games.AllSystems(), func(status gamesdb.IndexStatus) {
+		generate := s.db.Update
+		if rebuild {
+			generate = s.db.Generate
+		}
+
+		_, err := generate(games.AllSystems(), func(status gamesdb.IndexStatus) {
 			s.TotalSteps = status.Total
 			s.CurrentStep = status.Step
 			if status.Step == 1 {
 				s.CurrentDesc = "Finding games folders..."
 			} else if status.Step == status.Total {
-				s.CurrentDesc = "Writing database... (" + fmt.Sprint(status.Files) + " games)"
+				s.CurrentDesc = fmt.Sprintf(
+					"Writing database... (%d games: %d added / %d removed)",
+					status.Files,
+					status.Added,
+					status.Removed,
+				)
 			} else {
 				system, err := games.GetSystem(status.SystemId)
 				if err != nil {
@@ -110,11 +174,35 @@ func NewIndex() *Index {
 	return &Index{}
 }
 
+// Open sets up the games index backend selected in the user config. Must be
+// called before the index is used.
+func (s *Index) Open(cfg *config.UserConfig) error {
+	db, err := index.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	s.db = db
+	return nil
+}
+
 var IndexInstance = NewIndex()
 
+type generateIndexRequest struct {
+	Rebuild bool `json:"rebuild"`
+}
+
 func GenerateSearchIndex(logger *service.Logger, cfg *config.UserConfig) http.HandlerFunc {
-	return func(_ http.ResponseWriter, _ *http.Request) {
-		IndexInstance.GenerateIndex(logger, cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		var args generateIndexRequest
+		err := json.NewDecoder(r.Body).Decode(&args)
+		if err != nil && err != io.EOF {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger.Error("generate index: decoding request: %s", err)
+			return
+		}
+
+		IndexInstance.GenerateIndex(logger, cfg, args.Rebuild)
 	}
 }
 
@@ -123,17 +211,25 @@ type listSystemsPayloadSystem struct {
 	Name string `json:"name"`
 }
 
+type listSystemsPayloadGroup struct {
+	Id      string   `json:"id"`
+	Name    string   `json:"name"`
+	Systems []string `json:"systems"`
+}
+
 type listSystemsPayload struct {
 	Systems []listSystemsPayloadSystem `json:"systems"`
+	Groups  []listSystemsPayloadGroup  `json:"groups"`
 }
 
 func ListSystems(logger *service.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, _ *http.Request) {
 		payload := listSystemsPayload{
 			Systems: make([]listSystemsPayloadSystem, 0),
+			Groups:  make([]listSystemsPayloadGroup, 0),
 		}
 
-		indexed, err := gamesdb.IndexedSystems()
+		indexed, err := IndexInstance.db.Systems()
 		if err != nil {
 			logger.Error("list systems: getting indexed systems: %s", err)
 			indexed = []string{}
@@ -157,6 +253,29 @@ func ListSystems(logger *service.Logger) http.HandlerFunc {
 			})
 		}
 
+		// only list groups which contain an indexed system
+		for _, group := range games.SystemGroups() {
+			var ids []string
+			for _, system := range group.Systems {
+				for _, id := range indexed {
+					if system.Id == id {
+						ids = append(ids, id)
+						break
+					}
+				}
+			}
+
+			if len(ids) == 0 {
+				continue
+			}
+
+			payload.Groups = append(payload.Groups, listSystemsPayloadGroup{
+				Id:      group.Id,
+				Name:    group.Name,
+				Systems: ids,
+			})
+		}
+
 		err = json.NewEncoder(w).Encode(payload)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -166,11 +285,40 @@ func ListSystems(logger *service.Logger) http.HandlerFunc {
 	}
 }
 
-func Search(logger *service.Logger) http.HandlerFunc {
+// Avoid null values in JSON responses.
+func emptyIfNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+type searchFilterArgs struct {
+	Regions         []string `json:"regions"`
+	ExcludeBeta     bool     `json:"excludeBeta"`
+	ExcludeProto    bool     `json:"excludeProto"`
+	ExcludeHack     bool     `json:"excludeHack"`
+	ExcludeBad      bool     `json:"excludeBad"`
+	BestOnly        bool     `json:"bestOnly"`
+	Genre           string   `json:"genre"`
+	Developer       string   `json:"developer"`
+	Publisher       string   `json:"publisher"`
+	YearFrom        int      `json:"yearFrom"`
+	YearTo          int      `json:"yearTo"`
+	Players         int      `json:"players"`
+	ExcludeBootleg  bool     `json:"excludeBootleg"`
+	ExcludeHomebrew bool     `json:"excludeHomebrew"`
+	Orientation     string   `json:"orientation"`
+}
+
+func Search(logger *service.Logger, cfg *config.UserConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var args struct {
-			Query  string `json:"query"`
-			System string `json:"system"`
+			Query    string            `json:"query"`
+			System   string            `json:"system"`
+			Filter   *searchFilterArgs `json:"filter"`
+			Page     int               `json:"page"`
+			PageSize int               `json:"pageSize"`
 		}
 
 		err := json.NewDecoder(r.Body).Decode(&args)
@@ -180,19 +328,48 @@ func Search(logger *service.Logger) http.HandlerFunc {
 			return
 		}
 
+		var filter gamesdb.SearchFilter
+		if args.Filter != nil {
+			filter = gamesdb.SearchFilter{
+				Regions:         args.Filter.Regions,
+				ExcludeBeta:     args.Filter.ExcludeBeta,
+				ExcludeProto:    args.Filter.ExcludeProto,
+				ExcludeHack:     args.Filter.ExcludeHack,
+				ExcludeBad:      args.Filter.ExcludeBad,
+				BestOnly:        args.Filter.BestOnly,
+				Genre:           args.Filter.Genre,
+				Developer:       args.Filter.Developer,
+				Publisher:       args.Filter.Publisher,
+				YearFrom:        args.Filter.YearFrom,
+				YearTo:          args.Filter.YearTo,
+				Players:         args.Filter.Players,
+				ExcludeBootleg:  args.Filter.ExcludeBootleg,
+				ExcludeHomebrew: args.Filter.ExcludeHomebrew,
+				Orientation:     args.Filter.Orientation,
+			}
+		} else {
+			filter, err = gamesdb.NewSearchFilter(cfg)
+			if err != nil {
+				logger.Error("search games: reading filter config: %s", err)
+			}
+		}
+
 		var results = make([]SearchResultGame, 0)
 		var search []gamesdb.SearchResult
 
 		if args.System == "all" || args.System == "" {
-			search, err = gamesdb.SearchNamesWords(games.AllSystems(), args.Query)
+			search, err = IndexInstance.db.Search(games.AllSystems(), args.Query)
+		} else if system, errSys := games.GetSystem(args.System); errSys == nil {
+			search, err = IndexInstance.db.Search([]games.System{*system}, args.Query)
 		} else {
-			system, errSys := games.GetSystem(args.System)
+			// lists and groups of systems, e.g. "@console,-@nintendo"
+			systems, errSys := games.LookupSystems(args.System)
 			if errSys != nil {
 				http.Error(w, errSys.Error(), http.StatusBadRequest)
-				logger.Error("search games: getting system: %s", err)
+				logger.Error("search games: getting system: %s", errSys)
 				return
 			}
-			search, err = gamesdb.SearchNamesWords([]games.System{*system}, args.Query)
+			search, err = IndexInstance.db.Search(systems, args.Query)
 		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -200,7 +377,20 @@ func Search(logger *service.Logger) http.HandlerFunc {
 			return
 		}
 
-		for _, result := range search {
+		search = gamesdb.FilterResults(search, filter)
+		total := len(search)
+
+		page := args.Page
+		if page < 1 {
+			page = 1
+		}
+
+		size := args.PageSize
+		if size < 1 || size > pageSize {
+			size = pageSize
+		}
+
+		for _, result := range index.Page(search, (page-1)*size, size) {
 			system, err := games.GetSystem(result.SystemId)
 			if err != nil {
 				continue
@@ -211,22 +401,37 @@ func Search(logger *service.Logger) http.HandlerFunc {
 					Id:   system.Id,
 					Name: system.Name,
 				},
-				Name: result.Name,
-				Path: result.Path,
+				Name:        result.Name,
+				Path:        result.Path,
+				Canonical:   result.Canonical,
+				Title:       result.Tags.Title,
+				Regions:     emptyIfNil(result.Tags.Regions),
+				Languages:   emptyIfNil(result.Tags.Languages),
+				Revision:    result.Tags.Revision,
+				Beta:        result.Tags.Beta,
+				Proto:       result.Tags.Proto,
+				Hack:        result.Tags.Hack,
+				Demo:        result.Tags.Demo,
+				Unlicensed:  result.Tags.Unlicensed,
+				Translation: result.Tags.Translation,
+				Verified:    result.Tags.Verified,
+				BadDump:     result.Tags.BadDump,
+				Genre:       result.Metadata.Genre,
+				Year:        result.Metadata.Year,
+				Developer:   result.Metadata.Developer,
+				Publisher:   result.Metadata.Publisher,
+				Players:     result.Metadata.Players,
+				Description: result.Metadata.Description,
+				Media:       mediaTypes(result.Media),
+				Arcade:      newArcadeInfo(result.Arcade),
 			})
 		}
 
-		total := len(results)
-
-		if len(results) > pageSize {
-			results = results[:pageSize]
-		}
-
 		err = json.NewEncoder(w).Encode(&SearchResults{
 			Data:     results,
 			Total:    total,
-			PageSize: pageSize,
-			Page:     1,
+			PageSize: size,
+			Page:     page,
 		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
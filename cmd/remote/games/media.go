@@ -0,0 +1,96 @@
+package games
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/index"
+	"github.com/wizzomafizzo/mrext/pkg/media"
+	"github.com/wizzomafizzo/mrext/pkg/service"
+)
+
+const (
+	defaultThumbnailSize = 256
+	maxThumbnailSize     = 1024
+)
+
+// Return the types of media found for a game, in the order of media.Types.
+func mediaTypes(found map[string]string) []string {
+	types := make([]string, 0)
+	for _, t := range media.Types {
+		if _, ok := found[t]; ok {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// ViewMedia serves a thumbnail of an image for an indexed game, looked up by
+// system ID and name. The type query parameter selects the media type and
+// size sets the max width and height, 0 serves the original image.
+func ViewMedia(logger *service.Logger, cfg *config.UserConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		if !index.UsesGamesDb(cfg) {
+			http.NotFound(w, r)
+			return
+		}
+
+		system, err := games.GetSystem(vars["system"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mediaType := r.URL.Query().Get("type")
+		if mediaType == "" {
+			mediaType = media.TypeBoxart
+		}
+
+		size := defaultThumbnailSize
+		if v := r.URL.Query().Get("size"); v != "" {
+			size, err = strconv.Atoi(v)
+			if err != nil || size < 0 || size > maxThumbnailSize {
+				http.Error(w, "invalid size", http.StatusBadRequest)
+				return
+			}
+		}
+
+		results, err := gamesdb.SearchNamesExact([]games.System{*system}, vars["name"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("view media: searching: %s", err)
+			return
+		}
+
+		path := ""
+		for _, result := range results {
+			if p, ok := result.Media[mediaType]; ok {
+				path = p
+				break
+			}
+		}
+
+		if path == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if size > 0 {
+			path, err = media.Thumbnail(path, size)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				logger.Error("view media: creating thumbnail: %s", err)
+				return
+			}
+		}
+
+		http.ServeFile(w, r, path)
+	}
+}
@@ -0,0 +1,428 @@
+package games
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/playlog"
+	"github.com/wizzomafizzo/mrext/pkg/service"
+	"github.com/wizzomafizzo/mrext/pkg/tracker"
+)
+
+type playLogGame struct {
+	Id     string `json:"id"`
+	Path   string `json:"path"`
+	Name   string `json:"name"`
+	Folder string `json:"folder"`
+	Time   int    `json:"time"`
+}
+
+type playLogCore struct {
+	Name string `json:"name"`
+	Time int    `json:"time"`
+}
+
+type playLogSystem struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Time int    `json:"time"`
+}
+
+type playLogSession struct {
+	Id       int64     `json:"id"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration int       `json:"duration"`
+	Core     string    `json:"core"`
+	System   string    `json:"system"`
+	GameId   string    `json:"gameId"`
+	GamePath string    `json:"gamePath"`
+	GameName string    `json:"gameName"`
+	EndedBy  string    `json:"endedBy"`
+}
+
+type playLogBucket struct {
+	Start    time.Time `json:"start"`
+	Time     int       `json:"time"`
+	Sessions int       `json:"sessions"`
+}
+
+type playLogTotal struct {
+	Core     string `json:"core"`
+	System   string `json:"system"`
+	GameId   string `json:"gameId"`
+	GamePath string `json:"gamePath"`
+	GameName string `json:"gameName"`
+	Time     int    `json:"time"`
+	Sessions int    `json:"sessions"`
+}
+
+const maxHistogramBuckets = 1000
+
+var sessionEndedBy = map[int]string{
+	tracker.SessionActive:            "active",
+	tracker.SessionEndedByStop:       "stop",
+	tracker.SessionEndedByCoreSwitch: "coreSwitch",
+	tracker.SessionEndedByGameSwitch: "gameSwitch",
+	tracker.SessionEndedByPowerLoss:  "powerLoss",
+}
+
+func newPlayLogSession(s tracker.Session) playLogSession {
+	return playLogSession{
+		Id:       s.Id,
+		Start:    s.Start,
+		End:      s.End,
+		Duration: s.Duration,
+		Core:     s.Core,
+		System:   s.System,
+		GameId:   s.GameId,
+		GamePath: s.GamePath,
+		GameName: s.GameName,
+		EndedBy:  sessionEndedBy[s.EndedBy],
+	}
+}
+
+// Parse a date query parameter as either a local date (2006-01-02) or an
+// RFC 3339 timestamp. A missing parameter is a zero time.
+func parseDateQuery(r *http.Request, key string) (time.Time, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return t, fmt.Errorf("invalid %s date: %s", key, v)
+	}
+
+	return t, nil
+}
+
+func parseLimitQuery(r *http.Request, def int) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return def, nil
+	}
+
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid limit: %s", v)
+	}
+
+	return limit, nil
+}
+
+func writePlayLog(w http.ResponseWriter, logger *service.Logger, name string, v any) {
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.Error("playlog %s: encoding response: %s", name, err)
+	}
+}
+
+// Write an error if the PlayLog database couldn't be opened when Remote
+// started.
+func playLogUnavailable(w http.ResponseWriter, pl *playlog.Db) bool {
+	if pl == nil {
+		http.Error(w, "playlog database is not available", http.StatusServiceUnavailable)
+		return true
+	}
+	return false
+}
+
+// PlayLogGames returns the total play time of each game, most played first.
+func PlayLogGames(logger *service.Logger, pl *playlog.Db) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if playLogUnavailable(w, pl) {
+			return
+		}
+
+		limit, err := parseLimitQuery(r, -1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		gts, err := pl.TopGames(limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("playlog games: %s", err)
+			return
+		}
+
+		payload := make([]playLogGame, 0)
+		for _, gt := range gts {
+			payload = append(payload, playLogGame{
+				Id:     gt.Id,
+				Path:   gt.Path,
+				Name:   gt.Name,
+				Folder: gt.Folder,
+				Time:   gt.Time,
+			})
+		}
+
+		writePlayLog(w, logger, "games", payload)
+	}
+}
+
+// PlayLogCores returns the total play time of each core, most played first.
+func PlayLogCores(logger *service.Logger, pl *playlog.Db) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if playLogUnavailable(w, pl) {
+			return
+		}
+
+		limit, err := parseLimitQuery(r, -1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cts, err := pl.TopCores(limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("playlog cores: %s", err)
+			return
+		}
+
+		payload := make([]playLogCore, 0)
+		for _, ct := range cts {
+			payload = append(payload, playLogCore{
+				Name: ct.Name,
+				Time: ct.Time,
+			})
+		}
+
+		writePlayLog(w, logger, "cores", payload)
+	}
+}
+
+// PlayLogSystems returns the total play time of all games in each system,
+// most played first.
+func PlayLogSystems(logger *service.Logger, pl *playlog.Db) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if playLogUnavailable(w, pl) {
+			return
+		}
+
+		limit, err := parseLimitQuery(r, -1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sts, err := pl.TopSystems(limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("playlog systems: %s", err)
+			return
+		}
+
+		payload := make([]playLogSystem, 0)
+		for _, st := range sts {
+			name := st.System
+			if system, err := games.GetSystem(st.System); err == nil {
+				name = system.Name
+			}
+
+			payload = append(payload, playLogSystem{
+				Id:   st.System,
+				Name: name,
+				Time: st.Time,
+			})
+		}
+
+		writePlayLog(w, logger, "systems", payload)
+	}
+}
+
+// PlayLogSessions returns the most recent play sessions, newest first. If a
+// date range is given, all sessions started in it are returned instead,
+// oldest first.
+func PlayLogSessions(logger *service.Logger, pl *playlog.Db) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if playLogUnavailable(w, pl) {
+			return
+		}
+
+		limit, err := parseLimitQuery(r, 20)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		from, err := parseDateQuery(r, "from")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		to, err := parseDateQuery(r, "to")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var sessions []tracker.Session
+		if from.IsZero() && to.IsZero() {
+			sessions, err = pl.RecentSessions(limit)
+		} else {
+			sessions, err = pl.SessionsBetween(from, to)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("playlog sessions: %s", err)
+			return
+		}
+
+		payload := make([]playLogSession, 0)
+		for _, s := range sessions {
+			payload = append(payload, newPlayLogSession(s))
+		}
+
+		writePlayLog(w, logger, "sessions", payload)
+	}
+}
+
+// PlayLogHistogram returns the total play time and number of sessions in
+// each day, week or month of a date range. Buckets with no sessions are
+// included with zero values.
+func PlayLogHistogram(logger *service.Logger, pl *playlog.Db) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if playLogUnavailable(w, pl) {
+			return
+		}
+
+		now := time.Now()
+
+		var periodStart func(time.Time) time.Time
+		var next func(time.Time) time.Time
+		var defaultFrom time.Time
+
+		switch r.URL.Query().Get("bucket") {
+		case "", "day":
+			periodStart = playlog.StartOfDay
+			next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+			defaultFrom = playlog.StartOfDay(now).AddDate(0, 0, -29)
+		case "week":
+			periodStart = playlog.StartOfWeek
+			next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+			defaultFrom = playlog.StartOfWeek(now).AddDate(0, 0, -7*11)
+		case "month":
+			periodStart = playlog.StartOfMonth
+			next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+			defaultFrom = playlog.StartOfMonth(now).AddDate(0, -11, 0)
+		default:
+			http.Error(w, "bucket must be day, week or month", http.StatusBadRequest)
+			return
+		}
+
+		from, err := parseDateQuery(r, "from")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if from.IsZero() {
+			from = defaultFrom
+		}
+
+		to, err := parseDateQuery(r, "to")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if to.IsZero() {
+			to = now
+		}
+
+		if !to.After(from) {
+			http.Error(w, "to must be after from", http.StatusBadRequest)
+			return
+		}
+
+		sessions, err := pl.SessionsBetween(from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("playlog histogram: %s", err)
+			return
+		}
+
+		periods := make(map[time.Time]playlog.Period)
+		for _, p := range playlog.GroupSessions(sessions, periodStart) {
+			periods[p.Start] = p
+		}
+
+		payload := make([]playLogBucket, 0)
+		for start := periodStart(from.Local()); start.Before(to); start = next(start) {
+			if len(payload) >= maxHistogramBuckets {
+				http.Error(w, "date range has too many buckets", http.StatusBadRequest)
+				return
+			}
+
+			bucket := playLogBucket{Start: start}
+			if p, ok := periods[start]; ok {
+				bucket.Time = p.Total
+				bucket.Sessions = len(p.Sessions)
+			}
+			payload = append(payload, bucket)
+		}
+
+		writePlayLog(w, logger, "histogram", payload)
+	}
+}
+
+// PlayLogTop returns the most played games, and cores played without a game,
+// in a date range. With no range, all sessions are counted.
+func PlayLogTop(logger *service.Logger, pl *playlog.Db) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if playLogUnavailable(w, pl) {
+			return
+		}
+
+		limit, err := parseLimitQuery(r, 10)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		from, err := parseDateQuery(r, "from")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		to, err := parseDateQuery(r, "to")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sessions, err := pl.SessionsBetween(from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			logger.Error("playlog top: %s", err)
+			return
+		}
+
+		payload := make([]playLogTotal, 0)
+		for _, t := range playlog.MostPlayed(sessions, limit) {
+			payload = append(payload, playLogTotal{
+				Core:     t.Core,
+				System:   t.System,
+				GameId:   t.GameId,
+				GamePath: t.GamePath,
+				GameName: t.GameName,
+				Time:     t.Time,
+				Sessions: t.Sessions,
+			})
+		}
+
+		writePlayLog(w, logger, "top", payload)
+	}
+}
@@ -2,71 +2,157 @@ package games
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/wizzomafizzo/mrext/cmd/remote/websocket"
 	"github.com/wizzomafizzo/mrext/pkg/config"
 	"github.com/wizzomafizzo/mrext/pkg/mister"
+	"github.com/wizzomafizzo/mrext/pkg/playlog"
 	"github.com/wizzomafizzo/mrext/pkg/service"
 	"github.com/wizzomafizzo/mrext/pkg/tracker"
 )
 
-type fakeDb struct {
-	logger *service.Logger
-	cfg    *config.UserConfig
+// DisplayGameId returns a tracker game ID as the system ID and filename of
+// the game, which is what Remote clients expect. Arcade games are left as
+// their setname.
+func DisplayGameId(id string, path string) string {
+	system, rest, ok := strings.Cut(id, "/")
+	if !ok {
+		return id
+	} else if system == tracker.ArcadeSystem {
+		return rest
+	} else if path == "" {
+		return id
+	}
+	return system + "/" + filepath.Base(path)
+}
+
+// trackerDb broadcasts tracker events to websocket clients and records them
+// to the PlayLog database. If another process, like the PlayLog service, was
+// already recording when Remote started, the database is only read from.
+type trackerDb struct {
+	logger    *service.Logger
+	cfg       *config.UserConfig
+	pl        *playlog.Db
+	recording bool
 }
 
-func (f *fakeDb) FixPowerLoss() (bool, error) {
-	return false, nil
+func (t *trackerDb) FixPowerLoss() (bool, error) {
+	if !t.recording {
+		return false, nil
+	}
+	return t.pl.FixPowerLoss()
 }
 
-func (f *fakeDb) AddEvent(ev tracker.EventAction) error {
+func (t *trackerDb) AddEvent(ev tracker.EventAction) error {
 	switch ev.Action {
 	case tracker.EventActionCoreStart:
-		websocket.Broadcast(f.logger, "coreRunning:"+ev.Target)
-		SendAnnounceGame(f.cfg, f.logger, &ev)
+		websocket.Broadcast(t.logger, "coreRunning:"+ev.Target)
+		SendAnnounceGame(t.cfg, t.logger, &ev)
 	case tracker.EventActionCoreStop:
-		websocket.Broadcast(f.logger, "coreRunning:")
-		SendAnnounceGame(f.cfg, f.logger, &ev)
+		websocket.Broadcast(t.logger, "coreRunning:")
+		SendAnnounceGame(t.cfg, t.logger, &ev)
 	case tracker.EventActionGameStart:
-		websocket.Broadcast(f.logger, "gameRunning:"+ev.Target)
-		SendAnnounceGame(f.cfg, f.logger, &ev)
+		websocket.Broadcast(t.logger, "gameRunning:"+DisplayGameId(ev.Target, ev.TargetPath))
+		SendAnnounceGame(t.cfg, t.logger, &ev)
 	case tracker.EventActionGameStop:
-		websocket.Broadcast(f.logger, "gameRunning:")
-		SendAnnounceGame(f.cfg, f.logger, &ev)
+		websocket.Broadcast(t.logger, "gameRunning:")
+		SendAnnounceGame(t.cfg, t.logger, &ev)
 	case tracker.EventActionMenuNavigation:
-		websocket.Broadcast(f.logger, "menuNavigation:"+ev.Target)
+		websocket.Broadcast(t.logger, "menuNavigation:"+ev.Target)
+	}
+
+	if !t.recording {
+		return nil
+	}
+	return t.pl.AddEvent(ev)
+}
+
+func (t *trackerDb) UpdateCore(ct tracker.CoreTime) error {
+	if !t.recording {
+		return nil
+	}
+	return t.pl.UpdateCore(ct)
+}
+
+func (t *trackerDb) GetCore(name string) (tracker.CoreTime, error) {
+	if t.pl == nil {
+		return tracker.CoreTime{}, sql.ErrNoRows
+	}
+	return t.pl.GetCore(name)
+}
+
+func (t *trackerDb) UpdateGame(gt tracker.GameTime) error {
+	if !t.recording {
+		return nil
+	}
+	return t.pl.UpdateGame(gt)
+}
+
+func (t *trackerDb) GetGame(id string) (tracker.GameTime, error) {
+	if t.pl == nil {
+		return tracker.GameTime{}, sql.ErrNoRows
 	}
-	return nil
+	return t.pl.GetGame(id)
 }
 
-func (f *fakeDb) UpdateCore(_ tracker.CoreTime) error {
-	return nil
+func (t *trackerDb) RenameGame(oldId string, newId string) error {
+	if !t.recording {
+		return nil
+	}
+	return t.pl.RenameGame(oldId, newId)
 }
 
-func (f *fakeDb) GetCore(_ string) (tracker.CoreTime, error) {
-	return tracker.CoreTime{}, nil
+func (t *trackerDb) UpdateArcadeSet(as tracker.ArcadeSet) error {
+	if !t.recording {
+		return nil
+	}
+	return t.pl.UpdateArcadeSet(as)
 }
 
-func (f *fakeDb) UpdateGame(_ tracker.GameTime) error {
-	return nil
+func (t *trackerDb) AddSession(s tracker.Session) (int64, error) {
+	if !t.recording {
+		return 0, nil
+	}
+	return t.pl.AddSession(s)
 }
 
-func (f *fakeDb) GetGame(_ string) (tracker.GameTime, error) {
-	return tracker.GameTime{}, nil
+func (t *trackerDb) UpdateSession(s tracker.Session) error {
+	if !t.recording || s.Id == 0 {
+		return nil
+	}
+	return t.pl.UpdateSession(s)
 }
 
-func (f *fakeDb) NoResults(_ error) bool {
-	return true
+func (t *trackerDb) NoResults(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
 }
 
-func StartTracker(logger *service.Logger, cfg *config.UserConfig) (*tracker.Tracker, func() error, error) {
-	tr, err := tracker.NewTracker(logger, cfg, &fakeDb{
-		logger: logger,
-		cfg:    cfg,
+// StartTracker starts tracking the running core and game. If pl is nil,
+// play times are not recorded.
+func StartTracker(logger *service.Logger, cfg *config.UserConfig, pl *playlog.Db) (*tracker.Tracker, func() error, error) {
+	recording := false
+	if pl != nil {
+		locked, err := pl.LockRecording()
+		if err != nil {
+			logger.Error("failed to lock playlog db, play times won't be recorded: %s", err)
+		} else if !locked {
+			logger.Info("playlog db is being recorded by another process")
+		}
+		recording = locked
+	}
+
+	tr, err := tracker.NewTracker(logger, cfg, &trackerDb{
+		logger:    logger,
+		cfg:       cfg,
+		pl:        pl,
+		recording: recording,
 	})
 	if err != nil {
 		logger.Error("failed to start tracker: %s", err)
@@ -87,7 +173,7 @@ func StartTracker(logger *service.Logger, cfg *config.UserConfig) (*tracker.Trac
 		return nil, nil, err
 	}
 
-	tr.StartTicker(0)
+	tr.StartTicker(cfg.PlayLog.SaveEvery)
 
 	return tr, func() error {
 		err := watcher.Close()
@@ -113,7 +199,7 @@ func HandlePlaying(tr *tracker.Tracker) http.HandlerFunc {
 			Core:       tr.ActiveCore,
 			System:     tr.ActiveSystem,
 			SystemName: tr.ActiveSystemName,
-			Game:       tr.ActiveGame,
+			Game:       DisplayGameId(tr.ActiveGame, tr.ActiveGamePath),
 			GameName:   tr.ActiveGameName,
 		}
 
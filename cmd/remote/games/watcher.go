@@ -0,0 +1,92 @@
+package games
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/cmd/remote/websocket"
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/index"
+	"github.com/wizzomafizzo/mrext/pkg/mister"
+	"github.com/wizzomafizzo/mrext/pkg/service"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+// How often to check for newly mounted or removed games folders.
+const mountPollInterval = 10 * time.Second
+
+// WatcherSupported returns true if the index backend in the user config can
+// be kept updated by the index watcher.
+func WatcherSupported(cfg *config.UserConfig) bool {
+	return index.UsesGamesDb(cfg)
+}
+
+// StartIndexWatcher keeps the search index updated as games are added to or
+// removed from the games folders.
+func StartIndexWatcher(logger *service.Logger, cfg *config.UserConfig) (func() error, error) {
+	watcher, err := gamesdb.NewWatcher(logger, cfg, func(status gamesdb.IndexStatus, err error) {
+		if err != nil {
+			logger.Error("index watcher: updating index: %s", err)
+			return
+		}
+
+		if status.Added == 0 && status.Removed == 0 {
+			return
+		}
+
+		logger.Info(
+			"index watcher: %d games added, %d removed",
+			status.Added,
+			status.Removed,
+		)
+		websocket.Broadcast(logger, fmt.Sprintf(
+			"indexUpdated:%d,%d,%d",
+			status.Files,
+			status.Added,
+			status.Removed,
+		))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mounts, _ := mister.GetMounts(cfg)
+	ticker := time.NewTicker(mountPollInterval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				newMounts, err := mister.GetMounts(cfg)
+				if err != nil {
+					logger.Error("index watcher: reading mounts: %s", err)
+					continue
+				}
+
+				changed := len(newMounts) != len(mounts)
+				for _, m := range newMounts {
+					if !utils.Contains(mounts, m) {
+						changed = true
+						break
+					}
+				}
+
+				if changed {
+					logger.Info("index watcher: games folder mounts changed")
+					mounts = newMounts
+					watcher.Refresh()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() error {
+		ticker.Stop()
+		close(done)
+		return watcher.Close()
+	}, nil
+}
@@ -23,8 +23,10 @@ import (
 	"github.com/wizzomafizzo/mrext/cmd/remote/systems"
 	"github.com/wizzomafizzo/mrext/cmd/remote/wallpapers"
 	"github.com/wizzomafizzo/mrext/cmd/remote/websocket"
+	mrgames "github.com/wizzomafizzo/mrext/pkg/games"
 	"github.com/wizzomafizzo/mrext/pkg/input"
 	"github.com/wizzomafizzo/mrext/pkg/mister"
+	"github.com/wizzomafizzo/mrext/pkg/playlog"
 	"github.com/wizzomafizzo/mrext/pkg/tracker"
 
 	gc "github.com/rthornton128/goncurses"
@@ -55,7 +57,7 @@ func wsConnectPayload(trk *tracker.Tracker) func() []string {
 
 		if trk != nil {
 			response = append(response, "coreRunning:"+trk.ActiveCore)
-			response = append(response, "gameRunning:"+trk.ActiveGame)
+			response = append(response, "gameRunning:"+games.DisplayGameId(trk.ActiveGame, trk.ActiveGamePath))
 		}
 
 		return response
@@ -129,14 +131,64 @@ func startService(logger *service.Logger, cfg *config.UserConfig) (func() error,
 		return nil, err
 	}
 
-	trk, stopTracker, err := games.StartTracker(logger, cfg)
+	pl, err := playlog.Open(config.PlayLogDbFile)
+	if err != nil {
+		logger.Error("failed to open playlog db, play times won't be recorded: %s", err)
+	}
+
+	var stopTracker, stopWatcher func() error
+
+	// stop everything which has been started, in reverse order
+	stopServices := func() {
+		if stopWatcher != nil {
+			err := stopWatcher()
+			if err != nil {
+				logger.Error("failed to stop index watcher: %s", err)
+			}
+		}
+
+		if stopTracker != nil {
+			err := stopTracker()
+			if err != nil {
+				logger.Error("failed to stop tracker: %s", err)
+			}
+		}
+
+		if pl != nil {
+			err := pl.Close()
+			if err != nil {
+				logger.Error("failed to close playlog db: %s", err)
+			}
+		}
+
+		kbd.Close()
+	}
+
+	trk, stopTracker, err := games.StartTracker(logger, cfg, pl)
 	if err != nil {
 		logger.Error("failed to start tracker: %s", err)
+		stopServices()
 		return nil, err
 	}
 
 	runStartupTasks(logger, cfg, trk)
 
+	err = games.IndexInstance.Open(cfg)
+	if err != nil {
+		logger.Error("failed to open games index: %s", err)
+		stopServices()
+		return nil, err
+	}
+
+	if cfg.Remote.WatchGames && !games.WatcherSupported(cfg) {
+		logger.Error("watch_games is only supported by the gamesdb index backend")
+	} else if cfg.Remote.WatchGames {
+		stopWatcher, err = games.StartIndexWatcher(logger, cfg)
+		if err != nil {
+			logger.Error("failed to start index watcher: %s", err)
+		}
+	}
+
 	var stopMdns func() error
 	if cfg.Remote.MdnsService {
 		go func() {
@@ -145,7 +197,7 @@ func startService(logger *service.Logger, cfg *config.UserConfig) (func() error,
 	}
 
 	router := mux.NewRouter()
-	setupApi(router.PathPrefix("/api").Subrouter(), kbd, trk, logger, cfg)
+	setupApi(router.PathPrefix("/api").Subrouter(), kbd, trk, pl, logger, cfg)
 	router.PathPrefix("/").Handler(http.HandlerFunc(appHandler))
 
 	corsHandler := cors.New(cors.Options{
@@ -169,8 +221,6 @@ func startService(logger *service.Logger, cfg *config.UserConfig) (func() error,
 	}()
 
 	return func() error {
-		kbd.Close()
-
 		if stopMdns != nil {
 			err := stopMdns()
 			if err != nil {
@@ -178,12 +228,9 @@ func startService(logger *service.Logger, cfg *config.UserConfig) (func() error,
 			}
 		}
 
-		err := stopTracker()
-		if err != nil {
-			logger.Error("failed to stop tracker: %s", err)
-		}
+		stopServices()
 
-		err = srv.Close()
+		err := srv.Close()
 		if err != nil {
 			logger.Error("failed to shutdown server: %s", err)
 		}
@@ -192,7 +239,7 @@ func startService(logger *service.Logger, cfg *config.UserConfig) (func() error,
 	}, nil
 }
 
-func setupApi(sub *mux.Router, kbd input.Keyboard, trk *tracker.Tracker, logger *service.Logger, cfg *config.UserConfig) {
+func setupApi(sub *mux.Router, kbd input.Keyboard, trk *tracker.Tracker, pl *playlog.Db, logger *service.Logger, cfg *config.UserConfig) {
 	sub.HandleFunc("/ws", websocket.Handle(logger, wsConnectPayload(trk), wsMsgHandler(kbd)))
 
 	sub.HandleFunc("/screenshots", screenshots.AllScreenshots(logger)).Methods("GET")
@@ -200,7 +247,7 @@ func setupApi(sub *mux.Router, kbd input.Keyboard, trk *tracker.Tracker, logger
 	sub.HandleFunc("/screenshots/{core}/{image}", screenshots.ViewScreenshot(logger)).Methods("GET")
 	sub.HandleFunc("/screenshots/{core}/{image}", screenshots.DeleteScreenshot(logger)).Methods("DELETE")
 
-	sub.HandleFunc("/systems", systems.ListSystems(logger)).Methods("GET")
+	sub.HandleFunc("/systems", systems.ListSystems(cfg, logger)).Methods("GET")
 	sub.HandleFunc("/systems/{id}", systems.LaunchCore(cfg, logger)).Methods("POST")
 
 	sub.HandleFunc("/wallpapers", wallpapers.AllWallpapersHandler(logger)).Methods("GET")
@@ -216,12 +263,26 @@ func setupApi(sub *mux.Router, kbd input.Keyboard, trk *tracker.Tracker, logger
 	sub.HandleFunc("/music/playlist", music.AllPlaylists(logger)).Methods("GET")
 	sub.HandleFunc("/music/playlist/{playlist}", music.SetPlaylist(logger)).Methods("POST")
 
-	sub.HandleFunc("/games/search", games.Search(logger)).Methods("POST")
+	sub.HandleFunc("/games/search", games.Search(logger, cfg)).Methods("POST")
 	sub.HandleFunc("/games/search/systems", games.ListSystems(logger)).Methods("GET")
 	sub.HandleFunc("/games/launch", games.LaunchGame(logger, cfg)).Methods("POST")
 	sub.HandleFunc("/games/index", games.GenerateSearchIndex(logger, cfg)).Methods("POST")
 	sub.HandleFunc("/games/playing", games.HandlePlaying(trk)).Methods("GET")
 	sub.HandleFunc("/games/view", games.ListGamesFolder(logger)).Methods("POST")
+	sub.HandleFunc("/games/media/{system}/{name}", games.ViewMedia(logger, cfg)).Methods("GET")
+	sub.HandleFunc("/games/arcade/broken", games.ListBrokenArcadeGames(logger, cfg)).Methods("GET")
+	sub.HandleFunc("/games/discs", games.ListDiscs(logger, trk)).Methods("GET")
+	sub.HandleFunc("/games/discs", games.SwapDisc(logger, cfg, trk)).Methods("POST")
+	sub.HandleFunc("/games/cores", games.ListCoreRules(logger)).Methods("GET")
+	sub.HandleFunc("/games/cores", games.PinCore(logger)).Methods("PUT")
+	sub.HandleFunc("/games/cores", games.UnpinCore(logger)).Methods("DELETE")
+
+	sub.HandleFunc("/playlog/games", games.PlayLogGames(logger, pl)).Methods("GET")
+	sub.HandleFunc("/playlog/cores", games.PlayLogCores(logger, pl)).Methods("GET")
+	sub.HandleFunc("/playlog/systems", games.PlayLogSystems(logger, pl)).Methods("GET")
+	sub.HandleFunc("/playlog/sessions", games.PlayLogSessions(logger, pl)).Methods("GET")
+	sub.HandleFunc("/playlog/histogram", games.PlayLogHistogram(logger, pl)).Methods("GET")
+	sub.HandleFunc("/playlog/top", games.PlayLogTop(logger, pl)).Methods("GET")
 
 	sub.HandleFunc("/l/{data:.*}", games.LaunchToken(logger, cfg, kbd)).Methods("GET")
 
@@ -293,6 +354,9 @@ func main() {
 	flag.Parse()
 
 	cfg, err := config.LoadUserConfig(appName, &config.UserConfig{
+		PlayLog: config.PlayLogConfig{
+			SaveEvery: 5, // minutes
+		},
 		Remote: config.RemoteConfig{
 			MdnsService: true,
 			SyncSSHKeys: true,
@@ -305,6 +369,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = mrgames.LoadUserSystems(config.UserSystemsFile)
+	if err != nil {
+		logger.Error("error loading user systems: %s", err)
+		fmt.Println("Error loading user systems:", err)
+	}
+
 	err = os.MkdirAll(config.MrextConfigFolder, 0755)
 	if err != nil {
 		logger.Error("error creating config folder: %s", err)
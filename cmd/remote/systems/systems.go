@@ -16,10 +16,19 @@ import (
 	"github.com/wizzomafizzo/mrext/pkg/utils"
 )
 
+type SystemCore struct {
+	Variant string `json:"variant"`
+	Rbf     string `json:"rbf"`
+}
+
 type System struct {
 	Id       string `json:"id"`
 	Name     string `json:"name"`
 	Category string `json:"category"`
+	// every installed core, the main core has a blank variant
+	Cores []SystemCore `json:"cores"`
+	// the variant which will be launched
+	Variant string `json:"variant"`
 }
 
 var ignoreSystems = []string{
@@ -28,18 +37,18 @@ var ignoreSystems = []string{
 	"SNESMusic",
 }
 
-func ListSystems(logger *service.Logger) http.HandlerFunc {
+func ListSystems(cfg *config.UserConfig, logger *service.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var systems []System
 
-		existingSystems := utils.MapKeys(games.SystemsWithRbf())
+		rbfs := games.SystemRbfs()
 
 		for _, system := range games.Systems {
 			if utils.Contains(ignoreSystems, system.Id) {
 				continue
 			}
 
-			if !utils.Contains(existingSystems, system.Id) {
+			if _, ok := rbfs[system.Id]; !ok {
 				continue
 			}
 
@@ -48,11 +57,23 @@ func ListSystems(logger *service.Logger) http.HandlerFunc {
 				name = system.Name
 			}
 
+			cores := make([]SystemCore, 0)
+			for _, rbf := range rbfs[system.Id] {
+				cores = append(cores, SystemCore{
+					Variant: rbf.Variant,
+					Rbf:     rbf.MglName,
+				})
+			}
+
+			preferred, _ := games.PickRbf(cfg, system.Id, rbfs[system.Id])
+
 			systems = append(systems, System{
 				Id:   system.Id,
 				Name: name,
 				// TODO: error checking
 				Category: strings.Split(system.Rbf, "/")[0][1:],
+				Cores:    cores,
+				Variant:  preferred.Variant,
 			})
 		}
 
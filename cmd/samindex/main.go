@@ -92,7 +92,7 @@ func writeGamelist(gamelistDir string, systemId string, files []string) {
 }
 
 // Generate gamelists for all systems. Main workflow of app.
-func createGamelists(gamelistDir string, systemPaths map[string][]string, progress bool, quiet bool, filter bool) int {
+func createGamelists(gamelistDir string, systemPaths map[string][]string, progress bool, quiet bool, filter bool, priority []string) int {
 	start := time.Now()
 
 	if !quiet && !progress {
@@ -135,7 +135,7 @@ func createGamelists(gamelistDir string, systemPaths map[string][]string, progre
 		}
 
 		if filter {
-			systemFiles = games.FilterUniqueFilenames(systemFiles)
+			systemFiles = games.Dedupe(systemFiles, priority)
 		}
 
 		// filter out certain extensions
@@ -181,8 +181,14 @@ func main() {
 	quiet := flag.Bool("q", false, "suppress all status output")
 	detect := flag.Bool("d", false, "list active system folders")
 	noDupes := flag.Bool("nodupes", false, "filter out duplicate games")
+	priority := flag.String("priority", "", "preferred regions and languages for -nodupes (ex. USA,Europe,En)")
 	flag.Parse()
 
+	err := games.LoadUserSystems(config.UserSystemsFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading user systems:", err)
+	}
+
 	// filter systems
 	var systems []games.System
 	if *filter == "all" {
@@ -221,7 +227,12 @@ func main() {
 		systemPathsMap[p.System.Id] = append(systemPathsMap[p.System.Id], p.Path)
 	}
 
-	total := createGamelists(*gamelistDir, systemPathsMap, *progress, *quiet, *noDupes)
+	var priorityList []string
+	if *priority != "" {
+		priorityList = strings.Split(*priority, ",")
+	}
+
+	total := createGamelists(*gamelistDir, systemPathsMap, *progress, *quiet, *noDupes, priorityList)
 
 	if total == 0 {
 		os.Exit(8)
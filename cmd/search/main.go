@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/wizzomafizzo/mrext/pkg/mister"
@@ -15,6 +16,7 @@ import (
 	"github.com/wizzomafizzo/mrext/pkg/curses"
 	"github.com/wizzomafizzo/mrext/pkg/games"
 	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/index"
 	"github.com/wizzomafizzo/mrext/pkg/utils"
 )
 
@@ -24,7 +26,9 @@ import (
 
 const appName = "search"
 
-func generateIndexWindow(cfg *config.UserConfig, stdscr *gc.Window) error {
+// Show the progress of indexing games. Only changed folders are read again,
+// unless rebuild is set.
+func generateIndexWindow(cfg *config.UserConfig, idx index.Index, stdscr *gc.Window, rebuild bool) error {
 	win, err := curses.NewWindow(stdscr, 4, 75, "", -1)
 	if err != nil {
 		return err
@@ -64,7 +68,12 @@ func generateIndexWindow(cfg *config.UserConfig, stdscr *gc.Window) error {
 	}
 
 	go func() {
-		_, err = gamesdb.NewNamesIndex(cfg, games.AllSystems(), func(is gamesdb.IndexStatus) {
+		generate := idx.Update
+		if rebuild {
+			generate = idx.Generate
+		}
+
+		_, err = generate(games.AllSystems(), func(is gamesdb.IndexStatus) {
 			systemName := is.SystemId
 			system, err := games.GetSystem(is.SystemId)
 			if err == nil {
@@ -116,7 +125,7 @@ func generateIndexWindow(cfg *config.UserConfig, stdscr *gc.Window) error {
 	return status.Error
 }
 
-func mainOptionsWindow(cfg *config.UserConfig, stdscr *gc.Window) error {
+func mainOptionsWindow(cfg *config.UserConfig, idx index.Index, stdscr *gc.Window) error {
 	button, selected, err := curses.ListPicker(stdscr, curses.ListPickerOpts{
 		Title:         "Options",
 		Buttons:       []string{"Select", "Back"},
@@ -125,7 +134,7 @@ func mainOptionsWindow(cfg *config.UserConfig, stdscr *gc.Window) error {
 		ShowTotal:     false,
 		Width:         70,
 		Height:        18,
-	}, []string{"Update games database..."})
+	}, []string{"Update games database...", "Rebuild games database..."})
 
 	if err != nil {
 		return err
@@ -133,8 +142,8 @@ func mainOptionsWindow(cfg *config.UserConfig, stdscr *gc.Window) error {
 
 	if button == 0 {
 		switch selected {
-		case 0:
-			err := generateIndexWindow(cfg, stdscr)
+		case 0, 1:
+			err := generateIndexWindow(cfg, idx, stdscr, selected == 1)
 			if err != nil {
 				return err
 			}
@@ -144,7 +153,7 @@ func mainOptionsWindow(cfg *config.UserConfig, stdscr *gc.Window) error {
 	return nil
 }
 
-func searchWindow(cfg *config.UserConfig, stdscr *gc.Window, query string, launchGame bool) (err error) {
+func searchWindow(cfg *config.UserConfig, idx index.Index, stdscr *gc.Window, query string, launchGame bool) (err error) {
 	stdscr.Erase()
 	stdscr.NoutRefresh()
 	_ = gc.Update()
@@ -157,31 +166,43 @@ func searchWindow(cfg *config.UserConfig, stdscr *gc.Window, query string, launc
 	}
 
 	if button == 0 {
-		err = mainOptionsWindow(cfg, stdscr)
+		err = mainOptionsWindow(cfg, idx, stdscr)
 		if err != nil {
 			return err
 		}
 
-		return searchWindow(cfg, stdscr, text, launchGame)
+		return searchWindow(cfg, idx, stdscr, text, launchGame)
 	} else if button == 1 {
 		if len(text) == 0 {
-			return searchWindow(cfg, stdscr, "", launchGame)
+			return searchWindow(cfg, idx, stdscr, "", launchGame)
 		}
 
 		if err := curses.InfoBox(stdscr, "", "Searching...", false, false); err != nil {
 			log.Fatal(err)
 		}
 
-		results, err := gamesdb.SearchNamesWords(games.AllSystems(), text)
+		// invalid systems and filter config is reported on startup
+		systems, _ := searchSystems(cfg)
+		results, err := idx.Search(systems, text)
 		if err != nil {
 			return err
 		}
 
+		filter, _ := gamesdb.NewSearchFilter(cfg)
+		results = gamesdb.FilterResults(results, filter)
+
 		if len(results) == 0 {
 			if err := curses.InfoBox(stdscr, "", "No results found.", false, true); err != nil {
 				log.Fatal(err)
 			}
-			return searchWindow(cfg, stdscr, text, launchGame)
+			return searchWindow(cfg, idx, stdscr, text, launchGame)
+		}
+
+		// files with the same name in different folders are all listed, with
+		// their parent folder shown so they can be told apart
+		nameCounts := make(map[string]int)
+		for _, result := range results {
+			nameCounts[result.SystemId+":"+result.Name]++
 		}
 
 		var names []string
@@ -194,6 +215,10 @@ func searchWindow(cfg *config.UserConfig, stdscr *gc.Window, query string, launc
 			}
 
 			display := fmt.Sprintf("[%s] %s", systemName, result.Name)
+			if nameCounts[result.SystemId+":"+result.Name] > 1 {
+				display += fmt.Sprintf(" (%s)", filepath.Base(filepath.Dir(result.Path)))
+			}
+
 			if !utils.Contains(names, display) {
 				names = append(names, display)
 				items = append(items, result)
@@ -248,12 +273,21 @@ func searchWindow(cfg *config.UserConfig, stdscr *gc.Window, query string, launc
 			}
 		}
 
-		return searchWindow(cfg, stdscr, text, launchGame)
+		return searchWindow(cfg, idx, stdscr, text, launchGame)
 	} else {
 		return nil
 	}
 }
 
+// Return the systems to search, set by the systems option in the config.
+func searchSystems(cfg *config.UserConfig) ([]games.System, error) {
+	if cfg.Search.Systems == "" {
+		return games.AllSystems(), nil
+	}
+
+	return games.LookupSystems(cfg.Search.Systems)
+}
+
 func main() {
 	printPtr := flag.Bool("print", false, "Print game path to stderr instead of launching the game")
 	flag.Parse()
@@ -265,20 +299,43 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = games.LoadUserSystems(config.UserSystemsFile)
+	if err != nil {
+		fmt.Println("Error loading user systems:", err)
+	}
+
+	_, err = gamesdb.NewSearchFilter(cfg)
+	if err != nil {
+		fmt.Println("Error in search config:", err)
+		os.Exit(1)
+	}
+
+	_, err = searchSystems(cfg)
+	if err != nil {
+		fmt.Println("Error in search config:", err)
+		os.Exit(1)
+	}
+
+	idx, err := index.New(cfg)
+	if err != nil {
+		fmt.Println("Error in gamesdb config:", err)
+		os.Exit(1)
+	}
+
 	stdscr, err := curses.Setup()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer gc.End()
 
-	if !gamesdb.DbExists() {
-		err := generateIndexWindow(cfg, stdscr)
+	if !idx.Exists() {
+		err := generateIndexWindow(cfg, idx, stdscr, false)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	err = searchWindow(cfg, stdscr, "", launchGame)
+	err = searchWindow(cfg, idx, stdscr, "", launchGame)
 	if err != nil {
 		log.Fatal(err)
 	}
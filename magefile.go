@@ -522,6 +522,38 @@ func GenSystemsDoc() {
 		md += fmt.Sprintf("| %s | %s |\n", k, strings.Join(syss, ", "))
 	}
 
+	md += "\n## System Groups\n"
+	md += "Systems can also be selected by category or manufacturer, in apps which accept a list of systems, using the group IDs below. Prefix a system or group with `-` to exclude it, for example `@console,-@nintendo` is every console not made by Nintendo.\n\n"
+	md += "| ID | Systems |\n| --- | --- |\n"
+	for _, g := range games.SystemGroups() {
+		var syss []string
+		for _, s := range g.Systems {
+			tocLink := "#" + strings.ReplaceAll(strings.ToLower(s.Name), " ", "-")
+			tocLink = utils.StripChars(tocLink, "()/")
+			syss = append(syss, fmt.Sprintf("[%s](%s)", s.Name, tocLink))
+		}
+		md += fmt.Sprintf("| %s | %s |\n", g.Id, strings.Join(syss, ", "))
+	}
+
+	md += "\n## Custom Systems\n"
+	md += "Systems can be added or changed by creating a `systems.json` file in the `/media/fat/Scripts/.config/mrext` folder. Each entry in the `systems` list either defines a new system (`id`, `name`, `folder`, `rbf` and `slots` are required) or overrides the `name`, `category`, `alias`, `setName`, `folder`, `rbf`, `slots` or `mglParams` fields of an existing system with the same ID. The `groups` object adds or replaces core groups as a list of system IDs. Invalid entries are skipped and reported by each app on startup.\n\n"
+	md += "```json\n{\n    \"systems\": [\n        {\n            \"id\": \"NewCore\",\n            \"name\": \"New Core\",\n            \"category\": \"Console\",\n            \"folder\": [\"NewCore\"],\n            \"rbf\": \"_Console/NewCore\",\n            \"slots\": [\n                {\"exts\": [\".bin\"], \"mgl\": {\"delay\": 1, \"method\": \"f\", \"index\": 1}}\n            ]\n        },\n        {\n            \"id\": \"SNES\",\n            \"folder\": [\"SNES\", \"SFC\"]\n        }\n    ],\n    \"groups\": {\n        \"NewCore\": [\"NewCore\", \"SNES\"]\n    }\n}\n```\n"
+
+	md += "\n## Alternate Cores\n"
+	md += "Alternate core sets, builds of a core for different hardware, are detected for every system. They're recognised by a `LLAPI`, `YC`, `DualSDRAM` or `DualRAM` suffix on the core's name (e.g. `_Console/PSX_LLAPI_20230101.rbf`), or by being in a top level folder of the same name (e.g. `_LLAPI/PSX_20230101.rbf`). A system is treated as installed if any of its cores are.\n\n"
+	md += "The main core is launched if it's installed, otherwise the first alternate core found. To always prefer a variant for a system, add a `core_variant` option to the `[systems]` section of an app's .ini file, once per system:\n\n"
+	md += "```ini\n[systems]\ncore_variant = PSX:LLAPI\ncore_variant = SNES:YC\n```\n\n"
+	md += "The `set_core` option, which sets the core of a system directly as `system:rbf`, takes priority over this.\n"
+
+	md += "\n## Core Rules\n"
+	md += "Individual games can be launched with a different core than the rest of their system by creating a `cores.json` file in the `/media/fat/Scripts/.config/mrext` folder. This is useful for games which need a specific core build, like a dual SDRAM or LLAPI version. Rules take priority over the `core_variant` and `set_core` options.\n\n"
+	md += "Each rule matches games with at least one of these fields, and a game must match all the fields given:\n\n"
+	md += "- `path`: the exact path of a single game.\n- `glob`: a glob pattern matched against the full path, or just the filename if it contains no `/`.\n- `name`: the filename without its extension, ignoring case.\n- `hash`: the CRC32, MD5 or SHA1 of the game file. Games are hashed when they're launched, so this is best kept to systems with small files.\n- `system`: optional, only match games of this system ID.\n\n"
+	md += "And then changes at least one of these:\n\n"
+	md += "- `rbf`: the core to launch, the same as the `rbf` field of a system.\n- `setName` and `setNameSameDir`: the setname of the core.\n- `mgl`: the `delay`, `method` and `index` used to load the game file.\n\n"
+	md += "The first rule which matches a game is used. Invalid rules are skipped. Rules for a single game can also be pinned from Remote.\n\n"
+	md += "```json\n{\n    \"rules\": [\n        {\"system\": \"N64\", \"glob\": \"*(Japan)*\", \"rbf\": \"_Console/N64_DualSDRAM\"},\n        {\"system\": \"PSX\", \"name\": \"Crash Bandicoot (USA)\", \"rbf\": \"_LLAPI/PSX_LLAPI\"},\n        {\"system\": \"Genesis\", \"hash\": \"f4e5a6b7\", \"mgl\": {\"delay\": 1, \"method\": \"f\", \"index\": 1}}\n    ]\n}\n```\n"
+
 	for _, s := range systems {
 		md += fmt.Sprintln("\n##", s.Name)
 
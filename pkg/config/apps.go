@@ -5,6 +5,7 @@ const UserAppPathEnv = "MREXT_APP_PATH"
 
 const ActiveGameFile = TempFolder + "/ACTIVEGAME"
 const SearchDbFile = SdFolder + "/search.db"
+const SearchSqliteFile = SdFolder + "/search.sqlite"
 const PlayLogDbFile = SdFolder + "/playlog.db"
 
 const PidFileTemplate = TempFolder + "/%s.pid"
@@ -21,4 +22,11 @@ const NfcLastScanFile = TempFolder + "/NFCSCAN"
 
 const GamesDb = ScriptsConfigFolder + "/mrext/games.db"
 
+const UserSystemsFile = MrextConfigFolder + "/systems.json"
+const CoreRulesFile = MrextConfigFolder + "/cores.json"
+const DatsFolder = MrextConfigFolder + "/dats"
+const MetadataFolder = MrextConfigFolder + "/metadata"
+const MediaFolder = MrextConfigFolder + "/media"
+const ThumbnailsFolder = MrextConfigFolder + "/thumbnails"
+
 const LastLaunchFile = SdFolder + "/.LASTLAUNCH.mgl"
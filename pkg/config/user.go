@@ -22,6 +22,12 @@ type RandomConfig struct{}
 type SearchConfig struct {
 	Filter []string `ini:"filter,omitempty" delim:","`
 	Sort   string   `ini:"sort,omitempty"`
+	// regions and language codes in order of preference, used to filter
+	// search results and when picking between different releases of the
+	// same game
+	Regions []string `ini:"regions,omitempty" delim:","`
+	// systems or groups to search, see games.LookupSystems
+	Systems string `ini:"systems,omitempty"`
 }
 
 type LastPlayedConfig struct {
@@ -37,6 +43,7 @@ type RemoteConfig struct {
 	SyncSSHKeys     bool   `ini:"sync_ssh_keys,omitempty"`
 	CustomLogo      string `ini:"custom_logo,omitempty"`
 	AnnounceGameUrl string `ini:"announce_game_url,omitempty"`
+	WatchGames      bool   `ini:"watch_games,omitempty"`
 }
 
 type NfcConfig struct {
@@ -49,6 +56,22 @@ type NfcConfig struct {
 type SystemsConfig struct {
 	GamesFolder []string `ini:"games_folder,omitempty,allowshadow"`
 	SetCore     []string `ini:"set_core,omitempty,allowshadow"`
+	// system:variant, the alternate core set to launch a system with if
+	// it's installed (LLAPI, YC, DualSDRAM or DualRAM)
+	CoreVariant []string `ini:"core_variant,omitempty,allowshadow"`
+}
+
+type GamesDbConfig struct {
+	// gamesdb (default), txt or sqlite
+	Backend   string `ini:"backend,omitempty"`
+	HashFiles bool   `ini:"hash_files,omitempty"`
+	DatFolder string `ini:"dat_folder,omitempty"`
+	// metadata files in the root apply to all systems, and files in a
+	// subfolder named after a system ID only apply to that system
+	MetadataFolder string `ini:"metadata_folder,omitempty"`
+	// same layout as the metadata folder, media is also found in the games
+	// folders of each system
+	MediaFolder string `ini:"media_folder,omitempty"`
 }
 
 type UserConfig struct {
@@ -62,6 +85,7 @@ type UserConfig struct {
 	Remote     RemoteConfig     `ini:"remote,omitempty"`
 	Nfc        NfcConfig        `ini:"nfc,omitempty"`
 	Systems    SystemsConfig    `ini:"systems,omitempty"`
+	GamesDb    GamesDbConfig    `ini:"gamesdb,omitempty"`
 }
 
 func LoadUserConfig(name string, defaultConfig *UserConfig) (*UserConfig, error) {
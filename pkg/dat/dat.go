@@ -0,0 +1,172 @@
+// Package dat reads Logiqx XML DAT files, as used by No-Intro, Redump and
+// MAME, and matches game files against them.
+package dat
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+type Header struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	Version     string `xml:"version"`
+}
+
+type Rom struct {
+	Name string `xml:"name,attr"`
+	Size int64  `xml:"size,attr"`
+	CRC  string `xml:"crc,attr"`
+	MD5  string `xml:"md5,attr"`
+	SHA1 string `xml:"sha1,attr"`
+}
+
+type Game struct {
+	Name        string `xml:"name,attr"`
+	CloneOf     string `xml:"cloneof,attr"`
+	Description string `xml:"description"`
+	Year        string `xml:"year"`
+	Roms        []Rom  `xml:"rom"`
+}
+
+// Title returns the full canonical name of the game. MAME DATs use short
+// set names, so the description is preferred if there is one.
+func (g *Game) Title() string {
+	if g.Description != "" {
+		return g.Description
+	}
+	return g.Name
+}
+
+type Datafile struct {
+	Header Header `xml:"header"`
+	Games  []Game `xml:"game"`
+	// MAME DATs use machine elements instead of game
+	Machines []Game `xml:"machine"`
+}
+
+// Read parses a single DAT file.
+func Read(path string) (*Datafile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var df Datafile
+	err = xml.NewDecoder(f).Decode(&df)
+	if err != nil {
+		return nil, err
+	}
+
+	df.Games = append(df.Games, df.Machines...)
+	df.Machines = nil
+
+	return &df, nil
+}
+
+// ReadFolder parses every .dat and .xml file in a folder. Files which can't
+// be parsed are skipped.
+func ReadFolder(folder string) ([]*Datafile, error) {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	var dats []*Datafile
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".dat" && ext != ".xml") {
+			continue
+		}
+
+		df, err := Read(filepath.Join(folder, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		dats = append(dats, df)
+	}
+
+	return dats, nil
+}
+
+// Index allows looking up DAT games by ROM checksum or filename.
+type Index struct {
+	byCRC   map[string]*Game
+	bySHA1  map[string]*Game
+	byMD5   map[string]*Game
+	byName  map[string]*Game
+	entries int
+}
+
+func nameKey(name string) string {
+	return strings.ToLower(utils.RemoveFileExt(filepath.Base(name)))
+}
+
+// NewIndex creates a lookup index of all games in the given DATs.
+func NewIndex(dats []*Datafile) *Index {
+	idx := &Index{
+		byCRC:  make(map[string]*Game),
+		bySHA1: make(map[string]*Game),
+		byMD5:  make(map[string]*Game),
+		byName: make(map[string]*Game),
+	}
+
+	for _, df := range dats {
+		for i := range df.Games {
+			game := &df.Games[i]
+			idx.entries++
+
+			idx.byName[strings.ToLower(game.Name)] = game
+
+			for _, rom := range game.Roms {
+				if rom.CRC != "" {
+					idx.byCRC[strings.ToLower(rom.CRC)] = game
+				}
+				if rom.SHA1 != "" {
+					idx.bySHA1[strings.ToLower(rom.SHA1)] = game
+				}
+				if rom.MD5 != "" {
+					idx.byMD5[strings.ToLower(rom.MD5)] = game
+				}
+				if _, ok := idx.byName[nameKey(rom.Name)]; !ok {
+					idx.byName[nameKey(rom.Name)] = game
+				}
+			}
+		}
+	}
+
+	return idx
+}
+
+// Len returns the number of games in the index.
+func (idx *Index) Len() int {
+	return idx.entries
+}
+
+// MatchHash returns the game containing a ROM with the given checksums.
+// SHA1 is checked first, then MD5 and CRC32. Blank checksums are ignored.
+func (idx *Index) MatchHash(crc string, md5 string, sha1 string) (*Game, bool) {
+	if g, ok := idx.bySHA1[strings.ToLower(sha1)]; ok && sha1 != "" {
+		return g, true
+	}
+	if g, ok := idx.byMD5[strings.ToLower(md5)]; ok && md5 != "" {
+		return g, true
+	}
+	if g, ok := idx.byCRC[strings.ToLower(crc)]; ok && crc != "" {
+		return g, true
+	}
+	return nil, false
+}
+
+// MatchName returns the game with a name or ROM filename matching the given
+// path, ignoring case and file extension.
+func (idx *Index) MatchName(path string) (*Game, bool) {
+	g, ok := idx.byName[nameKey(path)]
+	return g, ok
+}
@@ -0,0 +1,97 @@
+package games
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultPriority is the region and language preference used when picking
+// between versions of a game and none has been configured.
+var DefaultPriority = []string{"USA", "Europe", "Japan", "En"}
+
+// Disc and side numbers, which are removed from a filename to find the
+// release the disc belongs to.
+var discRe = regexp.MustCompile(`(?i)\(((disc|disk|side|cd) [^)]+)\)`)
+
+// DedupeKey returns the key used to group different releases of the same
+// game, based on the title in its filename. Every disc of a multi-disc game
+// has the same key.
+func DedupeKey(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	return strings.ToLower(strings.Join(strings.Fields(ParseTags(name).Title), " "))
+}
+
+// A single release of a game and each of its discs.
+type dedupeRelease struct {
+	tags  Tags
+	files []string
+	discs map[string]struct{}
+}
+
+// Dedupe groups a list of game files by title and returns only the best
+// release in each group, see BetterVersion. All discs of the picked release
+// are kept. The preferred list is an ordered list of regions and language
+// codes, DefaultPriority is used if it's empty. The files of each picked
+// release take the position of the first release of their game.
+func Dedupe(files []string, preferred []string) []string {
+	if len(preferred) == 0 {
+		preferred = DefaultPriority
+	}
+
+	var order []string
+	groups := make(map[string][]*dedupeRelease)
+	releases := make(map[string]*dedupeRelease)
+
+	for _, file := range files {
+		key := DedupeKey(file)
+		name := filepath.Base(file)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+
+		var disc []string
+		for _, m := range discRe.FindAllStringSubmatch(name, -1) {
+			disc = append(disc, strings.ToLower(m[1]))
+		}
+		discKey := strings.Join(disc, "|")
+
+		// the same for every disc of a release
+		releaseKey := strings.ToLower(strings.Join(strings.Fields(discRe.ReplaceAllString(name, "")), " "))
+
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+
+		r, ok := releases[releaseKey]
+		if !ok {
+			r = &dedupeRelease{
+				tags:  ParseTags(name),
+				discs: make(map[string]struct{}),
+			}
+			releases[releaseKey] = r
+			groups[key] = append(groups[key], r)
+		}
+
+		// copies of the same disc in other folders are skipped
+		if _, ok := r.discs[discKey]; ok {
+			continue
+		}
+		r.discs[discKey] = struct{}{}
+		r.files = append(r.files, file)
+	}
+
+	var picked []string
+	for _, key := range order {
+		best := groups[key][0]
+		for _, r := range groups[key][1:] {
+			if BetterVersion(r.tags, best.tags, preferred) {
+				best = r
+			}
+		}
+
+		picked = append(picked, best.files...)
+	}
+
+	return picked
+}
@@ -0,0 +1,35 @@
+package games
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	files := []string{
+		"/media/fat/games/SNES/Super Metroid (Japan, USA) (En,Ja).sfc",
+		"/media/fat/games/SNES/Super Metroid (Europe) (En,Fr,De).sfc",
+		"/media/fat/games/SNES/Tetris (Japan).sfc",
+		"/media/fat/games/SNES/Tetris (Europe).sfc",
+		"/media/fat/games/SNES/Tetris (Europe) (Beta).sfc",
+		"/media/fat/games/SNES/Other/Tetris (Europe).sfc",
+		"/media/fat/games/PSX/Final Fantasy VII (USA) (Disc 1).chd",
+		"/media/fat/games/PSX/Final Fantasy VII (USA) (Disc 2).chd",
+		"/media/fat/games/PSX/Final Fantasy VII (Europe) (Disc 1).chd",
+		"/media/fat/games/PSX/Policenauts (Japan) (Disc 1).chd",
+		"/media/fat/games/PSX/Policenauts (Japan) (Disc 2).chd",
+	}
+
+	want := []string{
+		"/media/fat/games/SNES/Super Metroid (Europe) (En,Fr,De).sfc",
+		"/media/fat/games/SNES/Tetris (Europe).sfc",
+		"/media/fat/games/PSX/Final Fantasy VII (Europe) (Disc 1).chd",
+		"/media/fat/games/PSX/Policenauts (Japan) (Disc 1).chd",
+		"/media/fat/games/PSX/Policenauts (Japan) (Disc 2).chd",
+	}
+
+	got := Dedupe(files, []string{"Europe", "USA"})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Dedupe() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+package games
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Systems with games which can span several CD images. Each disc set is
+// indexed as one game using its first disc.
+var discSystems = map[string]struct{}{
+	"PSX":            {},
+	"Saturn":         {},
+	"MegaCD":         {},
+	"TurboGrafx16CD": {},
+	"NeoGeoCD":       {},
+}
+
+var discNumRe = regexp.MustCompile(`(?i)\s*\((?:disc|disk|cd) (\d+)(?: of \d+)?\)`)
+
+// MultiDiscSystem returns true if games for the system can span several
+// discs.
+func MultiDiscSystem(systemId string) bool {
+	_, ok := discSystems[systemId]
+	return ok
+}
+
+// DiscNumber returns the disc number in a game's filename, such as
+// "(Disc 2)", or 0 if it doesn't have one.
+func DiscNumber(path string) int {
+	m := discNumRe.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return 0
+	}
+
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// IsExtraDisc returns true if the file is a disc after the first in a
+// multi-disc game, and shouldn't be listed as a game on its own.
+func IsExtraDisc(systemId string, path string) bool {
+	return MultiDiscSystem(systemId) && DiscNumber(path) > 1
+}
+
+// Return the key of the disc set a file belongs to, which is its path with
+// the disc number removed.
+func discSetKey(path string) string {
+	return strings.ToLower(filepath.Join(filepath.Dir(path), discNumRe.ReplaceAllString(filepath.Base(path), "")))
+}
+
+// ReadM3u returns the list of discs in an M3U playlist. Relative paths are
+// resolved from the folder of the playlist.
+func ReadM3u(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var discs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = filepath.FromSlash(strings.ReplaceAll(line, "\\", "/"))
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+
+		discs = append(discs, line)
+	}
+
+	return discs, scanner.Err()
+}
+
+func isM3u(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".m3u")
+}
+
+// GroupDiscs removes all but the first disc of each multi-disc game from a
+// list of files. Discs are grouped by any M3U playlists in the list, which
+// are also removed, and then by "(Disc N)" in their filenames. If the first
+// disc is missing, the lowest numbered one is kept.
+func GroupDiscs(files []string) []string {
+	extra := make(map[string]struct{})
+
+	for _, file := range files {
+		if !isM3u(file) {
+			continue
+		}
+
+		discs, err := ReadM3u(file)
+		if err != nil {
+			continue
+		}
+
+		for i := 1; i < len(discs); i++ {
+			extra[discs[i]] = struct{}{}
+		}
+	}
+
+	first := make(map[string]string)
+	for _, file := range files {
+		if isM3u(file) || DiscNumber(file) == 0 {
+			continue
+		}
+		if _, ok := extra[file]; ok {
+			continue
+		}
+
+		key := discSetKey(file)
+		if f, ok := first[key]; !ok || DiscNumber(file) < DiscNumber(f) {
+			first[key] = file
+		}
+	}
+
+	var grouped []string
+	for _, file := range files {
+		if isM3u(file) {
+			continue
+		} else if _, ok := extra[file]; ok {
+			continue
+		} else if DiscNumber(file) > 0 && first[discSetKey(file)] != file {
+			continue
+		}
+
+		grouped = append(grouped, file)
+	}
+
+	return grouped
+}
+
+// DiscSet returns every disc of the multi-disc game a file belongs to, in
+// order. M3U playlists in the same folder are checked first, then files
+// with the same name and a different disc number. If the file isn't part of
+// a set, it's returned on its own.
+func DiscSet(path string) []string {
+	files, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return []string{path}
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !isM3u(file.Name()) {
+			continue
+		}
+
+		discs, err := ReadM3u(filepath.Join(filepath.Dir(path), file.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, disc := range discs {
+			if disc == path {
+				return discs
+			}
+		}
+	}
+
+	if DiscNumber(path) == 0 {
+		return []string{path}
+	}
+
+	var discs []string
+	key := discSetKey(path)
+	for _, file := range files {
+		if file.IsDir() || DiscNumber(file.Name()) == 0 {
+			continue
+		}
+
+		disc := filepath.Join(filepath.Dir(path), file.Name())
+		if discSetKey(disc) == key {
+			discs = append(discs, disc)
+		}
+	}
+
+	sort.SliceStable(discs, func(i, j int) bool {
+		return DiscNumber(discs[i]) < DiscNumber(discs[j])
+	})
+
+	return discs
+}
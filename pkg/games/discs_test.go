@@ -0,0 +1,39 @@
+package games
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGroupDiscs(t *testing.T) {
+	dir := t.TempDir()
+
+	playlist := filepath.Join(dir, "Metal Gear Solid (USA).m3u")
+	err := os.WriteFile(playlist, []byte("#EXTM3U\nMGS1.chd\nMGS2.chd\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []string{
+		filepath.Join(dir, "Final Fantasy VII (USA) (Disc 2).chd"),
+		filepath.Join(dir, "Final Fantasy VII (USA) (Disc 1).chd"),
+		filepath.Join(dir, "Final Fantasy VII (USA) (Disc 3).chd"),
+		filepath.Join(dir, "MGS1.chd"),
+		filepath.Join(dir, "MGS2.chd"),
+		playlist,
+		filepath.Join(dir, "Tekken 3 (USA).chd"),
+	}
+
+	want := []string{
+		filepath.Join(dir, "Final Fantasy VII (USA) (Disc 1).chd"),
+		filepath.Join(dir, "MGS1.chd"),
+		filepath.Join(dir, "Tekken 3 (USA).chd"),
+	}
+
+	got := GroupDiscs(files)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupDiscs() = %v, want %v", got, want)
+	}
+}
@@ -2,11 +2,12 @@ package games
 
 import (
 	"fmt"
-	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/wizzomafizzo/mrext/pkg/config"
 	"github.com/wizzomafizzo/mrext/pkg/utils"
@@ -92,32 +93,64 @@ func AllSystems() []System {
 	return systems
 }
 
-type resultsStack [][]string
-
-func (r *resultsStack) new() {
-	*r = append(*r, []string{})
+// DirLister reads the contents of folders and zip files while searching for
+// games. Implementations may cache listings between searches.
+type DirLister interface {
+	// ReadFolder returns the names of the files and sub folders in a folder.
+	// Symlinks are listed as whatever they point to.
+	ReadFolder(path string) (files []string, dirs []string, err error)
+	// ReadZip returns the paths of all files inside a zip file.
+	ReadZip(path string) ([]string, error)
 }
 
-func (r *resultsStack) pop() {
-	if len(*r) == 0 {
-		return
+// DiskLister lists folders and zips directly from disk.
+type DiskLister struct{}
+
+func (DiskLister) ReadFolder(path string) ([]string, []string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, nil, err
 	}
-	*r = (*r)[:len(*r)-1]
-}
 
-func (r *resultsStack) get() (*[]string, error) {
-	if len(*r) == 0 {
-		return nil, fmt.Errorf("nothing on stack")
+	var files, dirs []string
+	for _, entry := range entries {
+		isDir := entry.IsDir()
+
+		if entry.Type()&os.ModeSymlink != 0 {
+			target, err := os.Stat(filepath.Join(path, entry.Name()))
+			if err != nil {
+				// broken symlink
+				continue
+			}
+			isDir = target.IsDir()
+		}
+
+		if isDir {
+			dirs = append(dirs, entry.Name())
+		} else {
+			files = append(files, entry.Name())
+		}
 	}
-	return &(*r)[len(*r)-1], nil
+
+	return files, dirs, nil
+}
+
+func (DiskLister) ReadZip(path string) ([]string, error) {
+	return utils.ListZip(path)
 }
 
 // GetFiles searches for all valid games in a given path and return a list of
 // files. This function deep searches .zip files and handles symlinks at all
-// levels.
+// levels. Multi-disc games are only listed by their first disc, see
+// GroupDiscs.
 func GetFiles(systemId string, path string) ([]string, error) {
-	var allResults []string
-	var stack resultsStack
+	return GetFilesWith(DiskLister{}, systemId, path)
+}
+
+// GetFilesWith works the same as GetFiles, but reads folders and zip files
+// using the given lister.
+func GetFilesWith(lister DirLister, systemId string, path string) ([]string, error) {
+	var results []string
 	visited := make(map[string]struct{})
 
 	system, err := GetSystem(systemId)
@@ -125,153 +158,70 @@ func GetFiles(systemId string, path string) ([]string, error) {
 		return nil, err
 	}
 
-	cwd, err := os.Getwd()
+	root, err := os.Stat(path)
 	if err != nil {
 		return nil, err
+	} else if !root.IsDir() {
+		return nil, fmt.Errorf("root is not a directory")
 	}
 
-	var scanner func(path string, file fs.DirEntry, err error) error
-	scanner = func(path string, file fs.DirEntry, _ error) error {
+	var walk func(path string) error
+	walk = func(path string) error {
 		// avoid recursive symlinks
-		if file.IsDir() {
-			if _, ok := visited[path]; ok {
-				return filepath.SkipDir
-			} else {
-				visited[path] = struct{}{}
-			}
+		realPath, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
 		}
-
-		// handle symlinked directories
-		if file.Type()&os.ModeSymlink != 0 {
-			err = os.Chdir(filepath.Dir(path))
-			if err != nil {
-				return err
-			}
-
-			realPath, err := filepath.EvalSymlinks(path)
-			if err != nil {
-				return err
-			}
-
-			file, err := os.Stat(realPath)
-			if err != nil {
-				return err
-			}
-
-			if file.IsDir() {
-				err = os.Chdir(path)
-				if err != nil {
-					return err
-				}
-
-				stack.new()
-				defer stack.pop()
-
-				err = filepath.WalkDir(realPath, scanner)
-				if err != nil {
-					return err
-				}
-
-				results, err := stack.get()
-				if err != nil {
-					return err
-				}
-
-				for i := range *results {
-					allResults = append(allResults, strings.Replace((*results)[i], realPath, path, 1))
-				}
-
-				return nil
-			}
+		if _, ok := visited[realPath]; ok {
+			return nil
 		}
+		visited[realPath] = struct{}{}
 
-		results, err := stack.get()
+		files, dirs, err := lister.ReadFolder(path)
 		if err != nil {
 			return err
 		}
 
-		if strings.HasSuffix(strings.ToLower(path), ".zip") {
-			// zip files
-			zipFiles, err := utils.ListZip(path)
-			if err != nil {
-				// skip invalid zip files
-				return nil
-			}
+		for _, name := range files {
+			file := filepath.Join(path, name)
 
-			for i := range zipFiles {
-				if MatchSystemFile(*system, zipFiles[i]) {
-					abs := filepath.Join(path, zipFiles[i])
-					*results = append(*results, abs)
+			if utils.IsZip(file) {
+				zipFiles, err := lister.ReadZip(file)
+				if err != nil {
+					// skip invalid zip files
+					continue
 				}
-			}
-		} else {
-			// regular files
-			if MatchSystemFile(*system, path) {
-				*results = append(*results, path)
+
+				for i := range zipFiles {
+					if MatchSystemFile(*system, zipFiles[i]) {
+						results = append(results, filepath.Join(file, zipFiles[i]))
+					}
+				}
+			} else if MatchSystemFile(*system, file) || (MultiDiscSystem(systemId) && isM3u(file)) {
+				// regular files, and playlists of multi-disc games to group
+				// their discs once everything is found
+				results = append(results, file)
 			}
 		}
 
-		return nil
-	}
-
-	stack.new()
-	defer stack.pop()
-
-	root, err := os.Lstat(path)
-	if err != nil {
-		return nil, err
-	}
-
-	err = os.Chdir(filepath.Dir(path))
-	if err != nil {
-		return nil, err
-	}
-
-	// handle symlinks on root game folder because WalkDir fails silently on them
-	var realPath string
-	if root.Mode()&os.ModeSymlink == 0 {
-		realPath = path
-	} else {
-		realPath, err = filepath.EvalSymlinks(path)
-		if err != nil {
-			return nil, err
+		for _, name := range dirs {
+			// unreadable sub folders are skipped, same as missing ones
+			_ = walk(filepath.Join(path, name))
 		}
-	}
-
-	realRoot, err := os.Stat(realPath)
-	if err != nil {
-		return nil, err
-	}
-
-	if !realRoot.IsDir() {
-		return nil, fmt.Errorf("root is not a directory")
-	}
 
-	err = filepath.WalkDir(realPath, scanner)
-	if err != nil {
-		return nil, err
+		return nil
 	}
 
-	results, err := stack.get()
+	err = walk(path)
 	if err != nil {
 		return nil, err
 	}
 
-	allResults = append(allResults, *results...)
-
-	// change root back to symlink
-	if realPath != path {
-		for i := range allResults {
-			allResults[i] = strings.Replace(allResults[i], realPath, path, 1)
-		}
-	}
-
-	err = os.Chdir(cwd)
-	if err != nil {
-		return nil, err
+	if MultiDiscSystem(systemId) {
+		results = GroupDiscs(results)
 	}
 
-	return allResults, nil
+	return results, nil
 }
 
 func GetAllFiles(systemPaths map[string][]string, statusFn func(systemId string, path string)) ([][2]string, error) {
@@ -343,18 +293,32 @@ type RbfInfo struct {
 	Filename  string // base filename of RBF file
 	ShortName string // base filename without date or extension
 	MglName   string // relative path launch-able from MGL file
+	Variant   string // alternate core set, blank for the main core
 }
 
+// ParseRbf reads the name of a core from the path of its RBF file. Anything
+// after the last underscore of the filename, usually the build date, is
+// removed unless it's the name of an alternate core set.
 func ParseRbf(path string) RbfInfo {
 	info := RbfInfo{
 		Path:     path,
 		Filename: filepath.Base(path),
 	}
 
-	if strings.Contains(info.Filename, "_") {
-		info.ShortName = info.Filename[0:strings.LastIndex(info.Filename, "_")]
-	} else {
-		info.ShortName = strings.TrimSuffix(info.Filename, filepath.Ext(info.Filename))
+	info.ShortName = strings.TrimSuffix(info.Filename, filepath.Ext(info.Filename))
+	if i := strings.LastIndex(info.ShortName, "_"); i >= 0 {
+		suffix := info.ShortName[i+1:]
+		variant := false
+		for _, v := range CoreVariants {
+			if strings.EqualFold(suffix, v) {
+				variant = true
+				break
+			}
+		}
+
+		if !variant {
+			info.ShortName = info.ShortName[:i]
+		}
 	}
 
 	if strings.HasPrefix(path, config.SdFolder) {
@@ -367,10 +331,17 @@ func ParseRbf(path string) RbfInfo {
 	return info
 }
 
-// Find all rbf files in the top 2 menu levels of the SD card.
-func shallowScanRbf() ([]RbfInfo, error) {
+// Find all rbf files in the top 2 menu levels of the SD card. The mtimes of
+// the scanned folders are stored in mtimes.
+func shallowScanRbf(mtimes map[string]time.Time) ([]RbfInfo, error) {
 	results := make([]RbfInfo, 0)
 
+	stat := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			mtimes[path] = info.ModTime()
+		}
+	}
+
 	isRbf := func(file os.DirEntry) bool {
 		return filepath.Ext(strings.ToLower(file.Name())) == ".rbf"
 	}
@@ -393,6 +364,7 @@ func shallowScanRbf() ([]RbfInfo, error) {
 		}
 	}
 
+	stat(config.SdFolder)
 	files, err := os.ReadDir(config.SdFolder)
 	if err != nil {
 		return results, err
@@ -400,6 +372,7 @@ func shallowScanRbf() ([]RbfInfo, error) {
 
 	for _, file := range files {
 		if file.IsDir() && strings.HasPrefix(file.Name(), "_") {
+			stat(filepath.Join(config.SdFolder, file.Name()))
 			subFiles, err := os.ReadDir(filepath.Join(config.SdFolder, file.Name()))
 			if err != nil {
 				continue
@@ -428,29 +401,154 @@ func shallowScanRbf() ([]RbfInfo, error) {
 	return results, nil
 }
 
-// SystemsWithRbf returns a map of all system IDs which have an existing rbf file.
-func SystemsWithRbf() map[string]RbfInfo {
-	// TODO: include alt rbfs somehow?
-	results := make(map[string]RbfInfo)
+// Result of the last RBF scan, which is reused until one of the scanned
+// folders changes.
+var rbfCache struct {
+	sync.Mutex
+	rbfs   []RbfInfo
+	mtimes map[string]time.Time
+}
+
+// Return all rbf files in the top 2 menu levels of the SD card, only scanning
+// the folders again if a core has been added or removed since the last scan.
+func scanRbf() ([]RbfInfo, error) {
+	rbfCache.Lock()
+	defer rbfCache.Unlock()
+
+	valid := rbfCache.mtimes != nil
+	for path, mtime := range rbfCache.mtimes {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(mtime) {
+			valid = false
+			break
+		}
+	}
+
+	if valid {
+		return rbfCache.rbfs, nil
+	}
+
+	mtimes := make(map[string]time.Time)
+	rbfs, err := shallowScanRbf(mtimes)
+	if err != nil {
+		return rbfs, err
+	}
+
+	rbfCache.rbfs = rbfs
+	rbfCache.mtimes = mtimes
+
+	return rbfs, nil
+}
+
+// Alternate core sets, which are builds of a core for different hardware.
+// They're installed with a suffix on the core's name, or in a top level
+// folder of the same name with an underscore prefix.
+var CoreVariants = []string{"LLAPI", "YC", "DualSDRAM", "DualRAM"}
+
+// Return the variant of a system's core an RBF is, or false if it's not one
+// of the system's cores.
+func rbfVariant(system System, rbf RbfInfo) (string, bool) {
+	if system.Rbf == "" {
+		return "", false
+	}
+
+	shortName := system.Rbf
+	if strings.Contains(shortName, "/") {
+		shortName = shortName[strings.LastIndex(shortName, "/")+1:]
+	}
+
+	folder := filepath.Base(filepath.Dir(rbf.Path))
+
+	if strings.EqualFold(rbf.ShortName, shortName) {
+		for _, v := range CoreVariants {
+			if strings.EqualFold(folder, "_"+v) {
+				return v, true
+			}
+		}
+		return "", true
+	}
+
+	if len(rbf.ShortName) > len(shortName) && strings.EqualFold(rbf.ShortName[:len(shortName)+1], shortName+"_") {
+		suffix := rbf.ShortName[len(shortName)+1:]
+		for _, v := range CoreVariants {
+			if strings.EqualFold(suffix, v) {
+				return v, true
+			}
+		}
+	}
 
-	rbfFiles, err := shallowScanRbf()
+	return "", false
+}
+
+// SystemRbfs returns every installed core of each system ID, including
+// alternate core sets. The main core is first if it's installed.
+func SystemRbfs() map[string][]RbfInfo {
+	results := make(map[string][]RbfInfo)
+
+	rbfFiles, err := scanRbf()
 	if err != nil {
 		return results
 	}
 
 	for _, rbfFile := range rbfFiles {
 		for _, system := range Systems {
-			shortName := system.Rbf
-
-			if strings.Contains(shortName, "/") {
-				shortName = shortName[strings.LastIndex(shortName, "/")+1:]
+			variant, ok := rbfVariant(system, rbfFile)
+			if !ok {
+				continue
 			}
 
-			if strings.EqualFold(rbfFile.ShortName, shortName) {
-				results[system.Id] = rbfFile
+			rbfFile.Variant = variant
+			if variant == "" {
+				results[system.Id] = append([]RbfInfo{rbfFile}, results[system.Id]...)
+			} else {
+				results[system.Id] = append(results[system.Id], rbfFile)
 			}
 		}
 	}
 
 	return results
 }
+
+// SystemsWithRbf returns a map of all system IDs which have an existing rbf
+// file. The main core is returned if it's installed, otherwise the first
+// alternate core found.
+func SystemsWithRbf() map[string]RbfInfo {
+	results := make(map[string]RbfInfo)
+
+	for id, rbfs := range SystemRbfs() {
+		results[id] = rbfs[0]
+	}
+
+	return results
+}
+
+// PickRbf returns the core to launch a system with from a list of its
+// installed cores, see PreferredRbf.
+func PickRbf(cfg *config.UserConfig, systemId string, rbfs []RbfInfo) (RbfInfo, bool) {
+	if len(rbfs) == 0 {
+		return RbfInfo{}, false
+	}
+
+	for _, pref := range cfg.Systems.CoreVariant {
+		parts := strings.SplitN(pref, ":", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], systemId) {
+			continue
+		}
+
+		for _, rbf := range rbfs {
+			if strings.EqualFold(rbf.Variant, parts[1]) {
+				return rbf, true
+			}
+		}
+	}
+
+	return rbfs[0], true
+}
+
+// PreferredRbf returns the installed core to launch a system with. The
+// variant set for the system with the core_variant option is used if it's
+// installed, then the main core, then any alternate core. Returns false if
+// none are installed.
+func PreferredRbf(cfg *config.UserConfig, system System) (RbfInfo, bool) {
+	return PickRbf(cfg, system.Id, SystemRbfs()[system.Id])
+}
@@ -0,0 +1,50 @@
+package games
+
+import "testing"
+
+func TestRbfVariant(t *testing.T) {
+	psx := System{Id: "PSX", Rbf: "_Console/PSX"}
+
+	tests := []struct {
+		path    string
+		variant string
+		ok      bool
+	}{
+		{"/media/fat/_Console/PSX_20230101.rbf", "", true},
+		{"/media/fat/_Console/PSX.rbf", "", true},
+		{"/media/fat/_LLAPI/PSX_LLAPI_20230101.rbf", "LLAPI", true},
+		{"/media/fat/_LLAPI/PSX_20230101.rbf", "LLAPI", true},
+		{"/media/fat/_Console/PSX_DualSDRAM.rbf", "DualSDRAM", true},
+		{"/media/fat/_Console/PSX_Other_20230101.rbf", "", false},
+		{"/media/fat/_Console/PSXtra_20230101.rbf", "", false},
+	}
+
+	for _, tt := range tests {
+		variant, ok := rbfVariant(psx, ParseRbf(tt.path))
+		if variant != tt.variant || ok != tt.ok {
+			t.Errorf("rbfVariant(%s) = %q, %v, want %q, %v", tt.path, variant, ok, tt.variant, tt.ok)
+		}
+	}
+}
+
+func TestParseRbf(t *testing.T) {
+	tests := []struct {
+		path      string
+		shortName string
+		mglName   string
+	}{
+		{"/media/fat/_Console/SNES_20230101.rbf", "SNES", "_Console/SNES"},
+		{"/media/fat/_Console/SNES.rbf", "SNES", "_Console/SNES"},
+		{"/media/fat/_Console/PSX_DualSDRAM.rbf", "PSX_DualSDRAM", "_Console/PSX_DualSDRAM"},
+		{"/media/fat/_LLAPI/PSX_LLAPI_20230101.rbf", "PSX_LLAPI", "_LLAPI/PSX_LLAPI"},
+		{"/media/fat/_Computer/Minimig_unstable.rbf", "Minimig", "_Computer/Minimig"},
+		{"/tmp/cores/NES_20230101.rbf", "NES", "/tmp/cores/NES_20230101.rbf"},
+	}
+
+	for _, tt := range tests {
+		rbf := ParseRbf(tt.path)
+		if rbf.ShortName != tt.shortName || rbf.MglName != tt.mglName {
+			t.Errorf("ParseRbf(%s) = %q, %q, want %q, %q", tt.path, rbf.ShortName, rbf.MglName, tt.shortName, tt.mglName)
+		}
+	}
+}
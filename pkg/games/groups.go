@@ -0,0 +1,148 @@
+package games
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GroupPrefix marks a system lookup term as a category or manufacturer group,
+// e.g. "@handheld" or "@sega".
+const GroupPrefix = "@"
+
+// SystemGroup is a set of systems which share a category or manufacturer.
+type SystemGroup struct {
+	Id      string
+	Name    string
+	Systems []System
+}
+
+// Convert a category or manufacturer name to a group ID, e.g.
+// "Bit Corporation" -> "@bitcorporation".
+func groupId(name string) string {
+	return GroupPrefix + strings.ToLower(strings.ReplaceAll(name, " ", ""))
+}
+
+// SystemGroups returns every category group followed by every manufacturer
+// group, each sorted by name. Systems in a group are sorted by ID.
+func SystemGroups() []SystemGroup {
+	categories := make(map[string]*SystemGroup)
+	manufacturers := make(map[string]*SystemGroup)
+
+	for _, system := range AllSystems() {
+		for _, g := range []struct {
+			groups map[string]*SystemGroup
+			name   string
+		}{
+			{categories, system.Category},
+			{manufacturers, system.Manufacturer},
+		} {
+			if g.name == "" {
+				continue
+			}
+
+			id := groupId(g.name)
+			if _, ok := g.groups[id]; !ok {
+				g.groups[id] = &SystemGroup{Id: id, Name: g.name}
+			}
+			g.groups[id].Systems = append(g.groups[id].Systems, system)
+		}
+	}
+
+	var groups []SystemGroup
+	for _, m := range []map[string]*SystemGroup{categories, manufacturers} {
+		var sorted []SystemGroup
+		for _, g := range m {
+			sorted = append(sorted, *g)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].Name < sorted[j].Name
+		})
+		groups = append(groups, sorted...)
+	}
+
+	return groups
+}
+
+// Return the systems matching a single lookup term, without any exclusion
+// prefix.
+func lookupTerm(term string) ([]System, error) {
+	if strings.EqualFold(term, "all") || strings.EqualFold(term, GroupPrefix+"all") {
+		return AllSystems(), nil
+	}
+
+	if strings.HasPrefix(term, GroupPrefix) {
+		id := groupId(term[len(GroupPrefix):])
+		for _, g := range SystemGroups() {
+			if g.Id == id {
+				return g.Systems, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown system group: %s", term)
+	}
+
+	system, err := LookupSystem(term)
+	if err != nil {
+		return nil, err
+	}
+
+	return []System{*system}, nil
+}
+
+// LookupSystems resolves a comma separated list of system IDs, aliases and
+// groups to a list of systems. Groups are categories or manufacturers
+// prefixed with @ (e.g. "@handheld", "@sega") and "all" selects every system.
+// Terms prefixed with - are removed from the list, so "@console,-@nintendo"
+// is every console not made by Nintendo. If only exclusions are given, they're
+// removed from all systems.
+func LookupSystems(expr string) ([]System, error) {
+	var included []System
+	excluded := make(map[string]struct{})
+	onlyExcludes := true
+
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		exclude := strings.HasPrefix(term, "-")
+		term = strings.TrimPrefix(term, "-")
+
+		systems, err := lookupTerm(term)
+		if err != nil {
+			return nil, err
+		}
+
+		if exclude {
+			for _, system := range systems {
+				excluded[system.Id] = struct{}{}
+			}
+		} else {
+			onlyExcludes = false
+			included = append(included, systems...)
+		}
+	}
+
+	if onlyExcludes {
+		if len(excluded) == 0 {
+			return nil, fmt.Errorf("no systems specified")
+		}
+		included = AllSystems()
+	}
+
+	var systems []System
+	seen := make(map[string]struct{})
+	for _, system := range included {
+		if _, ok := excluded[system.Id]; ok {
+			continue
+		}
+		if _, ok := seen[system.Id]; ok {
+			continue
+		}
+		seen[system.Id] = struct{}{}
+		systems = append(systems, system)
+	}
+
+	return systems, nil
+}
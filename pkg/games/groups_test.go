@@ -0,0 +1,51 @@
+package games
+
+import "testing"
+
+func systemIds(systems []System) map[string]bool {
+	ids := make(map[string]bool)
+	for _, system := range systems {
+		ids[system.Id] = true
+	}
+	return ids
+}
+
+func TestLookupSystems(t *testing.T) {
+	handhelds, err := LookupSystems("@handheld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := systemIds(handhelds)
+	if !ids["GBA"] || ids["SNES"] {
+		t.Errorf("@handheld = %v", ids)
+	}
+
+	consoles, err := LookupSystems("@console,-@nintendo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids = systemIds(consoles)
+	if !ids["Genesis"] || ids["SNES"] || ids["GBA"] {
+		t.Errorf("@console,-@nintendo = %v", ids)
+	}
+
+	excluded, err := LookupSystems("-snes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(excluded) != len(Systems)-1 || systemIds(excluded)["SNES"] {
+		t.Errorf("-snes returned %d systems", len(excluded))
+	}
+
+	listed, err := LookupSystems("nes, @BitCorporation, nes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) < 2 || listed[0].Id != "NES" {
+		t.Errorf("nes,@bitcorporation = %v", systemIds(listed))
+	}
+
+	if _, err := LookupSystems("@nothing"); err == nil {
+		t.Error("expected error for unknown group")
+	}
+}
@@ -116,7 +116,6 @@ func PathToMglDef(system System, path string) (*MglParams, error) {
 }
 
 // FIXME: launch game > launch new game same system > not working? should it?
-// TODO: alternate cores (user core override)
 // TODO: alternate arcade folders
 // TODO: custom scan function
 // TODO: custom launch function
@@ -0,0 +1,284 @@
+package games
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Tags is the information which can be parsed from a game's name when it
+// follows the No-Intro, Redump or GoodTools naming conventions, for example:
+// "Legend of Zelda, The (USA) (En,Fr) (Rev 1) (Beta)" or
+// "Legend of Zelda, The (U) (PRG1) [!]".
+type Tags struct {
+	Title       string
+	Regions     []string
+	Languages   []string
+	Revision    string
+	Beta        bool
+	Proto       bool
+	Hack        bool
+	Demo        bool
+	Unlicensed  bool
+	Translation bool
+	// GoodTools verified good dump [!]
+	Verified bool
+	// GoodTools bad or over dump [b] [o]
+	BadDump bool
+}
+
+var regionNames = []string{
+	"World", "USA", "Europe", "Japan", "Asia", "Australia", "Brazil",
+	"Canada", "China", "Denmark", "Finland", "France", "Germany", "Greece",
+	"Hong Kong", "India", "Ireland", "Israel", "Italy", "Korea", "Mexico",
+	"Netherlands", "New Zealand", "Norway", "Poland", "Portugal", "Russia",
+	"Scandinavia", "South Africa", "Spain", "Sweden", "Switzerland",
+	"Taiwan", "UK", "Unknown",
+}
+
+// GoodTools country codes.
+var goodToolsRegions = map[string][]string{
+	"U":   {"USA"},
+	"E":   {"Europe"},
+	"J":   {"Japan"},
+	"W":   {"World"},
+	"JU":  {"Japan", "USA"},
+	"UE":  {"USA", "Europe"},
+	"JE":  {"Japan", "Europe"},
+	"JUE": {"Japan", "USA", "Europe"},
+	"A":   {"Australia"},
+	"B":   {"Brazil"},
+	"C":   {"China"},
+	"F":   {"France"},
+	"FC":  {"Canada"},
+	"G":   {"Germany"},
+	"GR":  {"Greece"},
+	"HK":  {"Hong Kong"},
+	"I":   {"Italy"},
+	"K":   {"Korea"},
+	"NL":  {"Netherlands"},
+	"S":   {"Spain"},
+	"SW":  {"Sweden"},
+	"UK":  {"UK"},
+	"UNK": {"Unknown"},
+}
+
+var (
+	tagGroupRe    = regexp.MustCompile(`\(([^)]*)\)|\[([^]]*)]`)
+	languageRe    = regexp.MustCompile(`^[A-Z][a-z](-[A-Z][a-z])?$`)
+	revisionRe    = regexp.MustCompile(`^Rev ([0-9A-Z.]+)$`)
+	versionRe     = regexp.MustCompile(`^[vV]([0-9][0-9A-Za-z.]*)$`)
+	prgRe         = regexp.MustCompile(`^PRG ?([0-9]+)$`)
+	badDumpRe     = regexp.MustCompile(`^[bo][0-9]*( |$)`)
+	hackRe        = regexp.MustCompile(`^(h[0-9A-Za-z]*|t[0-9]+)( |$)`)
+	translationRe = regexp.MustCompile(`^T[+-]`)
+	betaRe        = regexp.MustCompile(`^Beta( [0-9]+)?$`)
+	protoRe       = regexp.MustCompile(`^Proto(type)?( [0-9]+)?$`)
+	trailingArtRe = regexp.MustCompile(`^(.+), (The|A|An)$`)
+)
+
+func splitTag(tag string) []string {
+	parts := strings.Split(tag, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func isRegionTag(parts []string) bool {
+	for _, p := range parts {
+		found := false
+		for _, r := range regionNames {
+			if strings.EqualFold(p, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func isLanguageTag(parts []string) bool {
+	for _, p := range parts {
+		if !languageRe.MatchString(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTags reads the title, regions, languages, revision and flags from a
+// game name. Any file extension should be removed first. Tags which aren't
+// recognised are ignored.
+func ParseTags(name string) Tags {
+	var tags Tags
+
+	title := name
+	if idx := strings.IndexAny(name, "(["); idx > 0 {
+		title = name[:idx]
+	}
+	title = strings.TrimSpace(title)
+
+	// "Legend of Zelda, The" -> "The Legend of Zelda"
+	if m := trailingArtRe.FindStringSubmatch(title); m != nil {
+		title = m[2] + " " + m[1]
+	}
+	tags.Title = title
+
+	for _, m := range tagGroupRe.FindAllStringSubmatch(name, -1) {
+		if m[1] == "" {
+			parseBracketTag(&tags, strings.TrimSpace(m[2]))
+			continue
+		}
+
+		tag := strings.TrimSpace(m[1])
+		parts := splitTag(tag)
+
+		switch {
+		case tags.Regions == nil && isRegionTag(parts):
+			tags.Regions = parts
+		case tags.Regions == nil && goodToolsRegions[strings.ToUpper(tag)] != nil:
+			tags.Regions = append([]string(nil), goodToolsRegions[strings.ToUpper(tag)]...)
+		case tags.Languages == nil && isLanguageTag(parts):
+			tags.Languages = parts
+		case revisionRe.MatchString(tag):
+			tags.Revision = revisionRe.FindStringSubmatch(tag)[1]
+		case tags.Revision == "" && versionRe.MatchString(tag):
+			tags.Revision = versionRe.FindStringSubmatch(tag)[1]
+		case prgRe.MatchString(tag):
+			tags.Revision = prgRe.FindStringSubmatch(tag)[1]
+		case betaRe.MatchString(tag):
+			tags.Beta = true
+		case protoRe.MatchString(tag):
+			tags.Proto = true
+		case strings.EqualFold(tag, "Hack"):
+			tags.Hack = true
+		case strings.EqualFold(tag, "Demo") || strings.EqualFold(tag, "Sample"):
+			tags.Demo = true
+		case strings.EqualFold(tag, "Unl"):
+			tags.Unlicensed = true
+		}
+	}
+
+	return tags
+}
+
+// Read GoodTools square bracket dump codes.
+func parseBracketTag(tags *Tags, tag string) {
+	switch {
+	case tag == "!":
+		tags.Verified = true
+	case badDumpRe.MatchString(tag):
+		tags.BadDump = true
+	case hackRe.MatchString(tag):
+		tags.Hack = true
+	case translationRe.MatchString(tag):
+		tags.Translation = true
+	}
+}
+
+// Return the position of the best matching region in a list of preferred
+// regions, or the length of the list if none match. World releases match
+// every preferred region.
+func regionRank(tags Tags, preferred []string) int {
+	best := len(preferred)
+	for _, region := range tags.Regions {
+		if strings.EqualFold(region, "World") && len(preferred) > 0 {
+			return 0
+		}
+
+		for i, p := range preferred {
+			if i < best && strings.EqualFold(region, p) {
+				best = i
+			}
+		}
+	}
+	return best
+}
+
+// Return the position of the best matching language in a list of preferred
+// regions and languages, or the length of the list if none match.
+func languageRank(tags Tags, preferred []string) int {
+	best := len(preferred)
+	for _, lang := range tags.Languages {
+		for i, p := range preferred {
+			if i < best && strings.EqualFold(lang, p) {
+				best = i
+			}
+		}
+	}
+	return best
+}
+
+// Count of tags which make a release less desirable than a normal one.
+func flagPenalty(tags Tags) int {
+	penalty := 0
+	for _, flag := range []bool{tags.BadDump, tags.Beta, tags.Proto, tags.Hack, tags.Demo} {
+		if flag {
+			penalty++
+		}
+	}
+	return penalty
+}
+
+// BetterVersion returns true if a is a more desirable release of a game than
+// b. Clean releases are better than bad dumps, betas, prototypes, hacks and
+// demos, then releases are ranked by the given list of preferred regions and
+// language codes (e.g. "USA", "Europe", "En"), then verified dumps,
+// untranslated releases and finally the newest revision are preferred.
+func BetterVersion(a Tags, b Tags, preferred []string) bool {
+	if pa, pb := flagPenalty(a), flagPenalty(b); pa != pb {
+		return pa < pb
+	}
+
+	if ra, rb := regionRank(a, preferred), regionRank(b, preferred); ra != rb {
+		return ra < rb
+	}
+
+	if la, lb := languageRank(a, preferred), languageRank(b, preferred); la != lb {
+		return la < lb
+	}
+
+	if a.Verified != b.Verified {
+		return a.Verified
+	}
+
+	if a.Translation != b.Translation {
+		return !a.Translation
+	}
+
+	return compareRevisions(a.Revision, b.Revision) > 0
+}
+
+// Compare revision strings like "1", "1.1" or "A", treating a blank revision
+// as the oldest. Returns 1 if a is newer, -1 if b is newer and 0 if equal.
+func compareRevisions(a string, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y string
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+
+		// compare numbers by length first so "10" is newer than "9"
+		if len(x) != len(y) {
+			if len(x) > len(y) {
+				return 1
+			}
+			return -1
+		}
+
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package games
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	var tests = []struct {
+		name string
+		want Tags
+	}{
+		{
+			"Super Mario Bros. (World)",
+			Tags{Title: "Super Mario Bros.", Regions: []string{"World"}},
+		},
+		{
+			"Legend of Zelda, The (USA) (Rev 1)",
+			Tags{Title: "The Legend of Zelda", Regions: []string{"USA"}, Revision: "1"},
+		},
+		{
+			"Tetris (Europe) (En,Fr,De)",
+			Tags{
+				Title:     "Tetris",
+				Regions:   []string{"Europe"},
+				Languages: []string{"En", "Fr", "De"},
+			},
+		},
+		{
+			"Star Fox (USA, Europe) (Beta 2)",
+			Tags{Title: "Star Fox", Regions: []string{"USA", "Europe"}, Beta: true},
+		},
+		{
+			"Some Game (Japan) (v1.1) (Proto)",
+			Tags{Title: "Some Game", Regions: []string{"Japan"}, Revision: "1.1", Proto: true},
+		},
+		{
+			"Some Game (USA) (Hack) [!]",
+			Tags{Title: "Some Game", Regions: []string{"USA"}, Hack: true, Verified: true},
+		},
+		{
+			"Legend of Zelda, The (U) (PRG1) [!]",
+			Tags{Title: "The Legend of Zelda", Regions: []string{"USA"}, Revision: "1", Verified: true},
+		},
+		{
+			"Some Game (JU) [b2][T+Eng1.0]",
+			Tags{Title: "Some Game", Regions: []string{"Japan", "USA"}, BadDump: true, Translation: true},
+		},
+		{
+			"Some Game (E) (Unl) [h1C]",
+			Tags{Title: "Some Game", Regions: []string{"Europe"}, Unlicensed: true, Hack: true},
+		},
+		{
+			"No Tags",
+			Tags{Title: "No Tags"},
+		},
+	}
+	for _, tt := range tests {
+		if got := ParseTags(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTags(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBetterVersion(t *testing.T) {
+	regions := []string{"Europe", "USA", "En"}
+	var tests = []struct {
+		a, b string
+		want bool
+	}{
+		{"Game (Europe)", "Game (USA)", true},
+		{"Game (USA)", "Game (Europe)", false},
+		{"Game (World)", "Game (USA)", true},
+		{"Game (USA)", "Game (Europe) (Beta)", true},
+		{"Game (USA) (Rev 2)", "Game (USA) (Rev 1)", true},
+		{"Game (USA) (v1.10)", "Game (USA) (v1.9)", true},
+		{"Game (U) [!]", "Game (U) [b1]", true},
+		{"Game (U) [!]", "Game (U)", true},
+		{"Game (Japan)", "Game (Korea)", false},
+		{"Game (Europe) (En,Fr)", "Game (Europe) (De,Fr)", true},
+	}
+	for _, tt := range tests {
+		if got := BetterVersion(ParseTags(tt.a), ParseTags(tt.b), regions); got != tt.want {
+			t.Errorf("BetterVersion(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package games
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type userMglParams struct {
+	Delay  int    `json:"delay"`
+	Method string `json:"method"`
+	Index  int    `json:"index"`
+}
+
+type userSlot struct {
+	Label string         `json:"label"`
+	Exts  []string       `json:"exts"`
+	Mgl   *userMglParams `json:"mgl"`
+}
+
+type userSystem struct {
+	Id             string         `json:"id"`
+	Name           string         `json:"name"`
+	Category       string         `json:"category"`
+	ReleaseDate    string         `json:"releaseDate"`
+	Manufacturer   string         `json:"manufacturer"`
+	Alias          []string       `json:"alias"`
+	SetName        string         `json:"setName"`
+	SetNameSameDir *bool          `json:"setNameSameDir"`
+	Folder         []string       `json:"folder"`
+	Rbf            string         `json:"rbf"`
+	Slots          []userSlot     `json:"slots"`
+	MglParams      *userMglParams `json:"mglParams"`
+}
+
+// The user systems file is a JSON file which can add new systems and
+// override attributes of existing ones. Example:
+//
+//	{
+//	    "systems": [
+//	        {
+//	            "id": "NewCore",
+//	            "name": "New Core",
+//	            "category": "Console",
+//	            "folder": ["NewCore"],
+//	            "rbf": "_Console/NewCore",
+//	            "slots": [
+//	                {"exts": [".bin"], "mgl": {"delay": 1, "method": "f", "index": 1}}
+//	            ]
+//	        },
+//	        {
+//	            "id": "SNES",
+//	            "folder": ["SNES", "SFC"]
+//	        }
+//	    ],
+//	    "groups": {
+//	        "NewCore": ["NewCore", "SNES"]
+//	    }
+//	}
+type userSystemsFile struct {
+	Systems []userSystem        `json:"systems"`
+	Groups  map[string][]string `json:"groups"`
+}
+
+// UserSystemsError contains every validation problem found in a user systems
+// file. Valid entries in the file are still applied.
+type UserSystemsError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *UserSystemsError) Error() string {
+	return fmt.Sprintf(
+		"invalid entries in %s:\n- %s",
+		e.Path,
+		strings.Join(e.Problems, "\n- "),
+	)
+}
+
+var categories = []string{
+	CategoryArcade,
+	CategoryConsole,
+	CategoryComputer,
+	CategoryHandheld,
+	CategoryOther,
+}
+
+func validCategory(category string) bool {
+	for _, c := range categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+func validateMglParams(mgl *userMglParams) error {
+	if mgl == nil {
+		return nil
+	}
+
+	if mgl.Method != "f" && mgl.Method != "s" {
+		return fmt.Errorf("mgl method must be \"f\" or \"s\", got %q", mgl.Method)
+	}
+
+	if mgl.Delay < 0 || mgl.Index < 0 {
+		return fmt.Errorf("mgl delay and index must not be negative")
+	}
+
+	return nil
+}
+
+func convertSlots(slots []userSlot) ([]Slot, error) {
+	var converted []Slot
+
+	for i, slot := range slots {
+		if len(slot.Exts) == 0 {
+			return nil, fmt.Errorf("slot %d has no extensions", i+1)
+		}
+
+		var exts []string
+		for _, ext := range slot.Exts {
+			if !strings.HasPrefix(ext, ".") || len(ext) < 2 {
+				return nil, fmt.Errorf("slot %d has invalid extension %q", i+1, ext)
+			}
+			exts = append(exts, strings.ToLower(ext))
+		}
+
+		if err := validateMglParams(slot.Mgl); err != nil {
+			return nil, fmt.Errorf("slot %d: %s", i+1, err)
+		}
+
+		var mgl *MglParams
+		if slot.Mgl != nil {
+			mgl = &MglParams{
+				Delay:  slot.Mgl.Delay,
+				Method: slot.Mgl.Method,
+				Index:  slot.Mgl.Index,
+			}
+		}
+
+		converted = append(converted, Slot{
+			Label: slot.Label,
+			Exts:  exts,
+			Mgl:   mgl,
+		})
+	}
+
+	return converted, nil
+}
+
+// Apply user values on top of an existing system. Only fields which are set
+// in the user definition are changed.
+func overrideSystem(system System, us userSystem) (System, error) {
+	if us.Name != "" {
+		system.Name = us.Name
+	}
+
+	if us.Category != "" {
+		if !validCategory(us.Category) {
+			return system, fmt.Errorf("unknown category %q", us.Category)
+		}
+		system.Category = us.Category
+	}
+
+	if us.ReleaseDate != "" {
+		system.ReleaseDate = us.ReleaseDate
+	}
+
+	if us.Manufacturer != "" {
+		system.Manufacturer = us.Manufacturer
+	}
+
+	if us.Alias != nil {
+		system.Alias = us.Alias
+	}
+
+	if us.SetName != "" {
+		system.SetName = us.SetName
+	}
+
+	if us.SetNameSameDir != nil {
+		system.SetNameSameDir = *us.SetNameSameDir
+	}
+
+	if us.Folder != nil {
+		if len(us.Folder) == 0 {
+			return system, fmt.Errorf("folder list must not be empty")
+		}
+		system.Folder = us.Folder
+	}
+
+	if us.Rbf != "" {
+		system.Rbf = us.Rbf
+	}
+
+	if us.Slots != nil {
+		slots, err := convertSlots(us.Slots)
+		if err != nil {
+			return system, err
+		}
+		if len(slots) == 0 {
+			return system, fmt.Errorf("slot list must not be empty")
+		}
+		system.Slots = slots
+	}
+
+	if us.MglParams != nil {
+		if err := validateMglParams(us.MglParams); err != nil {
+			return system, err
+		}
+
+		// copy slots so the original definition isn't modified
+		slots := make([]Slot, len(system.Slots))
+		for i, slot := range system.Slots {
+			slots[i] = slot
+			slots[i].Mgl = &MglParams{
+				Delay:  us.MglParams.Delay,
+				Method: us.MglParams.Method,
+				Index:  us.MglParams.Index,
+			}
+		}
+		system.Slots = slots
+	}
+
+	return system, nil
+}
+
+// Create a brand-new system from a user definition.
+func newUserSystem(us userSystem) (System, error) {
+	var missing []string
+	if us.Name == "" {
+		missing = append(missing, "name")
+	}
+	if len(us.Folder) == 0 {
+		missing = append(missing, "folder")
+	}
+	if us.Rbf == "" && us.Category != CategoryArcade {
+		missing = append(missing, "rbf")
+	}
+	if len(us.Slots) == 0 {
+		missing = append(missing, "slots")
+	}
+	if len(missing) > 0 {
+		return System{}, fmt.Errorf("missing required fields: %s", strings.Join(missing, ", "))
+	}
+
+	if us.Category == "" {
+		us.Category = CategoryOther
+	}
+
+	return overrideSystem(System{Id: us.Id}, us)
+}
+
+// Update any copies of the given system inside the core groups.
+func updateCoreGroups(system System) {
+	for groupId, group := range CoreGroups {
+		for i := range group {
+			if group[i].Id == system.Id {
+				CoreGroups[groupId][i] = system
+			}
+		}
+	}
+}
+
+// LoadUserSystems reads a user systems file and merges its definitions into
+// Systems and CoreGroups. A missing file is not an error. If some entries are
+// invalid, the valid ones are still applied and a *UserSystemsError is
+// returned describing the rest.
+func LoadUserSystems(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var usf userSystemsFile
+	err = json.Unmarshal(data, &usf)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %s", path, err)
+	}
+
+	var problems []string
+
+	for i, us := range usf.Systems {
+		if us.Id == "" {
+			problems = append(problems, fmt.Sprintf("system %d: missing id", i+1))
+			continue
+		} else if strings.ContainsAny(us.Id, ":,/ ") {
+			problems = append(problems, fmt.Sprintf("system %s: id must not contain spaces or :,/", us.Id))
+			continue
+		}
+
+		var system System
+		if existing, ok := Systems[us.Id]; ok {
+			system, err = overrideSystem(existing, us)
+		} else {
+			system, err = newUserSystem(us)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("system %s: %s", us.Id, err))
+			continue
+		}
+
+		Systems[system.Id] = system
+		updateCoreGroups(system)
+	}
+
+	for groupId, ids := range usf.Groups {
+		var group []System
+		valid := true
+
+		for _, id := range ids {
+			system, ok := Systems[id]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("group %s: unknown system %s", groupId, id))
+				valid = false
+				break
+			}
+			group = append(group, system)
+		}
+
+		if !valid {
+			continue
+		} else if len(group) == 0 {
+			problems = append(problems, fmt.Sprintf("group %s: no systems", groupId))
+			continue
+		}
+
+		CoreGroups[groupId] = group
+	}
+
+	if len(problems) > 0 {
+		return &UserSystemsError{
+			Path:     path,
+			Problems: problems,
+		}
+	}
+
+	return nil
+}
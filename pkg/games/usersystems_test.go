@@ -0,0 +1,156 @@
+package games
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadTestUserSystems loads a user systems file and restores the built-in
+// systems and groups when the test ends.
+func loadTestUserSystems(t *testing.T, contents string) error {
+	t.Helper()
+
+	systems := make(map[string]System, len(Systems))
+	for k, v := range Systems {
+		systems[k] = v
+	}
+	groups := make(map[string][]System, len(CoreGroups))
+	for k, v := range CoreGroups {
+		groups[k] = append([]System(nil), v...)
+	}
+	t.Cleanup(func() {
+		Systems = systems
+		CoreGroups = groups
+	})
+
+	path := filepath.Join(t.TempDir(), "systems.json")
+	err := os.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return LoadUserSystems(path)
+}
+
+func TestLoadUserSystemsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		system  string
+		problem string
+	}{
+		{"missing id", `{"name": "X"}`, "system 1: missing id"},
+		{"bad id", `{"id": "A B"}`, "system A B: id must not contain spaces or :,/"},
+		{"missing fields", `{"id": "New"}`, "system New: missing required fields: name, folder, rbf, slots"},
+		{"arcade needs no rbf", `{"id": "New", "name": "New", "category": "Arcade", "folder": ["New"], "slots": [{"exts": ["bin"]}]}`, "system New: slot 1 has invalid extension \"bin\""},
+		{"bad category", `{"id": "SNES", "category": "Toaster"}`, "system SNES: unknown category \"Toaster\""},
+		{"empty folder", `{"id": "SNES", "folder": []}`, "system SNES: folder list must not be empty"},
+		{"empty slot", `{"id": "SNES", "slots": [{"exts": []}]}`, "system SNES: slot 1 has no extensions"},
+		{"bad mgl method", `{"id": "SNES", "mglParams": {"method": "x"}}`, "system SNES: mgl method must be \"f\" or \"s\", got \"x\""},
+		{"negative mgl", `{"id": "SNES", "slots": [{"exts": [".sfc"], "mgl": {"method": "f", "delay": -1}}]}`, "system SNES: slot 1: mgl delay and index must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := Systems["SNES"]
+
+			err := loadTestUserSystems(t, `{"systems": [`+tt.system+`]}`)
+
+			var use *UserSystemsError
+			if !errors.As(err, &use) {
+				t.Fatalf("err = %v, want *UserSystemsError", err)
+			}
+			if len(use.Problems) != 1 || use.Problems[0] != tt.problem {
+				t.Errorf("problems = %q, want %q", use.Problems, tt.problem)
+			}
+			if Systems["SNES"].Name != before.Name || len(Systems["SNES"].Folder) != len(before.Folder) {
+				t.Errorf("invalid entry changed SNES: %+v", Systems["SNES"])
+			}
+		})
+	}
+}
+
+func TestLoadUserSystemsOverride(t *testing.T) {
+	err := loadTestUserSystems(t, `{"systems": [
+		{"id": "SNES", "name": "Super Famicom", "folder": ["SFC", "SNES"], "mglParams": {"method": "s", "delay": 3, "index": 2}},
+		{"id": "New", "name": "New Core", "folder": ["New"], "rbf": "_Console/New", "slots": [{"exts": [".BIN"]}]},
+		{"id": "", "name": "Invalid"}
+	]}`)
+
+	var use *UserSystemsError
+	if !errors.As(err, &use) || len(use.Problems) != 1 {
+		t.Fatalf("err = %v, want one problem", err)
+	}
+
+	snes := Systems["SNES"]
+	if snes.Name != "Super Famicom" || snes.Folder[0] != "SFC" || snes.Rbf != "_Console/SNES" {
+		t.Errorf("SNES = %+v, want name and folder overridden only", snes)
+	}
+	for _, slot := range snes.Slots {
+		if slot.Mgl == nil || slot.Mgl.Method != "s" || slot.Mgl.Delay != 3 || slot.Mgl.Index != 2 {
+			t.Errorf("SNES slot mgl = %+v, want mglParams applied", slot.Mgl)
+		}
+	}
+
+	for _, group := range CoreGroups {
+		for _, system := range group {
+			if system.Id == "SNES" && system.Name != "Super Famicom" {
+				t.Errorf("core group copy of SNES was not updated")
+			}
+		}
+	}
+
+	custom, ok := Systems["New"]
+	if !ok {
+		t.Fatal("New system not added")
+	}
+	if custom.Category != CategoryOther || custom.Slots[0].Exts[0] != ".bin" {
+		t.Errorf("New = %+v, want default category and lowercase exts", custom)
+	}
+}
+
+func TestLoadUserSystemsGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  string
+		want    []string
+		problem string
+	}{
+		{"replace", `{"NES": ["NES", "SNES"]}`, []string{"NES", "SNES"}, ""},
+		{"unknown system", `{"NES": ["NES", "Nope"]}`, nil, "group NES: unknown system Nope"},
+		{"empty", `{"NES": []}`, nil, "group NES: no systems"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(CoreGroups["NES"])
+
+			err := loadTestUserSystems(t, `{"groups": `+tt.groups+`}`)
+
+			if tt.problem == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				group := CoreGroups["NES"]
+				if len(group) != len(tt.want) {
+					t.Fatalf("group = %d systems, want %v", len(group), tt.want)
+				}
+				for i, id := range tt.want {
+					if group[i].Id != id {
+						t.Errorf("group[%d] = %s, want %s", i, group[i].Id, id)
+					}
+				}
+				return
+			}
+
+			var use *UserSystemsError
+			if !errors.As(err, &use) || use.Problems[0] != tt.problem {
+				t.Errorf("err = %v, want %q", err, tt.problem)
+			}
+			if len(CoreGroups["NES"]) != before {
+				t.Errorf("invalid group replaced NES group")
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package gamesdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
+)
+
+const arcadeSystemId = "Arcade"
+
+// Read the header of every indexed MRA file, join it with the ArcadeDB and
+// update the stored arcade info. Returns the number of MRAs read.
+func applyArcade(db *bolt.DB, arcadeDb metadata.ArcadeDbIndex) (int, error) {
+	files, err := readSystemFiles(db, arcadeSystemId)
+	if err != nil {
+		return 0, err
+	}
+
+	// read files before the update so the DB isn't locked in the meantime
+	infos := make(map[string]*metadata.ArcadeInfo)
+	for path := range files {
+		if !strings.EqualFold(filepath.Ext(path), ".mra") {
+			continue
+		}
+
+		mra, err := metadata.ReadMra(path)
+		if err != nil {
+			continue
+		}
+
+		info := metadata.NewArcadeInfo(mra, arcadeDb.Get(mra.SetName))
+		infos[path] = &info
+	}
+
+	err = updateFileRecords(db, arcadeSystemId, func(path string, fr *fileRecord) bool {
+		info := infos[path]
+		if info == nil && fr.Arcade == nil {
+			return false
+		}
+
+		fr.Arcade = info
+		return true
+	})
+
+	return len(infos), err
+}
+
+// Return true if the arcade system is one of the given systems.
+func arcadeIndexed(systems []games.System) bool {
+	for _, system := range systems {
+		if system.Id == arcadeSystemId {
+			return true
+		}
+	}
+	return false
+}
+
+// Read the ArcadeDB if it has been downloaded and update the arcade info of
+// every indexed MRA. Runs as part of indexing the arcade system.
+func importArcade(db *bolt.DB) (int, error) {
+	arcadeDb := make(metadata.ArcadeDbIndex)
+	if entries, err := metadata.ReadArcadeDb(); err == nil {
+		arcadeDb = metadata.NewArcadeDbIndex(entries)
+	}
+
+	count, err := applyArcade(db, arcadeDb)
+	if err != nil {
+		return count, fmt.Errorf("error updating arcade info: %s", err)
+	}
+
+	return count, nil
+}
+
+// ImportArcade reads the setname, core, name, year and manufacturer of every
+// indexed MRA file and joins it with the ArcadeDB, if it has been downloaded,
+// for players, orientation, buttons, category and bootleg or homebrew flags.
+// This is also done automatically when indexing the arcade system.
+//
+// Returns the number of MRA files read.
+func ImportArcade() (int, error) {
+	db, err := openNames()
+	if err != nil {
+		return 0, fmt.Errorf("error opening gamesdb: %s", err)
+	}
+	defer db.Close()
+
+	count, err := importArcade(db)
+	if err != nil {
+		return count, err
+	}
+
+	err = db.Sync()
+	if err != nil {
+		return count, fmt.Errorf("error syncing database: %s", err)
+	}
+
+	return count, nil
+}
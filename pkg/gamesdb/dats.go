@@ -0,0 +1,175 @@
+package gamesdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/dat"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+)
+
+// GameInfo is the canonical identity of a file which was matched against a
+// DAT file, and the tags parsed from its canonical name.
+type GameInfo struct {
+	Canonical string `json:"canonical"`
+	games.Tags
+}
+
+func newGameInfo(game *dat.Game) *GameInfo {
+	return &GameInfo{
+		Canonical: game.Title(),
+		Tags:      games.ParseTags(game.Title()),
+	}
+}
+
+// Return the folder DAT files are read from.
+func datFolder(cfg *config.UserConfig) string {
+	if cfg.GamesDb.DatFolder != "" {
+		return cfg.GamesDb.DatFolder
+	}
+	return config.DatsFolder
+}
+
+// Match a file against the DAT index, by hash if it has been hashed and
+// falling back to its filename.
+func matchDat(idx *dat.Index, path string, fr fileRecord) (*dat.Game, bool) {
+	if fr.Hashes != nil {
+		game, ok := idx.MatchHash(fr.Hashes.CRC32, fr.Hashes.MD5, fr.Hashes.SHA1)
+		if ok {
+			return game, true
+		}
+	}
+
+	return idx.MatchName(path)
+}
+
+// Match every indexed file of the given systems against the DAT index and
+// update their stored game info. Returns the number of matched files.
+func applyDats(db *bolt.DB, idx *dat.Index, systems []games.System) (int, error) {
+	matched := 0
+
+	for _, system := range systems {
+		err := updateFileRecords(db, system.Id, func(path string, fr *fileRecord) bool {
+			var info *GameInfo
+			if game, ok := matchDat(idx, path, *fr); ok {
+				info = newGameInfo(game)
+				matched++
+			}
+
+			if info == nil && fr.Info == nil {
+				return false
+			}
+
+			fr.Info = info
+			return true
+		})
+		if err != nil {
+			return matched, err
+		}
+	}
+
+	return matched, nil
+}
+
+// Return true if there are any DAT files available to import.
+func datsAvailable(cfg *config.UserConfig) bool {
+	entries, err := os.ReadDir(datFolder(cfg))
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".dat" || ext == ".xml") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read all DAT files from the DAT folder and match them against indexed files
+// of the given systems. Runs as part of indexing if the DAT folder has files.
+//
+// Only systems in changed, or which haven't been matched against the current
+// DAT files, are imported. Those systems are added to changed so their
+// metadata and media are matched again too. If changed is nil, every system
+// is imported.
+func importDats(
+	db *bolt.DB,
+	cfg *config.UserConfig,
+	systems []games.System,
+	changed map[string]bool,
+) (int, error) {
+	state, err := readImportState(db)
+	if err != nil {
+		return 0, fmt.Errorf("error reading import state: %s", err)
+	}
+
+	current := folderState([]string{datFolder(cfg)}, false)
+	updated := make(map[string]string)
+
+	var imports []games.System
+	for _, system := range systems {
+		if needsImport(state, importDatsSource, current, system.Id, changed) {
+			imports = append(imports, system)
+			updated[system.Id] = current
+			if changed != nil {
+				changed[system.Id] = true
+			}
+		}
+	}
+
+	if len(imports) == 0 {
+		return 0, nil
+	}
+
+	dats, err := dat.ReadFolder(datFolder(cfg))
+	if err != nil {
+		return 0, fmt.Errorf("error reading dat folder: %s", err)
+	}
+
+	matched, err := applyDats(db, dat.NewIndex(dats), imports)
+	if err != nil {
+		return matched, err
+	}
+
+	err = writeImportState(db, importDatsSource, updated)
+	if err != nil {
+		return matched, fmt.Errorf("error writing import state: %s", err)
+	}
+
+	return matched, nil
+}
+
+// ImportDats matches all indexed files of the given systems against the
+// No-Intro, Redump or MAME DAT files in the DAT folder, storing the canonical
+// name, regions, languages, revision and flags of each match. Files are
+// matched by hash if they were hashed during indexing, otherwise by filename.
+// This is also done automatically when indexing if the DAT folder has files.
+//
+// Returns the number of files matched.
+func ImportDats(cfg *config.UserConfig, systems []games.System) (int, error) {
+	db, err := openNames()
+	if err != nil {
+		return 0, fmt.Errorf("error opening gamesdb: %s", err)
+	}
+	defer db.Close()
+
+	matched, err := importDats(db, cfg, systems, nil)
+	if err != nil {
+		return matched, err
+	}
+
+	err = db.Sync()
+	if err != nil {
+		return matched, fmt.Errorf("error syncing database: %s", err)
+	}
+
+	return matched, nil
+}
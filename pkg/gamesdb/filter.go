@@ -0,0 +1,207 @@
+package gamesdb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
+)
+
+// Filter names used in the search config.
+const (
+	FilterNoBeta    = "no_beta"
+	FilterNoProto   = "no_proto"
+	FilterNoHack    = "no_hack"
+	FilterNoBadDump = "no_bad_dump"
+	FilterBestOnly  = "best_only"
+	// Arcade games only, see metadata.ArcadeInfo.
+	FilterNoBootleg  = "no_bootleg"
+	FilterNoHomebrew = "no_homebrew"
+	FilterVertical   = "vertical"
+	FilterHorizontal = "horizontal"
+)
+
+// SearchFilter narrows down search results using the tags parsed from game
+// names and any imported metadata.
+type SearchFilter struct {
+	// Only include games from these regions. Games released for the whole
+	// world or without a region are always included. The order is also used
+	// as the region preference when picking the best version.
+	Regions      []string
+	ExcludeBeta  bool
+	ExcludeProto bool
+	ExcludeHack  bool
+	ExcludeBad   bool
+	// Only include the best version of each game, see games.BetterVersion.
+	BestOnly bool
+	// Only include games with metadata containing these values, ignoring
+	// case. Games without metadata are excluded when any are set.
+	Genre     string
+	Developer string
+	Publisher string
+	// Only include games released in this range of years, 0 is no limit.
+	YearFrom int
+	YearTo   int
+	// Only include games which support at least this many players.
+	Players int
+	// Exclude arcade bootlegs and homebrew.
+	ExcludeBootleg  bool
+	ExcludeHomebrew bool
+	// Only include arcade games with this screen orientation. Games with an
+	// unknown orientation are excluded from vertical results, but included
+	// in horizontal results since almost all non-arcade games are.
+	Orientation string
+}
+
+// NewSearchFilter creates a filter from the search section of a user config.
+func NewSearchFilter(cfg *config.UserConfig) (SearchFilter, error) {
+	return ParseSearchFilter(cfg.Search.Regions, cfg.Search.Filter)
+}
+
+// ParseSearchFilter creates a filter from a list of preferred regions and
+// filter names, in the same format as the search section of a user config.
+func ParseSearchFilter(regions []string, names []string) (SearchFilter, error) {
+	filter := SearchFilter{
+		Regions: regions,
+	}
+
+	for _, f := range names {
+		switch strings.ToLower(strings.TrimSpace(f)) {
+		case FilterNoBeta:
+			filter.ExcludeBeta = true
+		case FilterNoProto:
+			filter.ExcludeProto = true
+		case FilterNoHack:
+			filter.ExcludeHack = true
+		case FilterNoBadDump:
+			filter.ExcludeBad = true
+		case FilterBestOnly:
+			filter.BestOnly = true
+		case FilterNoBootleg:
+			filter.ExcludeBootleg = true
+		case FilterNoHomebrew:
+			filter.ExcludeHomebrew = true
+		case FilterVertical:
+			filter.Orientation = metadata.OrientationVertical
+		case FilterHorizontal:
+			filter.Orientation = metadata.OrientationHorizontal
+		case "":
+			continue
+		default:
+			return filter, fmt.Errorf("unknown search filter: %s", f)
+		}
+	}
+
+	return filter, nil
+}
+
+func inRegions(tags games.Tags, regions []string) bool {
+	if len(regions) == 0 || len(tags.Regions) == 0 {
+		return true
+	}
+
+	for _, region := range tags.Regions {
+		if strings.EqualFold(region, "World") {
+			return true
+		}
+
+		for _, r := range regions {
+			if strings.EqualFold(region, r) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func containsFold(s string, substr string) bool {
+	return substr == "" || strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func matchesMetadata(meta metadata.GameMetadata, filter SearchFilter) bool {
+	if !containsFold(meta.Genre, filter.Genre) ||
+		!containsFold(meta.Developer, filter.Developer) ||
+		!containsFold(meta.Publisher, filter.Publisher) {
+		return false
+	}
+
+	if (filter.YearFrom > 0 || filter.YearTo > 0) && meta.Year == 0 {
+		return false
+	} else if filter.YearFrom > 0 && meta.Year < filter.YearFrom {
+		return false
+	} else if filter.YearTo > 0 && meta.Year > filter.YearTo {
+		return false
+	}
+
+	return filter.Players == 0 || meta.Players >= filter.Players
+}
+
+func matchesArcade(arcade metadata.ArcadeInfo, filter SearchFilter) bool {
+	if (filter.ExcludeBootleg && arcade.Bootleg) || (filter.ExcludeHomebrew && arcade.Homebrew) {
+		return false
+	}
+
+	switch filter.Orientation {
+	case metadata.OrientationVertical:
+		return arcade.Orientation == metadata.OrientationVertical
+	case metadata.OrientationHorizontal:
+		return arcade.Orientation != metadata.OrientationVertical
+	}
+
+	return true
+}
+
+// Key used to group different versions of the same game.
+func versionGroup(result SearchResult) string {
+	return result.SystemId + ":" + strings.Join(normaliseWords(result.Tags.Title), " ")
+}
+
+// FilterResults removes search results which don't match the filter. The
+// order of results is kept, when only the best version of a game is shown it
+// takes the position of the highest result for that game.
+func FilterResults(results []SearchResult, filter SearchFilter) []SearchResult {
+	var filtered []SearchResult
+
+	for _, result := range results {
+		tags := result.Tags
+		if (filter.ExcludeBeta && tags.Beta) ||
+			(filter.ExcludeProto && tags.Proto) ||
+			(filter.ExcludeHack && tags.Hack) ||
+			(filter.ExcludeBad && tags.BadDump) ||
+			!inRegions(tags, filter.Regions) ||
+			!matchesMetadata(result.Metadata, filter) ||
+			!matchesArcade(result.Arcade, filter) {
+			continue
+		}
+
+		filtered = append(filtered, result)
+	}
+
+	if !filter.BestOnly {
+		return filtered
+	}
+
+	best := make(map[string]int)
+	var grouped []SearchResult
+
+	for _, result := range filtered {
+		group := versionGroup(result)
+
+		if i, ok := best[group]; ok {
+			if games.BetterVersion(result.Tags, grouped[i].Tags, filter.Regions) {
+				// keep the relevance of the highest ranked version
+				result.Score = grouped[i].Score
+				grouped[i] = result
+			}
+			continue
+		}
+
+		best[group] = len(grouped)
+		grouped = append(grouped, result)
+	}
+
+	return grouped
+}
@@ -0,0 +1,266 @@
+package gamesdb
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Accented characters which are folded to their plain equivalent when
+// normalising names.
+var diacritics = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a",
+	'æ': "ae", 'ç': "c", 'č': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ñ': "n", 'ń': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o",
+	'œ': "oe", 'ß': "ss", 'š': "s", 'ś': "s",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u",
+	'ý': "y", 'ÿ': "y", 'ž': "z", 'ź': "z", 'ż': "z", 'ł': "l",
+}
+
+// Single letter numerals (I, V and X) aren't included because they're just
+// as often letters, e.g. "Mega Man X" is not "Mega Man 10".
+var romanNumerals = map[string]int{
+	"ii": 2, "iii": 3, "iv": 4, "vi": 6, "vii": 7, "viii": 8, "ix": 9,
+	"xi": 11, "xii": 12, "xiii": 13, "xiv": 14, "xv": 15, "xvi": 16,
+	"xvii": 17, "xviii": 18, "xix": 19, "xx": 20,
+}
+
+// Split a name into lowercase words with diacritics and punctuation removed
+// and roman numerals converted to numbers. Apostrophes are dropped instead
+// of splitting words, so "Yoshi's" becomes "yoshis".
+func normaliseWords(name string) []string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if folded, ok := diacritics[r]; ok {
+			sb.WriteString(folded)
+		} else if r == '\'' || r == '’' {
+			continue
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+
+	words := strings.Fields(sb.String())
+	for i, word := range words {
+		if n, ok := romanNumerals[word]; ok {
+			words[i] = strconv.Itoa(n)
+		}
+	}
+
+	return words
+}
+
+// Return the edit distance between two strings, counting a swap of two
+// adjacent characters as a single edit. Returns max+1 if the distance is
+// greater than max.
+func editDistance(a string, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > max {
+		return max + 1
+	}
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+
+			// transposition
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && prev2[j-2]+1 < curr[j] {
+				curr[j] = prev2[j-2] + 1
+			}
+
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+
+		if rowMin > max {
+			return max + 1
+		}
+
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func min3(a int, b int, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Number of typos allowed in a query word, based on its length.
+func allowedTypos(word string) int {
+	switch n := len([]rune(word)); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Score how well a single query word matches a name word, from 0 (no match)
+// to 1 (exact match).
+func scoreWord(query string, word string) float64 {
+	if query == word {
+		return 1
+	}
+
+	if strings.HasPrefix(word, query) {
+		return 0.9
+	}
+
+	maxTypos := allowedTypos(query)
+	if maxTypos > 0 {
+		if d := editDistance(query, word, maxTypos); d <= maxTypos {
+			return 0.8 - 0.15*float64(d)
+		}
+
+		// typo in a partially typed word
+		qr, wr := []rune(query), []rune(word)
+		if len(wr) > len(qr) {
+			if d := editDistance(query, string(wr[:len(qr)]), maxTypos); d <= maxTypos {
+				return 0.6 - 0.15*float64(d)
+			}
+		}
+	}
+
+	if len(query) > 2 && strings.Contains(word, query) {
+		return 0.5
+	}
+
+	return 0
+}
+
+// Score how well a normalised query matches the words of a name. Every query
+// word must match a word in the name for a score greater than 0. Names which
+// match in the same word order and have fewer extra words in the title (the
+// first titleLen words) score higher.
+func scoreName(query []string, name []string, titleLen int) float64 {
+	if len(query) == 0 || len(name) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	inOrder := true
+	lastPos := -1
+
+	for _, q := range query {
+		best := 0.0
+		bestPos := -1
+
+		for i, w := range name {
+			if s := scoreWord(q, w); s > best {
+				best = s
+				bestPos = i
+				if s == 1 {
+					break
+				}
+			}
+		}
+
+		if best == 0 {
+			return 0
+		}
+
+		if bestPos < lastPos {
+			inOrder = false
+		}
+		lastPos = bestPos
+
+		total += best
+	}
+
+	score := total / float64(len(query))
+
+	if inOrder {
+		score += 0.1
+	}
+
+	// prefer names closer in length to the query
+	if extra := titleLen - len(query); extra > 0 {
+		score -= 0.02 * float64(extra)
+	}
+
+	if score < 0.01 {
+		score = 0.01
+	}
+
+	return score
+}
+
+// Return the normalised words of a game name and how many of them are part
+// of the title, before any tags.
+func nameWords(name string) ([]string, int) {
+	title, tags := name, ""
+	if idx := strings.IndexAny(name, "(["); idx > 0 {
+		title, tags = name[:idx], name[idx:]
+	}
+
+	words := normaliseWords(title)
+	titleLen := len(words)
+
+	return append(words, normaliseWords(tags)...), titleLen
+}
+
+// FuzzyScorer returns a function which scores game names against a query,
+// using the same rules as SearchNamesFuzzy. A score of 0 is not a match.
+// A query with no words matches every name with the same score, so they're
+// sorted by name.
+func FuzzyScorer(query string) func(name string) float64 {
+	qWords := normaliseWords(query)
+	if len(qWords) == 0 {
+		return func(string) float64 {
+			return 1
+		}
+	}
+
+	return func(name string) float64 {
+		words, titleLen := nameWords(name)
+		return scoreName(qWords, words, titleLen)
+	}
+}
+
+// SortByScore sorts search results by highest score first, then by name.
+func SortByScore(results []SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return strings.ToLower(results[i].Name) < strings.ToLower(results[j].Name)
+	})
+}
@@ -0,0 +1,96 @@
+package gamesdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormaliseWords(t *testing.T) {
+	var tests = []struct {
+		name string
+		want []string
+	}{
+		{"Pokémon Red", []string{"pokemon", "red"}},
+		{"Street Fighter II' - Champion Edition", []string{"street", "fighter", "2", "champion", "edition"}},
+		{"Yoshi's Island", []string{"yoshis", "island"}},
+		{"I Have No Mouth", []string{"i", "have", "no", "mouth"}},
+		{"Final Fantasy I", []string{"final", "fantasy", "i"}},
+		{"Mega Man X", []string{"mega", "man", "x"}},
+		{"Final Fantasy VI", []string{"final", "fantasy", "6"}},
+		{"  ", []string{}},
+	}
+	for _, tt := range tests {
+		if got := normaliseWords(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normaliseWords(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	var tests = []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"mario", "mario", 2, 0},
+		{"maro", "mario", 2, 1},
+		{"zelda", "zedla", 2, 1},
+		{"sonic", "tetris", 2, 3},
+		{"a", "abcdef", 2, 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func fuzzyScore(query string, name string) float64 {
+	words, titleLen := nameWords(name)
+	return scoreName(normaliseWords(query), words, titleLen)
+}
+
+func TestScoreName(t *testing.T) {
+	var matches = []struct {
+		query string
+		name  string
+	}{
+		{"pokemon", "Pokémon - Red Version (USA, Europe)"},
+		{"street fighter 2", "Street Fighter II (USA)"},
+		{"super maro", "Super Mario World (USA)"},
+		{"zelad", "Legend of Zelda, The (USA)"},
+		{"mario usa", "Super Mario Bros. (USA)"},
+		{"final fantasy 6", "Final Fantasy VI (USA)"},
+		{"покеmо", "Покемончик"},
+	}
+	for _, tt := range matches {
+		if fuzzyScore(tt.query, tt.name) == 0 {
+			t.Errorf("query %q should match %q", tt.query, tt.name)
+		}
+	}
+
+	var misses = []struct {
+		query string
+		name  string
+	}{
+		{"sonic", "Super Mario World (USA)"},
+		{"mario kart", "Super Mario World (USA)"},
+		{"cat", "Cut Man (USA)"},
+		{"mega man 10", "Mega Man X (USA)"},
+	}
+	for _, tt := range misses {
+		if fuzzyScore(tt.query, tt.name) != 0 {
+			t.Errorf("query %q should not match %q", tt.query, tt.name)
+		}
+	}
+
+	exact := fuzzyScore("super mario world", "Super Mario World (USA)")
+	longer := fuzzyScore("super mario world", "Super Mario World 2 - Yoshi's Island (USA)")
+	typo := fuzzyScore("super mario wrold", "Super Mario World (USA)")
+	if exact <= longer {
+		t.Errorf("exact title should score higher than longer title: %f <= %f", exact, longer)
+	}
+	if exact <= typo {
+		t.Errorf("exact match should score higher than typo: %f <= %f", exact, typo)
+	}
+}
@@ -2,63 +2,146 @@ package gamesdb
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/wizzomafizzo/mrext/pkg/config"
 	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
 	"github.com/wizzomafizzo/mrext/pkg/utils"
 )
 
 const (
 	BucketNames       = "names"
+	BucketFiles       = "files"
+	BucketFolders     = "folders"
+	BucketZips        = "zips"
+	BucketHashes      = "hashes"
+	BucketReleases    = "releases"
+	BucketRandom      = "random"
 	indexedSystemsKey = "meta:indexedSystems"
+	versionKey        = "meta:version"
+	// Increment when the format of the DB changes. Existing DBs with a
+	// different version will be emptied and need to be indexed again.
+	dbVersion = "5"
 )
 
-// Return the key for a name in the names index.
+var allBuckets = []string{BucketNames, BucketFiles, BucketFolders, BucketZips, BucketHashes, BucketReleases, BucketRandom}
+
+// Path of the gamesdb file. Only changed by tests.
+var dbFile = config.GamesDb
+
+// Separates the name and path in a names index key.
+const nameSep = "\x00"
+
+// Return the key prefix for a name in the names index. Every file with this
+// name is stored under the prefix followed by its path, so files sharing a
+// name in different folders or zips are all kept.
 func NameKey(systemId string, name string) string {
 	return systemId + ":" + name
 }
 
+// Return the full key for a file in the names index.
+func namePathKey(systemId string, name string, path string) string {
+	return NameKey(systemId, name) + nameSep + path
+}
+
+// Return the full key for a file in the releases index. Files are stored
+// under their dedupe key so every release of a game can be found with a
+// prefix lookup, see games.DedupeKey.
+func releaseKey(systemId string, path string) string {
+	return systemId + ":" + games.DedupeKey(path) + nameSep + path
+}
+
+// Return the key for a file in the random index. Files are stored under a
+// hash of their path, which spreads them evenly over the system's keys so a
+// random game can be picked by seeking to a random key.
+func randomKey(systemId string, path string) string {
+	sum := sha1.Sum([]byte(path))
+	return systemId + ":" + hex.EncodeToString(sum[:])
+}
+
 // Check if the gamesdb exists on disk.
 func DbExists() bool {
-	_, err := os.Stat(config.GamesDb)
+	_, err := os.Stat(dbFile)
 	return err == nil
 }
 
+// Return when the gamesdb was last written to, or a zero time if it doesn't
+// exist.
+func DbModTime() time.Time {
+	info, err := os.Stat(dbFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
 // Open the gamesdb with the given options. If the database does not exist it
 // will be created and the buckets will be initialized.
 func open(options *bolt.Options) (*bolt.DB, error) {
-	err := os.MkdirAll(filepath.Dir(config.GamesDb), 0755)
+	err := os.MkdirAll(filepath.Dir(dbFile), 0755)
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := bolt.Open(config.GamesDb, 0600, options)
+	db, err := bolt.Open(dbFile, 0600, options)
 	if err != nil {
 		return nil, err
 	}
 
-	db.Update(func(txn *bolt.Tx) error {
-		for _, bucket := range []string{BucketNames} {
-			_, err := txn.CreateBucketIfNotExists([]byte(bucket))
-			if err != nil {
-				return err
+	if options == nil || !options.ReadOnly {
+		err = db.Update(func(txn *bolt.Tx) error {
+			for _, bucket := range allBuckets {
+				_, err := txn.CreateBucketIfNotExists([]byte(bucket))
+				if err != nil {
+					return err
+				}
 			}
-		}
 
-		return nil
-	})
+			v := txn.Bucket([]byte(BucketNames)).Get([]byte(versionKey))
+			if string(v) != dbVersion {
+				return resetBuckets(txn)
+			}
+
+			return nil
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
 
 	return db, nil
 }
 
+// Delete and recreate all buckets, then write the current DB version.
+func resetBuckets(txn *bolt.Tx) error {
+	for _, bucket := range allBuckets {
+		err := txn.DeleteBucket([]byte(bucket))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		_, err = txn.CreateBucket([]byte(bucket))
+		if err != nil {
+			return err
+		}
+	}
+
+	return txn.Bucket([]byte(BucketNames)).Put([]byte(versionKey), []byte(dbVersion))
+}
+
 // Open the gamesdb with default options for generating names index.
 func openNames() (*bolt.DB, error) {
 	return open(&bolt.Options{
@@ -101,41 +184,249 @@ func writeIndexedSystems(db *bolt.DB, systems []string) error {
 	})
 }
 
-type fileInfo struct {
-	SystemId string
-	Path     string
+type fileRecord struct {
+	Name string `json:"name"`
+	// Size and ModTime are of the file, or the zip containing it, at the
+	// time it was hashed.
+	Size    int64       `json:"size,omitempty"`
+	ModTime int64       `json:"mtime,omitempty"`
+	Hashes  *FileHashes `json:"hashes,omitempty"`
+	// Parsed from the filename when the file is indexed.
+	Tags *games.Tags `json:"tags,omitempty"`
+	// Set if the file was matched in a DAT file.
+	Info *GameInfo `json:"info,omitempty"`
+	// Set if the file was matched in a metadata file.
+	Meta *metadata.GameMetadata `json:"meta,omitempty"`
+	// Paths to images of the game, by media type.
+	Media map[string]string `json:"media,omitempty"`
+	// Set for arcade MRA files.
+	Arcade *metadata.ArcadeInfo `json:"arcade,omitempty"`
+}
+
+func newFileRecord(path string) fileRecord {
+	name := fileName(path)
+	tags := games.ParseTags(name)
+	return fileRecord{
+		Name: name,
+		Tags: &tags,
+	}
+}
+
+func decodeFileRecord(v []byte) (fileRecord, error) {
+	var fr fileRecord
+	if v == nil {
+		return fr, fmt.Errorf("file record not found")
+	}
+	err := json.Unmarshal(v, &fr)
+	return fr, err
+}
+
+// Return the key for a file in the files index.
+func fileKey(systemId string, path string) string {
+	return systemId + ":" + path
+}
+
+// Return the name a file is indexed under.
+func fileName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Read all indexed files for a system, mapped from path to record.
+func readSystemFiles(db *bolt.DB, systemId string) (map[string]fileRecord, error) {
+	files := make(map[string]fileRecord)
+
+	err := db.View(func(tx *bolt.Tx) error {
+		pre := []byte(fileKey(systemId, ""))
+
+		c := tx.Bucket([]byte(BucketFiles)).Cursor()
+		for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
+			fr, err := decodeFileRecord(v)
+			if err != nil {
+				fr = fileRecord{Name: fileName(string(k[len(pre):]))}
+			}
+			files[string(k[len(pre):])] = fr
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+// Call update for every indexed file of a system and store the records it
+// reports as changed.
+func updateFileRecords(db *bolt.DB, systemId string, update func(path string, fr *fileRecord) bool) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bfs := tx.Bucket([]byte(BucketFiles))
+		pre := []byte(fileKey(systemId, ""))
+
+		updated := make(map[string][]byte)
+
+		c := bfs.Cursor()
+		for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
+			fr, err := decodeFileRecord(v)
+			if err != nil {
+				continue
+			}
+
+			if !update(string(k[len(pre):]), &fr) {
+				continue
+			}
+
+			nv, err := json.Marshal(fr)
+			if err != nil {
+				return err
+			}
+
+			if !bytes.Equal(v, nv) {
+				updated[string(k)] = nv
+			}
+		}
+
+		// the bucket can't be modified while iterating it
+		for k, v := range updated {
+			err := bfs.Put([]byte(k), v)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
 
-// Update the names index with the given files.
-func updateNames(db *bolt.DB, files []fileInfo) error {
+// Remove and then add files to the indexes for a system. Changing an
+// existing file is done by including it in both maps.
+func updateNames(db *bolt.DB, systemId string, added map[string]fileRecord, removed map[string]fileRecord) error {
 	return db.Batch(func(tx *bolt.Tx) error {
 		bns := tx.Bucket([]byte(BucketNames))
+		bfs := tx.Bucket([]byte(BucketFiles))
+		bhs := tx.Bucket([]byte(BucketHashes))
+		brs := tx.Bucket([]byte(BucketReleases))
+		bas := tx.Bucket([]byte(BucketRandom))
+
+		for path, fr := range removed {
+			err := bfs.Delete([]byte(fileKey(systemId, path)))
+			if err != nil {
+				return err
+			}
+
+			err = bns.Delete([]byte(namePathKey(systemId, fr.Name, path)))
+			if err != nil {
+				return err
+			}
+
+			err = brs.Delete([]byte(releaseKey(systemId, path)))
+			if err != nil {
+				return err
+			}
 
-		for _, file := range files {
-			base := filepath.Base(file.Path)
-			name := strings.TrimSuffix(base, filepath.Ext(base))
+			err = bas.Delete([]byte(randomKey(systemId, path)))
+			if err != nil {
+				return err
+			}
+
+			for _, hk := range hashKeys(fr.Hashes, systemId, path) {
+				err = bhs.Delete([]byte(hk))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for path, fr := range added {
+			v, err := json.Marshal(fr)
+			if err != nil {
+				return err
+			}
+
+			err = bfs.Put([]byte(fileKey(systemId, path)), v)
+			if err != nil {
+				return err
+			}
+
+			err = bns.Put([]byte(namePathKey(systemId, fr.Name, path)), []byte(path))
+			if err != nil {
+				return err
+			}
+
+			err = brs.Put([]byte(releaseKey(systemId, path)), []byte(fr.Name))
+			if err != nil {
+				return err
+			}
 
-			nk := NameKey(file.SystemId, name)
-			err := bns.Put([]byte(nk), []byte(file.Path))
+			err = bas.Put([]byte(randomKey(systemId, path)), []byte(path))
 			if err != nil {
 				return err
 			}
+
+			for _, hk := range hashKeys(fr.Hashes, systemId, path) {
+				err = bhs.Put([]byte(hk), nil)
+				if err != nil {
+					return err
+				}
+			}
 		}
 
 		return nil
 	})
 }
 
+// Return new records for files which need to be hashed: files which have
+// never been hashed or have changed on disk since they were.
+func hashRecords(files map[string]fileRecord) map[string]fileRecord {
+	var paths []string
+	stats := make(map[string][2]int64)
+
+	for path, fr := range files {
+		size, mtime, err := statGameFile(path)
+		if err != nil {
+			continue
+		}
+
+		if fr.Hashes != nil && fr.Size == size && fr.ModTime == mtime {
+			continue
+		}
+
+		paths = append(paths, path)
+		stats[path] = [2]int64{size, mtime}
+	}
+
+	hashed := make(map[string]fileRecord)
+	for path, h := range hashGameFiles(paths) {
+		h := h
+		fr := files[path]
+		fr.Size = stats[path][0]
+		fr.ModTime = stats[path][1]
+		fr.Hashes = &h
+		hashed[path] = fr
+	}
+
+	return hashed
+}
+
+// Empty all index buckets, ready for a full rebuild.
+func resetIndex(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		return resetBuckets(tx)
+	})
+}
+
 type IndexStatus struct {
-	Total    int
-	Step     int
-	SystemId string
-	Files    int
+	Total     int
+	Step      int
+	SystemId  string
+	Files     int
+	Added     int
+	Removed   int
+	Unchanged int
+	Hashed    int
 }
 
 // Given a list of systems, index all valid game files on disk and write a
-// names index to the DB. Overwrites any existing names index, but does not
-// clean up old missing files.
+// names index to the DB. Any existing index is discarded first and every
+// folder is read again.
 //
 // Takes a function which will be called with the current status of the index
 // during key steps.
@@ -145,6 +436,30 @@ func NewNamesIndex(
 	cfg *config.UserConfig,
 	systems []games.System,
 	update func(IndexStatus),
+) (int, error) {
+	return indexNames(cfg, systems, update, true)
+}
+
+// Same as NewNamesIndex, but only reads folders and zip files which have
+// changed since the last index. Files which no longer exist are removed from
+// the index. The Added, Removed and Unchanged counts in the status are
+// updated as each system is processed.
+//
+// If the hash_files option is enabled, checksums are also calculated for any
+// new or changed files and can be looked up with FindByHash.
+func UpdateNamesIndex(
+	cfg *config.UserConfig,
+	systems []games.System,
+	update func(IndexStatus),
+) (int, error) {
+	return indexNames(cfg, systems, update, false)
+}
+
+func indexNames(
+	cfg *config.UserConfig,
+	systems []games.System,
+	update func(IndexStatus),
+	rebuild bool,
 ) (int, error) {
 	status := IndexStatus{
 		Total: len(systems) + 1,
@@ -157,6 +472,19 @@ func NewNamesIndex(
 	}
 	defer db.Close()
 
+	if rebuild {
+		err = resetIndex(db)
+		if err != nil {
+			return status.Files, fmt.Errorf("error resetting index: %s", err)
+		}
+	}
+
+	sc := newScanner()
+	err = sc.load(db)
+	if err != nil {
+		return status.Files, fmt.Errorf("error reading folder cache: %s", err)
+	}
+
 	update(status)
 	systemPaths := make(map[string][]string, 0)
 	for _, v := range games.GetSystemPaths(cfg, systems) {
@@ -164,47 +492,173 @@ func NewNamesIndex(
 	}
 
 	g := new(errgroup.Group)
+	counts := make(map[string]int)
+	// systems with new or updated file records, which need game info
+	// imported again
+	changed := make(map[string]bool)
+
+	for _, system := range systems {
+		k := system.Id
+		if _, ok := systemPaths[k]; !ok {
+			// system may have been indexed before, its folders are gone now
+			continue
+		}
 
-	for _, k := range utils.AlphaMapKeys(systemPaths) {
 		status.SystemId = k
 		status.Step++
 		update(status)
 
-		files := make([]fileInfo, 0)
+		found := make(map[string]struct{})
 
 		for _, path := range systemPaths[k] {
-			pathFiles, err := games.GetFiles(k, path)
+			pathFiles, err := sc.systemFiles(system, path)
 			if err != nil {
 				return status.Files, fmt.Errorf("error getting files: %s", err)
 			}
 
-			if len(pathFiles) == 0 {
+			for _, pf := range pathFiles {
+				found[pf] = struct{}{}
+			}
+		}
+
+		existing, err := readSystemFiles(db, k)
+		if err != nil {
+			return status.Files, fmt.Errorf("error reading index: %s", err)
+		}
+
+		added := make(map[string]fileRecord)
+		unchanged := make(map[string]fileRecord)
+		for path := range found {
+			if fr, ok := existing[path]; ok {
+				delete(existing, path)
+				unchanged[path] = fr
+				status.Unchanged++
+			} else {
+				added[path] = newFileRecord(path)
+			}
+		}
+		removed := existing
+
+		status.Files += len(found)
+		counts[k] = len(found)
+		status.Added += len(added)
+		status.Removed += len(removed)
+
+		if cfg.GamesDb.HashFiles {
+			for path, fr := range hashRecords(added) {
+				added[path] = fr
+				status.Hashed++
+			}
+
+			// only files in folders and zips which were read again can
+			// have changed, stat'ing every file is too slow on large
+			// collections. A rebuild will catch files edited in place.
+			rehash := make(map[string]fileRecord)
+			for path, fr := range unchanged {
+				if fr.Hashes == nil || sc.changed(path) {
+					rehash[path] = fr
+				}
+			}
+
+			for path, fr := range hashRecords(rehash) {
+				// replace the old record and its hashes
+				removed[path] = unchanged[path]
+				added[path] = fr
+				status.Hashed++
+			}
+		}
+
+		// records from older versions may be missing parsed tags
+		for path, fr := range unchanged {
+			if fr.Tags != nil {
 				continue
 			}
 
-			for pf := range pathFiles {
-				files = append(files, fileInfo{SystemId: k, Path: pathFiles[pf]})
+			if _, ok := added[path]; ok {
+				fr = added[path]
+			} else {
+				removed[path] = fr
 			}
+			fr.Tags = newFileRecord(path).Tags
+			added[path] = fr
 		}
 
-		if len(files) == 0 {
+		if len(added) == 0 && len(removed) == 0 {
 			continue
 		}
 
-		status.Files += len(files)
+		if len(added) > 0 {
+			changed[k] = true
+		}
 
 		g.Go(func() error {
-			return updateNames(db, files)
+			return updateNames(db, k, added, removed)
 		})
 	}
 
+	err = g.Wait()
+	if err != nil {
+		return status.Files, fmt.Errorf("error updating names index: %s", err)
+	}
+
+	// clean up systems which no longer have any games folders
+	for _, system := range systems {
+		if _, ok := systemPaths[system.Id]; ok {
+			continue
+		}
+
+		existing, err := readSystemFiles(db, system.Id)
+		if err != nil {
+			return status.Files, fmt.Errorf("error reading index: %s", err)
+		}
+
+		counts[system.Id] = 0
+
+		if len(existing) == 0 {
+			continue
+		}
+
+		status.Removed += len(existing)
+		err = updateNames(db, system.Id, nil, existing)
+		if err != nil {
+			return status.Files, fmt.Errorf("error updating names index: %s", err)
+		}
+	}
+
 	status.Step++
 	status.SystemId = ""
 	update(status)
 
-	err = g.Wait()
+	err = sc.save(db)
 	if err != nil {
-		return status.Files, fmt.Errorf("error updating names index: %s", err)
+		return status.Files, fmt.Errorf("error writing folder cache: %s", err)
+	}
+
+	if datsAvailable(cfg) {
+		_, err = importDats(db, cfg, systems, changed)
+		if err != nil {
+			return status.Files, err
+		}
+	}
+
+	// after DATs so files can be matched by canonical name
+	if metadataAvailable(cfg) {
+		_, err = importMetadata(db, cfg, systems, changed)
+		if err != nil {
+			return status.Files, err
+		}
+	}
+
+	_, err = importMedia(db, cfg, systems, changed)
+	if err != nil {
+		return status.Files, err
+	}
+
+	if arcadeIndexed(systems) {
+		_, err = importArcade(db)
+		if err != nil {
+			return status.Files, err
+		}
 	}
 
 	err = writeIndexedSystems(db, utils.AlphaMapKeys(systemPaths))
@@ -212,6 +666,11 @@ func NewNamesIndex(
 		return status.Files, fmt.Errorf("error writing indexed systems: %s", err)
 	}
 
+	err = writeSystemCounts(db, counts)
+	if err != nil {
+		return status.Files, fmt.Errorf("error writing system counts: %s", err)
+	}
+
 	err = db.Sync()
 	if err != nil {
 		return status.Files, fmt.Errorf("error syncing database: %s", err)
@@ -224,6 +683,62 @@ type SearchResult struct {
 	SystemId string
 	Name     string
 	Path     string
+	// Canonical name from a matched DAT file, blank if not matched.
+	Canonical string
+	// Parsed from the canonical name, or the filename if not matched.
+	Tags games.Tags
+	// Imported from a metadata file, blank if not matched.
+	Metadata metadata.GameMetadata
+	// Paths to images of the game, by media type. See media.Types.
+	Media map[string]string
+	// Read from the MRA file and ArcadeDB, blank if not an arcade game.
+	Arcade metadata.ArcadeInfo
+	// Relevance of the result, higher is better. Always 1 for searches
+	// which aren't ranked.
+	Score float64
+}
+
+// Create a search result, including any stored game info for the file.
+func newSearchResult(bfs *bolt.Bucket, systemId string, name string, path string) SearchResult {
+	result := SearchResult{
+		SystemId: systemId,
+		Name:     name,
+		Path:     path,
+	}
+
+	var fr fileRecord
+	if bfs != nil {
+		fr, _ = decodeFileRecord(bfs.Get([]byte(fileKey(systemId, path))))
+	}
+
+	if fr.Info != nil {
+		result.Canonical = fr.Info.Canonical
+		result.Tags = fr.Info.Tags
+	} else if fr.Tags != nil {
+		result.Tags = *fr.Tags
+	} else {
+		result.Tags = games.ParseTags(name)
+	}
+
+	if fr.Arcade != nil {
+		result.Arcade = *fr.Arcade
+	}
+
+	if fr.Meta != nil {
+		result.Metadata = *fr.Meta
+	} else if fr.Arcade != nil {
+		// so arcade games can use the same metadata filters
+		result.Metadata = metadata.GameMetadata{
+			Genre:     fr.Arcade.Category,
+			Year:      fr.Arcade.Year,
+			Developer: fr.Arcade.Manufacturer,
+			Players:   fr.Arcade.Players,
+		}
+	}
+
+	result.Media = fr.Media
+
+	return result
 }
 
 // Iterate all indexed names and return matches to test func against query.
@@ -231,6 +746,20 @@ func searchNamesGeneric(
 	systems []games.System,
 	query string,
 	test func(string, string) bool,
+) ([]SearchResult, error) {
+	return searchNamesScored(systems, func(keyName string) float64 {
+		if test(query, keyName) {
+			return 1
+		}
+		return 0
+	})
+}
+
+// Iterate all indexed names and return every name given a score greater
+// than 0 by the score func.
+func searchNamesScored(
+	systems []games.System,
+	score func(string) float64,
 ) ([]SearchResult, error) {
 	if !DbExists() {
 		return nil, fmt.Errorf("gamesdb does not exist")
@@ -246,6 +775,7 @@ func searchNamesGeneric(
 
 	err = db.View(func(tx *bolt.Tx) error {
 		bn := tx.Bucket([]byte(BucketNames))
+		bfs := tx.Bucket([]byte(BucketFiles))
 
 		for _, system := range systems {
 			pre := []byte(system.Id + ":")
@@ -254,13 +784,14 @@ func searchNamesGeneric(
 			c := bn.Cursor()
 			for k, v := c.Seek([]byte(pre)); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
 				keyName := string(k[nameIdx+1:])
+				if i := strings.Index(keyName, nameSep); i >= 0 {
+					keyName = keyName[:i]
+				}
 
-				if test(query, keyName) {
-					results = append(results, SearchResult{
-						SystemId: system.Id,
-						Name:     keyName,
-						Path:     string(v),
-					})
+				if s := score(keyName); s > 0 {
+					result := newSearchResult(bfs, system.Id, keyName, string(v))
+					result.Score = s
+					results = append(results, result)
 				}
 			}
 		}
@@ -275,6 +806,39 @@ func searchNamesGeneric(
 	return results, nil
 }
 
+// SearchReleases returns every indexed file of a system with the given dedupe
+// key, which are all the releases of the same game. See games.DedupeKey.
+func SearchReleases(systemId string, key string) ([]SearchResult, error) {
+	if !DbExists() {
+		return nil, fmt.Errorf("gamesdb does not exist")
+	}
+
+	db, err := open(&bolt.Options{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var results []SearchResult
+
+	err = db.View(func(tx *bolt.Tx) error {
+		br := tx.Bucket([]byte(BucketReleases))
+		bfs := tx.Bucket([]byte(BucketFiles))
+		pre := []byte(systemId + ":" + key + nameSep)
+
+		c := br.Cursor()
+		for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
+			result := newSearchResult(bfs, systemId, string(v), string(k[len(pre):]))
+			result.Score = 1
+			results = append(results, result)
+		}
+
+		return nil
+	})
+
+	return results, err
+}
+
 // Return indexed names matching exact query (case insensitive).
 func SearchNamesExact(systems []games.System, query string) ([]SearchResult, error) {
 	return searchNamesGeneric(systems, query, func(query, keyName string) bool {
@@ -304,16 +868,118 @@ func SearchNamesWords(systems []games.System, query string) ([]SearchResult, err
 	})
 }
 
-// Return indexed names matching query using regular expression.
+// Return indexed names matching query using regular expression. Returns an
+// error if the expression is invalid.
 func SearchNamesRegexp(systems []games.System, query string) ([]SearchResult, error) {
-	return searchNamesGeneric(systems, query, func(query, keyName string) bool {
-		r, err := regexp.Compile(query)
+	r, err := regexp.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return searchNamesGeneric(systems, query, func(_, keyName string) bool {
+		return r.MatchString(keyName)
+	})
+}
+
+// Return indexed names which loosely match every word in query, sorted by
+// relevance. Matching ignores case, punctuation and accents, treats roman
+// numerals the same as numbers and allows small typos in longer words. An
+// empty query returns every name, sorted by name.
+func SearchNamesFuzzy(systems []games.System, query string) ([]SearchResult, error) {
+	results, err := searchNamesScored(systems, FuzzyScorer(query))
+	if err != nil {
+		return nil, err
+	}
+
+	SortByScore(results)
+
+	return results, nil
+}
+
+// NewSearchResult creates a search result for a game file which isn't stored
+// in the gamesdb. Tags are parsed from the filename.
+func NewSearchResult(systemId string, path string) SearchResult {
+	name := fileName(path)
+	return SearchResult{
+		SystemId: systemId,
+		Name:     name,
+		Path:     path,
+		Tags:     games.ParseTags(name),
+		Score:    1,
+	}
+}
+
+// FileLookup keeps the gamesdb open to look up many indexed files, instead
+// of opening it again for each one.
+type FileLookup struct {
+	db *bolt.DB
+}
+
+// OpenFileLookup opens the gamesdb for looking up indexed files. It must be
+// closed when finished, because the gamesdb can't be written to until then.
+func OpenFileLookup() (*FileLookup, error) {
+	if !DbExists() {
+		return nil, fmt.Errorf("gamesdb does not exist")
+	}
+
+	// don't wait long if the index is being written to
+	db, err := open(&bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileLookup{db: db}, nil
+}
+
+func (fl *FileLookup) Close() error {
+	if fl == nil {
+		return nil
+	}
+	return fl.db.Close()
+}
+
+// Lookup returns the stored info of an indexed file, and its hashes if it
+// was hashed. Returns false if the file isn't indexed, or the lookup is nil.
+func (fl *FileLookup) Lookup(systemId string, path string) (SearchResult, *FileHashes, bool) {
+	var result SearchResult
+	var hashes *FileHashes
+	found := false
+
+	if fl == nil {
+		return result, hashes, found
+	}
+
+	_ = fl.db.View(func(tx *bolt.Tx) error {
+		bfs := tx.Bucket([]byte(BucketFiles))
+		if bfs == nil {
+			return nil
+		}
+
+		fr, err := decodeFileRecord(bfs.Get([]byte(fileKey(systemId, path))))
 		if err != nil {
-			return false
+			return nil
 		}
 
-		return r.MatchString(keyName)
+		result = newSearchResult(bfs, systemId, fileName(path), path)
+		hashes = fr.Hashes
+		found = true
+
+		return nil
 	})
+
+	return result, hashes, found
+}
+
+// LookupFile returns the stored info of an indexed file, and its hashes if
+// it was hashed. Returns false if the file isn't indexed.
+func LookupFile(systemId string, path string) (SearchResult, *FileHashes, bool) {
+	fl, err := OpenFileLookup()
+	if err != nil {
+		return SearchResult{}, nil, false
+	}
+	defer fl.Close()
+
+	return fl.Lookup(systemId, path)
 }
 
 // Return true if a specific system is indexed in the gamesdb
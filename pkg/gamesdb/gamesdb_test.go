@@ -0,0 +1,381 @@
+package gamesdb
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+)
+
+// setupTestIndex points the gamesdb at a temporary file and returns a config
+// with a temporary games folder.
+func setupTestIndex(t *testing.T) (*config.UserConfig, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	old := dbFile
+	dbFile = filepath.Join(dir, "games.db")
+	t.Cleanup(func() {
+		dbFile = old
+	})
+
+	gamesFolder := filepath.Join(dir, "games")
+	cfg := &config.UserConfig{}
+	cfg.Systems.GamesFolder = []string{gamesFolder}
+	cfg.GamesDb.DatFolder = filepath.Join(dir, "dats")
+	cfg.GamesDb.MetadataFolder = filepath.Join(dir, "metadata")
+	cfg.GamesDb.MediaFolder = filepath.Join(dir, "media")
+
+	return cfg, gamesFolder
+}
+
+func writeTestFile(t *testing.T, path string, mtime time.Time) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chtimes(path, mtime, mtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setMtime(t *testing.T, path string, mtime time.Time) {
+	t.Helper()
+
+	err := os.Chtimes(path, mtime, mtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func indexedNames(t *testing.T) []string {
+	t.Helper()
+
+	snes, err := games.GetSystem("SNES")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := SearchNamesPartial([]games.System{*snes}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestUpdateNamesIndex(t *testing.T) {
+	cfg, gamesFolder := setupTestIndex(t)
+
+	snes, err := games.GetSystem("SNES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	systems := []games.System{*snes}
+
+	snesFolder := filepath.Join(gamesFolder, "SNES")
+	subFolder := filepath.Join(snesFolder, "Sub")
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	t3 := t2.Add(time.Hour)
+
+	writeTestFile(t, filepath.Join(snesFolder, "A.sfc"), t1)
+	writeTestFile(t, filepath.Join(snesFolder, "B.sfc"), t1)
+	writeTestFile(t, filepath.Join(subFolder, "C.sfc"), t1)
+	setMtime(t, subFolder, t1)
+	setMtime(t, snesFolder, t1)
+
+	steps := []struct {
+		name    string
+		change  func()
+		status  IndexStatus
+		indexed []string
+	}{
+		{
+			name:    "new index",
+			change:  func() {},
+			status:  IndexStatus{Files: 3, Added: 3},
+			indexed: []string{"A", "B", "C"},
+		},
+		{
+			name:    "nothing changed",
+			change:  func() {},
+			status:  IndexStatus{Files: 3, Unchanged: 3},
+			indexed: []string{"A", "B", "C"},
+		},
+		{
+			// the folder's mtime is the same, so its cached listing is used
+			name: "cached folder",
+			change: func() {
+				writeTestFile(t, filepath.Join(subFolder, "D.sfc"), t1)
+				setMtime(t, subFolder, t1)
+			},
+			status:  IndexStatus{Files: 3, Unchanged: 3},
+			indexed: []string{"A", "B", "C"},
+		},
+		{
+			name: "added and removed",
+			change: func() {
+				setMtime(t, subFolder, t2)
+				err := os.Remove(filepath.Join(snesFolder, "B.sfc"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				setMtime(t, snesFolder, t2)
+			},
+			status:  IndexStatus{Files: 3, Added: 1, Removed: 1, Unchanged: 2},
+			indexed: []string{"A", "C", "D"},
+		},
+		{
+			name: "removed folder",
+			change: func() {
+				err := os.RemoveAll(subFolder)
+				if err != nil {
+					t.Fatal(err)
+				}
+				setMtime(t, snesFolder, t3)
+			},
+			status:  IndexStatus{Files: 1, Removed: 2, Unchanged: 1},
+			indexed: []string{"A"},
+		},
+	}
+
+	for _, step := range steps {
+		step.change()
+
+		var status IndexStatus
+		_, err := UpdateNamesIndex(cfg, systems, func(is IndexStatus) {
+			status = is
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", step.name, err)
+		}
+
+		if status.Files != step.status.Files ||
+			status.Added != step.status.Added ||
+			status.Removed != step.status.Removed ||
+			status.Unchanged != step.status.Unchanged {
+			t.Errorf("%s: status = %+v, want %+v", step.name, status, step.status)
+		}
+
+		names := indexedNames(t)
+		if len(names) != len(step.indexed) {
+			t.Errorf("%s: indexed = %v, want %v", step.name, names, step.indexed)
+			continue
+		}
+		for i := range names {
+			if names[i] != step.indexed[i] {
+				t.Errorf("%s: indexed = %v, want %v", step.name, names, step.indexed)
+				break
+			}
+		}
+	}
+
+	// a rebuild ignores the folder cache
+	writeTestFile(t, filepath.Join(snesFolder, "E.sfc"), t1)
+	setMtime(t, snesFolder, t3)
+	_, err = NewNamesIndex(cfg, systems, func(IndexStatus) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := indexedNames(t)
+	if len(names) != 2 || names[1] != "E" {
+		t.Errorf("rebuild indexed = %v, want [A E]", names)
+	}
+}
+
+func writeTestDat(t *testing.T, path string, title string, mtime time.Time) {
+	t.Helper()
+
+	dat := `<datafile><game name="A"><description>` + title + `</description></game></datafile>`
+	err := os.WriteFile(path, []byte(dat), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setMtime(t, path, mtime)
+}
+
+func TestUpdateNamesIndexImports(t *testing.T) {
+	cfg, gamesFolder := setupTestIndex(t)
+
+	snes, err := games.GetSystem("SNES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	systems := []games.System{*snes}
+
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	writeTestFile(t, filepath.Join(gamesFolder, "SNES", "A.sfc"), t1)
+	err = os.MkdirAll(cfg.GamesDb.DatFolder, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datPath := filepath.Join(cfg.GamesDb.DatFolder, "snes.dat")
+
+	steps := []struct {
+		name      string
+		title     string
+		mtime     time.Time
+		canonical string
+	}{
+		{"first import", "Alpha (USA)", t1, "Alpha (USA)"},
+		// same size and mtime, so the DAT is not read again
+		{"unchanged dat", "Alpha (EUR)", t1, "Alpha (USA)"},
+		{"changed dat", "Alpha (EUR)", t2, "Alpha (EUR)"},
+	}
+
+	for _, step := range steps {
+		writeTestDat(t, datPath, step.title, step.mtime)
+
+		_, err := UpdateNamesIndex(cfg, systems, func(IndexStatus) {})
+		if err != nil {
+			t.Fatalf("%s: %s", step.name, err)
+		}
+
+		results, err := SearchNamesPartial(systems, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Canonical != step.canonical {
+			t.Errorf("%s: results = %+v, want canonical %q", step.name, results, step.canonical)
+		}
+	}
+}
+
+func TestSearchReleases(t *testing.T) {
+	cfg, gamesFolder := setupTestIndex(t)
+
+	snes, err := games.GetSystem("SNES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	systems := []games.System{*snes}
+
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{"Tetris (USA).sfc", "Tetris (Europe).sfc", "Tetris 2 (USA).sfc"} {
+		writeTestFile(t, filepath.Join(gamesFolder, "SNES", name), t1)
+	}
+
+	_, err = NewNamesIndex(cfg, systems, func(IndexStatus) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := SearchReleases("SNES", games.DedupeKey("Tetris (Japan).sfc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+
+	if len(names) != 2 || names[0] != "Tetris (Europe)" || names[1] != "Tetris (USA)" {
+		t.Errorf("SearchReleases() = %v, want [Tetris (Europe) Tetris (USA)]", names)
+	}
+}
+
+func TestSearchNamesFuzzyEmpty(t *testing.T) {
+	cfg, gamesFolder := setupTestIndex(t)
+
+	snes, err := games.GetSystem("SNES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	systems := []games.System{*snes}
+
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{"Zelda (USA).sfc", "F-Zero (USA).sfc", "Mario Paint (USA).sfc"} {
+		writeTestFile(t, filepath.Join(gamesFolder, "SNES", name), t1)
+	}
+
+	_, err = NewNamesIndex(cfg, systems, func(IndexStatus) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := SearchNamesFuzzy(systems, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+
+	want := []string{"F-Zero (USA)", "Mario Paint (USA)", "Zelda (USA)"}
+	if len(names) != len(want) || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Errorf("SearchNamesFuzzy() = %v, want %v", names, want)
+	}
+}
+
+func TestRandomGame(t *testing.T) {
+	cfg, gamesFolder := setupTestIndex(t)
+
+	snes, err := games.GetSystem("SNES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	systems := []games.System{*snes}
+
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []string{"Zelda (USA).sfc", "F-Zero (USA).sfc", "Mario Paint (USA).sfc"}
+	for _, name := range files {
+		writeTestFile(t, filepath.Join(gamesFolder, "SNES", name), t1)
+	}
+
+	_, err = NewNamesIndex(cfg, systems, func(IndexStatus) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		result, err := RandomGame(systems, WeightGames)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.SystemId != "SNES" || result.Name != strings.TrimSuffix(filepath.Base(result.Path), ".sfc") {
+			t.Fatalf("RandomGame() = %+v, want SNES file with its name", result)
+		}
+	}
+}
+
+func TestScannerChangedZipCase(t *testing.T) {
+	s := newScanner()
+
+	// "İ" is longer once lowercased, which used to shift the zip path
+	zipPath := "/media/fat/games/SNES/İ/Games.ZIP"
+	s.dirtyZips[zipPath] = struct{}{}
+
+	if !s.changed(zipPath + "/Zelda (USA).sfc") {
+		t.Errorf("changed() = false for a file in a changed zip")
+	}
+	if s.changed("/media/fat/games/SNES/İ/Other.zip/Zelda (USA).sfc") {
+		t.Errorf("changed() = true for a file in an unchanged zip")
+	}
+}
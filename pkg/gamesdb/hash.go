@@ -0,0 +1,288 @@
+package gamesdb
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/wizzomafizzo/mrext/pkg/games"
+)
+
+const (
+	HashCRC32 = "crc32"
+	HashMD5   = "md5"
+	HashSHA1  = "sha1"
+)
+
+// FileHashes contains the checksums of a game file's contents.
+type FileHashes struct {
+	CRC32 string `json:"crc32"`
+	MD5   string `json:"md5"`
+	SHA1  string `json:"sha1"`
+}
+
+// Return the hash index keys for every checksum of a file.
+func hashKeys(h *FileHashes, systemId string, path string) []string {
+	if h == nil {
+		return nil
+	}
+
+	suffix := nameSep + fileKey(systemId, path)
+	return []string{
+		HashCRC32 + ":" + h.CRC32 + suffix,
+		HashMD5 + ":" + h.MD5 + suffix,
+		HashSHA1 + ":" + h.SHA1 + suffix,
+	}
+}
+
+func hashReader(r io.Reader) (FileHashes, error) {
+	hc := crc32.NewIEEE()
+	hm := md5.New()
+	hs := sha1.New()
+
+	_, err := io.Copy(io.MultiWriter(hc, hm, hs), r)
+	if err != nil {
+		return FileHashes{}, err
+	}
+
+	return FileHashes{
+		CRC32: fmt.Sprintf("%08x", hc.Sum32()),
+		MD5:   fmt.Sprintf("%x", hm.Sum(nil)),
+		SHA1:  fmt.Sprintf("%x", hs.Sum(nil)),
+	}, nil
+}
+
+// Return the index of the first ".zip" folder in a path, matched without
+// case, or -1 if there is none. The path itself is searched instead of a
+// lowercase copy because lowercasing can change the length of a string.
+func zipIndex(path string) int {
+	sep := ".zip" + string(filepath.Separator)
+	for i := 0; i+len(sep) <= len(path); i++ {
+		if strings.EqualFold(path[i:i+len(sep)], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Split a game path into the zip file containing it and the member's name
+// inside the zip. Returns an empty zip path if the file is not in a zip.
+func splitZipPath(path string) (string, string) {
+	idx := zipIndex(path)
+	if idx < 0 {
+		return "", path
+	}
+
+	zipPath := path[:idx+4]
+	if info, err := os.Stat(zipPath); err != nil || info.IsDir() {
+		return "", path
+	}
+
+	return zipPath, path[idx+5:]
+}
+
+// Return the size and modification time of a game file on disk. For files
+// inside a zip, the stats of the zip file itself are returned.
+func statGameFile(path string) (int64, int64, error) {
+	zipPath, _ := splitZipPath(path)
+	if zipPath != "" {
+		path = zipPath
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return info.Size(), info.ModTime().UnixNano(), nil
+}
+
+// HashFile returns the checksums of a game file. Paths to files inside a zip
+// are supported, the zip member is streamed and never extracted to disk.
+func HashFile(path string) (FileHashes, error) {
+	zipPath, member := splitZipPath(path)
+	if zipPath != "" {
+		hashes, err := hashZipMembers(zipPath, []string{member})
+		if err != nil {
+			return FileHashes{}, err
+		}
+
+		h, ok := hashes[member]
+		if !ok {
+			return FileHashes{}, fmt.Errorf("file not found in zip: %s", member)
+		}
+
+		return h, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return FileHashes{}, err
+	}
+	defer f.Close()
+
+	return hashReader(f)
+}
+
+// Hash multiple members of a single zip file, returned by member name.
+// Members which can't be read are left out of the results.
+func hashZipMembers(zipPath string, members []string) (map[string]FileHashes, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	wanted := make(map[string]struct{}, len(members))
+	for _, m := range members {
+		wanted[m] = struct{}{}
+	}
+
+	hashes := make(map[string]FileHashes)
+	for _, f := range r.File {
+		if _, ok := wanted[f.Name]; !ok {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+
+		h, err := hashReader(rc)
+		rc.Close()
+		if err != nil {
+			continue
+		}
+
+		hashes[f.Name] = h
+	}
+
+	return hashes, nil
+}
+
+// Calculate checksums for a list of game files, returned by path. Files in
+// the same zip are hashed with the zip only opened once. Files which can't be
+// read are left out of the results.
+func hashGameFiles(paths []string) map[string]FileHashes {
+	hashes := make(map[string]FileHashes)
+	zips := make(map[string][]string)
+
+	for _, path := range paths {
+		zipPath, member := splitZipPath(path)
+		if zipPath != "" {
+			zips[zipPath] = append(zips[zipPath], member)
+			continue
+		}
+
+		h, err := HashFile(path)
+		if err != nil {
+			continue
+		}
+		hashes[path] = h
+	}
+
+	for zipPath, members := range zips {
+		zh, err := hashZipMembers(zipPath, members)
+		if err != nil {
+			continue
+		}
+
+		for member, h := range zh {
+			hashes[filepath.Join(zipPath, member)] = h
+		}
+	}
+
+	return hashes
+}
+
+// Return the hash algorithm for a hex encoded checksum, based on its length.
+func hashType(hash string) (string, error) {
+	switch len(hash) {
+	case 8:
+		return HashCRC32, nil
+	case 32:
+		return HashMD5, nil
+	case 40:
+		return HashSHA1, nil
+	default:
+		return "", fmt.Errorf("unknown hash type: %s", hash)
+	}
+}
+
+// FindByHash returns all indexed files with the given CRC32, MD5 or SHA1
+// checksum (hex encoded). The type of hash is detected from its length. Files
+// are only hashed if the hash_files option was enabled during indexing.
+func FindByHash(systems []games.System, hash string) ([]SearchResult, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	algo, err := hashType(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !DbExists() {
+		return nil, fmt.Errorf("gamesdb does not exist")
+	}
+
+	db, err := open(&bolt.Options{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var results []SearchResult
+
+	err = db.View(func(tx *bolt.Tx) error {
+		bh := tx.Bucket([]byte(BucketHashes))
+		bf := tx.Bucket([]byte(BucketFiles))
+		if bh == nil || bf == nil {
+			return nil
+		}
+
+		pre := []byte(algo + ":" + hash + nameSep)
+
+		c := bh.Cursor()
+		for k, _ := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, _ = c.Next() {
+			fk := string(k[len(pre):])
+			systemId, path, ok := strings.Cut(fk, ":")
+			if !ok {
+				continue
+			}
+
+			found := false
+			for _, system := range systems {
+				if system.Id == systemId {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+
+			name := fileName(path)
+			if fr, err := decodeFileRecord(bf.Get([]byte(fk))); err == nil {
+				name = fr.Name
+			}
+
+			results = append(results, newSearchResult(bf, systemId, name, path))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,123 @@
+package gamesdb
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// State of the DAT, metadata and media folders each system was last matched
+// against, so indexing only imports them again when something has changed.
+const importStateKey = "meta:importState"
+
+// Sources of game info which are imported after indexing.
+const (
+	importDatsSource     = "dats"
+	importMetadataSource = "metadata"
+	importMediaSource    = "media"
+)
+
+// Return a summary of the contents of some folders. If it's the same as the
+// last import, nothing has been added to, removed from or changed in them
+// since. Files are compared by their size and mtime. If dirs is set, only
+// sub folders and their mtimes are compared instead, which is enough to
+// spot files being added or removed in large media folders.
+func folderState(folders []string, dirs bool) string {
+	h := sha1.New()
+
+	for _, folder := range folders {
+		entries, err := os.ReadDir(folder)
+		if err != nil {
+			continue
+		}
+
+		fmt.Fprintf(h, "%s\n", folder)
+		for _, entry := range entries {
+			if entry.IsDir() != dirs {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+
+			fmt.Fprintf(
+				h,
+				"%s\x00%d\x00%d\n",
+				filepath.Join(folder, entry.Name()),
+				info.Size(),
+				info.ModTime().UnixNano(),
+			)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func importStateKeyFor(source string, systemId string) string {
+	return source + ":" + systemId
+}
+
+func readImportState(db *bolt.DB) (map[string]string, error) {
+	state := make(map[string]string)
+
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(BucketNames)).Get([]byte(importStateKey))
+		if v == nil {
+			return nil
+		}
+
+		// a bad record just means everything is imported again
+		_ = json.Unmarshal(v, &state)
+		return nil
+	})
+
+	return state, err
+}
+
+// Store the state of a source which systems were just imported from. Other
+// systems and sources are kept.
+func writeImportState(db *bolt.DB, source string, updated map[string]string) error {
+	if len(updated) == 0 {
+		return nil
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BucketNames))
+
+		state := make(map[string]string)
+		if v := b.Get([]byte(importStateKey)); v != nil {
+			_ = json.Unmarshal(v, &state)
+		}
+
+		for systemId, s := range updated {
+			state[importStateKeyFor(source, systemId)] = s
+		}
+
+		v, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(importStateKey), v)
+	})
+}
+
+// Report whether a system needs to be imported from a source again. Systems
+// are imported if changed is nil, their indexed files changed or the source
+// has changed since they were last imported from it.
+func needsImport(
+	state map[string]string,
+	source string,
+	current string,
+	systemId string,
+	changed map[string]bool,
+) bool {
+	return changed == nil || changed[systemId] || state[importStateKeyFor(source, systemId)] != current
+}
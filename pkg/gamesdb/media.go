@@ -0,0 +1,125 @@
+package gamesdb
+
+import (
+	"fmt"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/media"
+)
+
+// Return the folder media packs are read from.
+func mediaFolder(cfg *config.UserConfig) string {
+	if cfg.GamesDb.MediaFolder != "" {
+		return cfg.GamesDb.MediaFolder
+	}
+	return config.MediaFolder
+}
+
+// Match every indexed file of a system against the media index and update its
+// stored media paths. Files are matched by filename, then canonical DAT name
+// and then title without tags. Returns the number of matched files.
+func applyMedia(db *bolt.DB, idx media.Index, system games.System) (int, error) {
+	matched := 0
+
+	err := updateFileRecords(db, system.Id, func(path string, fr *fileRecord) bool {
+		names := []string{fileName(path)}
+		if fr.Info != nil {
+			names = append(names, fr.Info.Canonical, fr.Info.Title)
+		} else if fr.Tags != nil {
+			names = append(names, fr.Tags.Title)
+		}
+
+		found := idx.Match(names...)
+		if found != nil {
+			matched++
+		} else if fr.Media == nil {
+			return false
+		}
+
+		fr.Media = found
+		return true
+	})
+
+	return matched, err
+}
+
+// Find media for indexed files of the given systems in the media folder and
+// their games folders. Runs as part of every index.
+//
+// Only systems in changed, or with media folders which have changed since
+// they were last imported, are imported. If changed is nil, every system is
+// imported.
+func importMedia(
+	db *bolt.DB,
+	cfg *config.UserConfig,
+	systems []games.System,
+	changed map[string]bool,
+) (int, error) {
+	systemPaths := make(map[string][]string)
+	for _, v := range games.GetSystemPaths(cfg, systems) {
+		systemPaths[v.System.Id] = append(systemPaths[v.System.Id], v.Path)
+	}
+
+	state, err := readImportState(db)
+	if err != nil {
+		return 0, fmt.Errorf("error reading import state: %s", err)
+	}
+
+	matched := 0
+	updated := make(map[string]string)
+	for _, system := range systems {
+		roots := append([]string{filepath.Join(mediaFolder(cfg), system.Id)}, systemPaths[system.Id]...)
+
+		// the mtimes of the media type folders change when images are
+		// added or removed
+		current := folderState(roots, true)
+		if !needsImport(state, importMediaSource, current, system.Id, changed) {
+			continue
+		}
+
+		count, err := applyMedia(db, media.ReadFolders(roots), system)
+		matched += count
+		if err != nil {
+			return matched, err
+		}
+		updated[system.Id] = current
+	}
+
+	err = writeImportState(db, importMediaSource, updated)
+	if err != nil {
+		return matched, fmt.Errorf("error writing import state: %s", err)
+	}
+
+	return matched, nil
+}
+
+// ImportMedia finds box art, screenshots and title screens for all indexed
+// files of the given systems and stores the paths to them. Images are found
+// in media type folders (e.g. boxart, snap, titles) of the system's folder in
+// the media folder or its games folders, named after the file, its canonical
+// DAT name or its title. This is also done automatically when indexing.
+//
+// Returns the number of files with any media found.
+func ImportMedia(cfg *config.UserConfig, systems []games.System) (int, error) {
+	db, err := openNames()
+	if err != nil {
+		return 0, fmt.Errorf("error opening gamesdb: %s", err)
+	}
+	defer db.Close()
+
+	matched, err := importMedia(db, cfg, systems, nil)
+	if err != nil {
+		return matched, err
+	}
+
+	err = db.Sync()
+	if err != nil {
+		return matched, fmt.Errorf("error syncing database: %s", err)
+	}
+
+	return matched, nil
+}
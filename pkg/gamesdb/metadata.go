@@ -0,0 +1,158 @@
+package gamesdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
+)
+
+// Return the folder metadata files are read from.
+func metadataFolder(cfg *config.UserConfig) string {
+	if cfg.GamesDb.MetadataFolder != "" {
+		return cfg.GamesDb.MetadataFolder
+	}
+	return config.MetadataFolder
+}
+
+// Match a file against the metadata index, by hash if it has been hashed,
+// then by filename and finally by its canonical name from a DAT file.
+func matchMetadata(idx *metadata.Index, path string, fr fileRecord) (*metadata.GameMetadata, bool) {
+	if fr.Hashes != nil {
+		meta, ok := idx.MatchHash(fr.Hashes.SHA1, fr.Hashes.MD5, fr.Hashes.CRC32)
+		if ok {
+			return meta, true
+		}
+	}
+
+	if meta, ok := idx.MatchName(path); ok {
+		return meta, true
+	}
+
+	if fr.Info != nil {
+		return idx.MatchName(fr.Info.Canonical)
+	}
+
+	return nil, false
+}
+
+// Match every indexed file of a system against the metadata index and update
+// its stored metadata. Returns the number of matched files.
+func applyMetadata(db *bolt.DB, idx *metadata.Index, system games.System) (int, error) {
+	matched := 0
+
+	err := updateFileRecords(db, system.Id, func(path string, fr *fileRecord) bool {
+		meta, ok := matchMetadata(idx, path, *fr)
+		if ok {
+			matched++
+		} else if fr.Meta == nil {
+			return false
+		}
+
+		fr.Meta = meta
+		return true
+	})
+
+	return matched, err
+}
+
+// Return true if there are any metadata files available to import.
+func metadataAvailable(cfg *config.UserConfig) bool {
+	entries, err := os.ReadDir(metadataFolder(cfg))
+	return err == nil && len(entries) > 0
+}
+
+// Read all metadata files from the metadata folder and match them against
+// indexed files of the given systems. Runs as part of indexing if the
+// metadata folder has files.
+//
+// Only systems in changed, or which haven't been matched against the current
+// metadata files, are imported. If changed is nil, every system is imported.
+func importMetadata(
+	db *bolt.DB,
+	cfg *config.UserConfig,
+	systems []games.System,
+	changed map[string]bool,
+) (int, error) {
+	folder := metadataFolder(cfg)
+
+	state, err := readImportState(db)
+	if err != nil {
+		return 0, fmt.Errorf("error reading import state: %s", err)
+	}
+
+	updated := make(map[string]string)
+	var imports []games.System
+	for _, system := range systems {
+		current := folderState([]string{folder, filepath.Join(folder, system.Id)}, false)
+		if needsImport(state, importMetadataSource, current, system.Id, changed) {
+			imports = append(imports, system)
+			updated[system.Id] = current
+		}
+	}
+
+	if len(imports) == 0 {
+		return 0, nil
+	}
+
+	shared, err := metadata.ReadFolder(folder)
+	if err != nil {
+		return 0, fmt.Errorf("error reading metadata folder: %s", err)
+	}
+
+	matched := 0
+	for _, system := range imports {
+		// system files are added last so they replace shared entries
+		entries := shared
+		if found, err := metadata.ReadFolder(filepath.Join(folder, system.Id)); err == nil {
+			entries = append(append([]metadata.Entry{}, shared...), found...)
+		}
+
+		count, err := applyMetadata(db, metadata.NewIndex(entries), system)
+		matched += count
+		if err != nil {
+			return matched, err
+		}
+	}
+
+	err = writeImportState(db, importMetadataSource, updated)
+	if err != nil {
+		return matched, fmt.Errorf("error writing import state: %s", err)
+	}
+
+	return matched, nil
+}
+
+// ImportMetadata matches all indexed files of the given systems against the
+// EmulationStation gamelist.xml, CSV and JSON files in the metadata folder,
+// storing the genre, year, developer, publisher, players and description of
+// each match. Files are matched by hash if they were hashed during indexing,
+// otherwise by filename or canonical DAT name. Files which are no longer
+// matched have their metadata removed. This is also done automatically when
+// indexing if the metadata folder has files.
+//
+// Returns the number of files matched.
+func ImportMetadata(cfg *config.UserConfig, systems []games.System) (int, error) {
+	db, err := openNames()
+	if err != nil {
+		return 0, fmt.Errorf("error opening gamesdb: %s", err)
+	}
+	defer db.Close()
+
+	matched, err := importMetadata(db, cfg, systems, nil)
+	if err != nil {
+		return matched, err
+	}
+
+	err = db.Sync()
+	if err != nil {
+		return matched, fmt.Errorf("error syncing database: %s", err)
+	}
+
+	return matched, nil
+}
@@ -0,0 +1,271 @@
+package gamesdb
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+// Number of indexed files for each system, updated after indexing so picking
+// random games and paging don't need to count every file.
+const systemCountsKey = "meta:systemCounts"
+
+// RandomWeight sets how systems are chosen when picking a random game.
+type RandomWeight int
+
+const (
+	// Every system has an equal chance of being picked, regardless of how
+	// many games it has.
+	WeightSystems RandomWeight = iota
+	// Systems are picked based on how many games they have, so every game
+	// has an equal chance of being picked.
+	WeightGames
+)
+
+// Count the files of every system by reading the whole files bucket. Used
+// for DBs indexed before counts were stored.
+func countSystemFiles(tx *bolt.Tx) map[string]int {
+	counts := make(map[string]int)
+
+	_ = tx.Bucket([]byte(BucketFiles)).ForEach(func(k, _ []byte) error {
+		if systemId, _, ok := strings.Cut(string(k), ":"); ok {
+			counts[systemId]++
+		}
+		return nil
+	})
+
+	return counts
+}
+
+func readSystemCounts(tx *bolt.Tx) map[string]int {
+	v := tx.Bucket([]byte(BucketNames)).Get([]byte(systemCountsKey))
+	if v == nil {
+		return countSystemFiles(tx)
+	}
+
+	var counts map[string]int
+	if err := json.Unmarshal(v, &counts); err != nil {
+		return countSystemFiles(tx)
+	}
+
+	return counts
+}
+
+// Store updated file counts for the given systems. A count of 0 removes the
+// system. Other systems are kept.
+func writeSystemCounts(db *bolt.DB, updated map[string]int) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		counts := readSystemCounts(tx)
+		for systemId, count := range updated {
+			if count == 0 {
+				delete(counts, systemId)
+			} else {
+				counts[systemId] = count
+			}
+		}
+
+		v, err := json.Marshal(counts)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte(BucketNames)).Put([]byte(systemCountsKey), v)
+	})
+}
+
+// SystemCounts returns the number of indexed files for each system.
+func SystemCounts() (map[string]int, error) {
+	if !DbExists() {
+		return nil, fmt.Errorf("gamesdb does not exist")
+	}
+
+	db, err := open(&bolt.Options{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var counts map[string]int
+	err = db.View(func(tx *bolt.Tx) error {
+		counts = readSystemCounts(tx)
+		return nil
+	})
+
+	return counts, err
+}
+
+// Pick a system with indexed files from the list, using the given weighting.
+// Returns an empty string if none of the systems have files.
+func pickSystem(systems []games.System, counts map[string]int, weight RandomWeight) string {
+	var ids []string
+	total := 0
+	for _, system := range systems {
+		if counts[system.Id] > 0 {
+			ids = append(ids, system.Id)
+			total += counts[system.Id]
+		}
+	}
+
+	if len(ids) == 0 {
+		return ""
+	}
+
+	if weight == WeightSystems {
+		id, _ := utils.RandomElem(ids)
+		return id
+	}
+
+	n := utils.RandomInt(total)
+	for _, id := range ids {
+		if n < counts[id] {
+			return id
+		}
+		n -= counts[id]
+	}
+
+	return ids[len(ids)-1]
+}
+
+// RandomGame returns a random indexed game from one of the given systems.
+// The weight sets whether each system or each game has an equal chance of
+// being picked.
+func RandomGame(systems []games.System, weight RandomWeight) (SearchResult, error) {
+	if !DbExists() {
+		return SearchResult{}, fmt.Errorf("gamesdb does not exist")
+	}
+
+	db, err := open(&bolt.Options{ReadOnly: true})
+	if err != nil {
+		return SearchResult{}, err
+	}
+	defer db.Close()
+
+	var result SearchResult
+	found := false
+
+	err = db.View(func(tx *bolt.Tx) error {
+		bf := tx.Bucket([]byte(BucketFiles))
+		ba := tx.Bucket([]byte(BucketRandom))
+
+		counts := readSystemCounts(tx)
+		systemId := pickSystem(systems, counts, weight)
+		if systemId == "" {
+			return nil
+		}
+
+		// seek to a random hash and take the next file, wrapping around to
+		// the system's first file past the last one
+		pre := []byte(systemId + ":")
+		target := make([]byte, sha1.Size)
+		for i := range target {
+			target[i] = byte(utils.RandomInt(256))
+		}
+
+		c := ba.Cursor()
+		k, v := c.Seek(append(pre, hex.EncodeToString(target)...))
+		if k == nil || !bytes.HasPrefix(k, pre) {
+			k, v = c.Seek(pre)
+		}
+
+		if k == nil || !bytes.HasPrefix(k, pre) {
+			return nil
+		}
+
+		path := string(v)
+		name := fileName(path)
+		if fr, err := decodeFileRecord(bf.Get([]byte(fileKey(systemId, path)))); err == nil {
+			name = fr.Name
+		}
+
+		result = newSearchResult(bf, systemId, name, path)
+		result.Score = 1
+		found = true
+
+		return nil
+	})
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	if !found {
+		return SearchResult{}, fmt.Errorf("no indexed games found")
+	}
+
+	return result, nil
+}
+
+// ListGames returns a page of indexed games, sorted by system in the order
+// given and then by name. Starts at offset and returns at most limit games,
+// along with the total number of games in the systems.
+func ListGames(systems []games.System, offset int, limit int) ([]SearchResult, int, error) {
+	if !DbExists() {
+		return nil, 0, fmt.Errorf("gamesdb does not exist")
+	}
+
+	db, err := open(&bolt.Options{ReadOnly: true})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer db.Close()
+
+	var results []SearchResult
+	total := 0
+
+	err = db.View(func(tx *bolt.Tx) error {
+		bn := tx.Bucket([]byte(BucketNames))
+		bf := tx.Bucket([]byte(BucketFiles))
+		counts := readSystemCounts(tx)
+
+		skip := offset
+		for _, system := range systems {
+			count := counts[system.Id]
+			total += count
+
+			// skip whole systems without reading them
+			if len(results) >= limit {
+				continue
+			} else if skip >= count {
+				skip -= count
+				continue
+			}
+
+			pre := []byte(system.Id + ":")
+
+			c := bn.Cursor()
+			for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
+				if skip > 0 {
+					skip--
+					continue
+				}
+
+				if len(results) >= limit {
+					break
+				}
+
+				name := string(k[len(pre):])
+				if i := strings.Index(name, nameSep); i >= 0 {
+					name = name[:i]
+				}
+
+				result := newSearchResult(bf, system.Id, name, string(v))
+				result.Score = 1
+				results = append(results, result)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
@@ -0,0 +1,215 @@
+package gamesdb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+// Cached directory listing. If the directory's mtime hasn't changed since it
+// was recorded, the listing is reused instead of reading the directory again.
+type folderRecord struct {
+	ModTime int64    `json:"mtime"`
+	Files   []string `json:"files"`
+	Dirs    []string `json:"dirs"`
+}
+
+// Cached zip file listing, invalidated if the size or mtime of the zip
+// changes.
+type zipRecord struct {
+	Size    int64    `json:"size"`
+	ModTime int64    `json:"mtime"`
+	Files   []string `json:"files"`
+}
+
+// Walks system folders using the folder and zip caches stored in the gamesdb.
+// The same scanner should be used for every system in an index run so shared
+// folders are only read once.
+type scanner struct {
+	folders      map[string]folderRecord
+	zips         map[string]zipRecord
+	seenFolders  map[string]struct{}
+	seenZips     map[string]struct{}
+	dirtyFolders map[string]struct{}
+	dirtyZips    map[string]struct{}
+	roots        []string
+}
+
+func newScanner() *scanner {
+	return &scanner{
+		folders:      make(map[string]folderRecord),
+		zips:         make(map[string]zipRecord),
+		seenFolders:  make(map[string]struct{}),
+		seenZips:     make(map[string]struct{}),
+		dirtyFolders: make(map[string]struct{}),
+		dirtyZips:    make(map[string]struct{}),
+	}
+}
+
+// Load existing folder and zip records from the DB.
+func (s *scanner) load(db *bolt.DB) error {
+	return db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket([]byte(BucketFolders)).ForEach(func(k, v []byte) error {
+			var fr folderRecord
+			if err := json.Unmarshal(v, &fr); err != nil {
+				// bad records are just rescanned
+				return nil
+			}
+			s.folders[string(k)] = fr
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte(BucketZips)).ForEach(func(k, v []byte) error {
+			var zr zipRecord
+			if err := json.Unmarshal(v, &zr); err != nil {
+				return nil
+			}
+			s.zips[string(k)] = zr
+			return nil
+		})
+	})
+}
+
+// Write changed records back to the DB and remove records for any folders
+// or zips under a scanned root which no longer exist.
+func (s *scanner) save(db *bolt.DB) error {
+	underRoot := func(path string) bool {
+		for _, root := range s.roots {
+			if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bf := tx.Bucket([]byte(BucketFolders))
+		for path := range s.dirtyFolders {
+			v, err := json.Marshal(s.folders[path])
+			if err != nil {
+				return err
+			}
+			if err := bf.Put([]byte(path), v); err != nil {
+				return err
+			}
+		}
+		for path := range s.folders {
+			if _, ok := s.seenFolders[path]; !ok && underRoot(path) {
+				if err := bf.Delete([]byte(path)); err != nil {
+					return err
+				}
+			}
+		}
+
+		bz := tx.Bucket([]byte(BucketZips))
+		for path := range s.dirtyZips {
+			v, err := json.Marshal(s.zips[path])
+			if err != nil {
+				return err
+			}
+			if err := bz.Put([]byte(path), v); err != nil {
+				return err
+			}
+		}
+		for path := range s.zips {
+			if _, ok := s.seenZips[path]; !ok && underRoot(path) {
+				if err := bz.Delete([]byte(path)); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// ReadFolder returns the listing of a directory, from the cache if it's still
+// valid. Implements games.DirLister.
+func (s *scanner) ReadFolder(path string) ([]string, []string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.seenFolders[path] = struct{}{}
+	mtime := info.ModTime().UnixNano()
+
+	if fr, ok := s.folders[path]; ok && fr.ModTime == mtime {
+		return fr.Files, fr.Dirs, nil
+	}
+
+	files, dirs, err := games.DiskLister{}.ReadFolder(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.folders[path] = folderRecord{
+		ModTime: mtime,
+		Files:   files,
+		Dirs:    dirs,
+	}
+	s.dirtyFolders[path] = struct{}{}
+
+	return files, dirs, nil
+}
+
+// ReadZip returns the contents of a zip file, from the cache if it's still
+// valid. Implements games.DirLister.
+func (s *scanner) ReadZip(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.seenZips[path] = struct{}{}
+	size := info.Size()
+	mtime := info.ModTime().UnixNano()
+
+	if zr, ok := s.zips[path]; ok && zr.Size == size && zr.ModTime == mtime {
+		return zr.Files, nil
+	}
+
+	files, err := utils.ListZip(path)
+	if err != nil {
+		// invalid zips are cached too so they aren't opened every run
+		files = nil
+	}
+
+	s.zips[path] = zipRecord{
+		Size:    size,
+		ModTime: mtime,
+		Files:   files,
+	}
+	s.dirtyZips[path] = struct{}{}
+
+	return files, nil
+}
+
+// Report whether the folder or zip containing a game file was read from disk
+// again during this scan, so the file may have changed since it was indexed.
+func (s *scanner) changed(path string) bool {
+	if idx := zipIndex(path); idx >= 0 {
+		if _, ok := s.dirtyZips[path[:idx+4]]; ok {
+			return true
+		}
+	}
+
+	_, ok := s.dirtyFolders[filepath.Dir(path)]
+	return ok
+}
+
+// Return all valid game files for a system in the given folder, only reading
+// folders and zips which have changed since the last scan.
+func (s *scanner) systemFiles(system games.System, root string) ([]string, error) {
+	s.roots = append(s.roots, root)
+	return games.GetFilesWith(s, system.Id, root)
+}
@@ -0,0 +1,289 @@
+package gamesdb
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/service"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+// How long to wait for file changes to settle before updating the index.
+// Copying a collection creates a lot of events in a short time.
+const watchDelay = 3 * time.Second
+
+// Watcher monitors the active games folders of all systems and keeps the
+// names index up to date as files are added, removed or renamed.
+type Watcher struct {
+	logger   *service.Logger
+	cfg      *config.UserConfig
+	fsw      *fsnotify.Watcher
+	onUpdate func(IndexStatus, error)
+
+	mu      sync.Mutex
+	roots   map[string][]string // system folder -> system ids
+	watched map[string]struct{}
+	dirty   map[string]struct{}
+	timer   *time.Timer
+	closed  bool
+	full    bool // inotify watch limit was reached
+	running sync.Mutex
+}
+
+// NewWatcher starts watching the active system folders. The onUpdate func is
+// called with the final status each time the index is updated. Changes made
+// while nothing was watching are not picked up until the index is next
+// updated manually.
+func NewWatcher(logger *service.Logger, cfg *config.UserConfig, onUpdate func(IndexStatus, error)) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		logger:   logger,
+		cfg:      cfg,
+		fsw:      fsw,
+		onUpdate: onUpdate,
+		roots:    make(map[string][]string),
+		watched:  make(map[string]struct{}),
+		dirty:    make(map[string]struct{}),
+	}
+
+	go w.listen()
+
+	w.refresh(false)
+
+	return w, nil
+}
+
+// Refresh checks for new or missing system folders, for example when a USB
+// drive or network share is mounted, and updates the watched folders. Systems
+// with folders which were added or removed are queued for an index update.
+func (w *Watcher) Refresh() {
+	w.refresh(true)
+}
+
+func (w *Watcher) refresh(queue bool) {
+	roots := make(map[string][]string)
+	for _, r := range games.GetActiveSystemPaths(w.cfg, games.AllSystems()) {
+		roots[r.Path] = append(roots[r.Path], r.System.Id)
+	}
+
+	w.mu.Lock()
+	var added []string
+	for root := range roots {
+		if _, ok := w.roots[root]; !ok {
+			added = append(added, root)
+		}
+	}
+	w.mu.Unlock()
+
+	// walking large folders is slow, so it's done without holding the lock
+	dirs := make(map[string][]string)
+	for _, root := range added {
+		dirs[root] = listDirs(root)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	for root, ids := range w.roots {
+		if _, ok := roots[root]; !ok {
+			w.unwatch(root)
+			if queue {
+				w.markDirty(ids)
+			}
+		}
+	}
+
+	for root, ids := range roots {
+		if _, ok := w.roots[root]; !ok {
+			w.watch(dirs[root])
+			if queue {
+				w.markDirty(ids)
+			}
+		}
+	}
+
+	w.roots = roots
+}
+
+// Close stops watching folders. An index update already in progress will
+// still finish.
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	w.stopTimer()
+	w.mu.Unlock()
+
+	return w.fsw.Close()
+}
+
+// Return a folder and all its sub folders.
+func listDirs(root string) []string {
+	var dirs []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs
+}
+
+// Add a watch for each folder. Once the system's inotify watch limit is
+// reached, no more watches are added.
+func (w *Watcher) watch(dirs []string) {
+	for i, path := range dirs {
+		if w.full {
+			return
+		}
+
+		if _, ok := w.watched[path]; ok {
+			continue
+		}
+
+		err := w.fsw.Add(path)
+		if errors.Is(err, syscall.ENOSPC) {
+			w.full = true
+			w.logger.Warn(
+				"index watcher: inotify watch limit reached, %d folders are not watched (see fs.inotify.max_user_watches)",
+				len(dirs)-i,
+			)
+			return
+		} else if err == nil {
+			w.watched[path] = struct{}{}
+		}
+	}
+}
+
+// Remove watches for a folder and all its sub folders.
+func (w *Watcher) unwatch(root string) {
+	for path := range w.watched {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			_ = w.fsw.Remove(path)
+			delete(w.watched, path)
+		}
+	}
+}
+
+// Return the systems which use the system folder containing path.
+func (w *Watcher) systemsFor(path string) []string {
+	var ids []string
+	for root, rootIds := range w.roots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			ids = append(ids, rootIds...)
+		}
+	}
+	return ids
+}
+
+// Queue systems for an index update, which will run once no more changes
+// have happened for the watch delay.
+func (w *Watcher) markDirty(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	for _, id := range ids {
+		w.dirty[id] = struct{}{}
+	}
+
+	if w.timer == nil {
+		w.timer = time.AfterFunc(watchDelay, w.update)
+	} else {
+		w.timer.Reset(watchDelay)
+	}
+}
+
+func (w *Watcher) stopTimer() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
+
+// Run an incremental index update for all queued systems.
+func (w *Watcher) update() {
+	w.running.Lock()
+	defer w.running.Unlock()
+
+	w.mu.Lock()
+	w.timer = nil
+	if w.closed || len(w.dirty) == 0 || !DbExists() {
+		w.mu.Unlock()
+		return
+	}
+	ids := utils.MapKeys(w.dirty)
+	w.dirty = make(map[string]struct{})
+	w.mu.Unlock()
+
+	var systems []games.System
+	for _, id := range ids {
+		system, err := games.GetSystem(id)
+		if err != nil {
+			continue
+		}
+		systems = append(systems, *system)
+	}
+
+	var final IndexStatus
+	_, err := UpdateNamesIndex(w.cfg, systems, func(status IndexStatus) {
+		final = status
+	})
+
+	if w.onUpdate != nil {
+		w.onUpdate(final, err)
+	}
+}
+
+func (w *Watcher) listen() {
+	for {
+		select {
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			var dirs []string
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				info, err := os.Stat(event.Name)
+				if err == nil && info.IsDir() {
+					dirs = listDirs(event.Name)
+				}
+			}
+
+			w.mu.Lock()
+			if len(dirs) > 0 {
+				w.watch(dirs)
+			} else if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				w.unwatch(event.Name)
+			}
+			w.markDirty(w.systemsFor(event.Name))
+			w.mu.Unlock()
+		case _, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
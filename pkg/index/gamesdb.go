@@ -0,0 +1,58 @@
+package index
+
+import (
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+)
+
+// The default bolt database index, supports incremental updates, hashes and
+// DAT matching.
+type gamesDbIndex struct {
+	cfg *config.UserConfig
+}
+
+func (idx *gamesDbIndex) Exists() bool {
+	return gamesdb.DbExists()
+}
+
+func (idx *gamesDbIndex) Generate(systems []games.System, update func(gamesdb.IndexStatus)) (int, error) {
+	return gamesdb.NewNamesIndex(idx.cfg, systems, update)
+}
+
+func (idx *gamesDbIndex) Update(systems []games.System, update func(gamesdb.IndexStatus)) (int, error) {
+	return gamesdb.UpdateNamesIndex(idx.cfg, systems, update)
+}
+
+func (idx *gamesDbIndex) Search(systems []games.System, query string) ([]gamesdb.SearchResult, error) {
+	return gamesdb.SearchNamesFuzzy(systems, query)
+}
+
+func (idx *gamesDbIndex) SearchRegexp(systems []games.System, pattern string) ([]gamesdb.SearchResult, error) {
+	return gamesdb.SearchNamesRegexp(systems, pattern)
+}
+
+func (idx *gamesDbIndex) Releases(systemId string, key string) ([]gamesdb.SearchResult, error) {
+	return gamesdb.SearchReleases(systemId, key)
+}
+
+func (idx *gamesDbIndex) Systems() ([]string, error) {
+	return gamesdb.IndexedSystems()
+}
+
+func (idx *gamesDbIndex) Stats() (Stats, error) {
+	counts, err := gamesdb.SystemCounts()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return newStats(counts), nil
+}
+
+func (idx *gamesDbIndex) Random(systems []games.System, weight gamesdb.RandomWeight) (gamesdb.SearchResult, error) {
+	return gamesdb.RandomGame(systems, weight)
+}
+
+func (idx *gamesDbIndex) List(systems []games.System, offset int, limit int) ([]gamesdb.SearchResult, int, error) {
+	return gamesdb.ListGames(systems, offset, limit)
+}
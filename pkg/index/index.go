@@ -0,0 +1,111 @@
+// Package index provides a common interface to the different formats a games
+// index can be stored in, so apps can search and pick games without caring
+// which one is in use.
+package index
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+)
+
+// Backend names used by the backend option in the gamesdb config section.
+const (
+	BackendGamesDb = "gamesdb"
+	BackendTxt     = "txt"
+	BackendSqlite  = "sqlite"
+)
+
+// Stats contains the number of indexed games.
+type Stats struct {
+	Total   int
+	Systems map[string]int
+}
+
+// Index is a searchable list of every game file found in the games folders.
+type Index interface {
+	// Exists returns true if the index has been generated.
+	Exists() bool
+	// Generate creates a new index of the given systems, replacing any
+	// existing index. Returns the total number of files indexed.
+	Generate(systems []games.System, update func(gamesdb.IndexStatus)) (int, error)
+	// Update refreshes the given systems in an existing index, or creates
+	// a new one. Other systems in the index are kept. Returns the total
+	// number of files indexed for the given systems.
+	Update(systems []games.System, update func(gamesdb.IndexStatus)) (int, error)
+	// Search returns games which loosely match every word in query, sorted
+	// by relevance. An empty query returns every game, sorted by name. See
+	// gamesdb.SearchNamesFuzzy.
+	Search(systems []games.System, query string) ([]gamesdb.SearchResult, error)
+	// SearchRegexp returns games with a name matching a regular expression.
+	// An invalid expression returns its compile error.
+	SearchRegexp(systems []games.System, pattern string) ([]gamesdb.SearchResult, error)
+	// Releases returns every game in a system with the given dedupe key,
+	// which are all the releases of the same game. See games.DedupeKey.
+	Releases(systemId string, key string) ([]gamesdb.SearchResult, error)
+	// Systems returns the IDs of all indexed systems.
+	Systems() ([]string, error)
+	// Stats returns the number of indexed games.
+	Stats() (Stats, error)
+	// Random returns a random game from one of the given systems. The
+	// weight sets whether each system or each game has an equal chance of
+	// being picked.
+	Random(systems []games.System, weight gamesdb.RandomWeight) (gamesdb.SearchResult, error)
+	// List returns a page of games from the given systems, sorted by system
+	// in the order given and then by name, starting at offset and with at
+	// most limit games. Also returns the total number of games.
+	List(systems []games.System, offset int, limit int) ([]gamesdb.SearchResult, int, error)
+}
+
+// New returns the index set by the backend option in the user config. The
+// gamesdb is used by default.
+func New(cfg *config.UserConfig) (Index, error) {
+	if UsesGamesDb(cfg) {
+		return &gamesDbIndex{cfg: cfg}, nil
+	}
+
+	switch strings.ToLower(cfg.GamesDb.Backend) {
+	case BackendTxt:
+		return newTxtIndex(cfg), nil
+	case BackendSqlite:
+		return newSqlIndex(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown index backend: %s", cfg.GamesDb.Backend)
+	}
+}
+
+// UsesGamesDb returns true if the user config selects the gamesdb backend,
+// which is the only one storing hashes, DAT info, metadata and media.
+func UsesGamesDb(cfg *config.UserConfig) bool {
+	backend := strings.ToLower(cfg.GamesDb.Backend)
+	return backend == "" || backend == BackendGamesDb
+}
+
+func newStats(counts map[string]int) Stats {
+	stats := Stats{Systems: counts}
+	for _, count := range counts {
+		stats.Total += count
+	}
+	return stats
+}
+
+// Page returns the results from offset up to at most limit results.
+func Page(results []gamesdb.SearchResult, offset int, limit int) []gamesdb.SearchResult {
+	if offset < 0 {
+		offset = 0
+	}
+
+	if offset >= len(results) || limit <= 0 {
+		return []gamesdb.SearchResult{}
+	}
+
+	end := offset + limit
+	if end > len(results) {
+		end = len(results)
+	}
+
+	return results[offset:end]
+}
@@ -0,0 +1,411 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/sqlindex"
+	"github.com/wizzomafizzo/mrext/pkg/txtindex"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+// A simple index which is read and written as a whole list of system ID and
+// path pairs. Updates rescan every folder of the updated systems, and
+// searches load the entire list. The loaded list is kept until the index
+// file changes.
+type listIndex struct {
+	cfg    *config.UserConfig
+	path   string
+	exists func() bool
+	load   func() ([][2]string, error)
+	write  func([][2]string) error
+
+	mu         sync.Mutex
+	cached     [][2]string
+	cachedTime time.Time
+	cachedSize int64
+}
+
+func newTxtIndex(cfg *config.UserConfig) *listIndex {
+	return &listIndex{
+		cfg:    cfg,
+		path:   config.SearchDbFile,
+		exists: txtindex.Exists,
+		load: func() ([][2]string, error) {
+			idx, err := txtindex.Open(config.SearchDbFile)
+			if err != nil {
+				return nil, err
+			}
+
+			var files [][2]string
+			for _, system := range idx.Systems() {
+				for _, f := range idx.SystemFiles(system) {
+					files = append(files, [2]string{f.System, f.Path})
+				}
+			}
+
+			return files, nil
+		},
+		write: func(files [][2]string) error {
+			return txtindex.Generate(files, config.SearchDbFile)
+		},
+	}
+}
+
+func newSqlIndex(cfg *config.UserConfig) *listIndex {
+	return &listIndex{
+		cfg:    cfg,
+		path:   config.SearchSqliteFile,
+		exists: sqlindex.Exists,
+		load: func() ([][2]string, error) {
+			rows, err := sqlindex.AllGames()
+			if err != nil {
+				return nil, err
+			}
+
+			files := make([][2]string, 0, len(rows))
+			for _, g := range rows {
+				files = append(files, [2]string{g.System, g.Path})
+			}
+
+			return files, nil
+		},
+		write: func(files [][2]string) error {
+			return sqlindex.Generate(files, func(int) {})
+		},
+	}
+}
+
+// Read all game files on disk for the given systems.
+func scanFiles(
+	cfg *config.UserConfig,
+	systems []games.System,
+	status *gamesdb.IndexStatus,
+	update func(gamesdb.IndexStatus),
+) [][2]string {
+	update(*status)
+
+	systemPaths := make(map[string][]string)
+	for _, v := range games.GetSystemPaths(cfg, systems) {
+		systemPaths[v.System.Id] = append(systemPaths[v.System.Id], v.Path)
+	}
+
+	var files [][2]string
+	for _, system := range systems {
+		paths, ok := systemPaths[system.Id]
+		if !ok {
+			continue
+		}
+
+		status.SystemId = system.Id
+		status.Step++
+		update(*status)
+
+		for _, path := range paths {
+			found, err := games.GetFiles(system.Id, path)
+			if err != nil {
+				continue
+			}
+
+			for _, f := range found {
+				files = append(files, [2]string{system.Id, f})
+			}
+		}
+	}
+
+	status.Files = len(files)
+
+	return files
+}
+
+// Return every indexed file. The list is only loaded again if the index
+// file's modified time or size has changed since it was last loaded, so
+// picking random games doesn't parse the whole index every time.
+func (idx *listIndex) files() ([][2]string, error) {
+	if idx.path == "" {
+		return idx.load()
+	}
+
+	info, err := os.Stat(idx.path)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.cached != nil && info.ModTime().Equal(idx.cachedTime) && info.Size() == idx.cachedSize {
+		return idx.cached, nil
+	}
+
+	files, err := idx.load()
+	if err != nil {
+		return nil, err
+	}
+
+	idx.cached = files
+	idx.cachedTime = info.ModTime()
+	idx.cachedSize = info.Size()
+
+	return files, nil
+}
+
+// Replace the index with a new list of files.
+func (idx *listIndex) save(files [][2]string) error {
+	idx.mu.Lock()
+	idx.cached = nil
+	idx.mu.Unlock()
+
+	return idx.write(files)
+}
+
+func (idx *listIndex) Exists() bool {
+	return idx.exists()
+}
+
+func (idx *listIndex) Generate(systems []games.System, update func(gamesdb.IndexStatus)) (int, error) {
+	status := gamesdb.IndexStatus{
+		Total: len(systems) + 1,
+		Step:  1,
+	}
+
+	files := scanFiles(idx.cfg, systems, &status, update)
+	status.Added = len(files)
+
+	status.Step++
+	status.SystemId = ""
+	update(status)
+
+	err := idx.save(files)
+	if err != nil {
+		return status.Files, fmt.Errorf("error writing index: %s", err)
+	}
+
+	return status.Files, nil
+}
+
+func (idx *listIndex) Update(systems []games.System, update func(gamesdb.IndexStatus)) (int, error) {
+	if !idx.exists() {
+		return idx.Generate(systems, update)
+	}
+
+	existing, err := idx.files()
+	if err != nil {
+		return 0, fmt.Errorf("error reading index: %s", err)
+	}
+
+	status := gamesdb.IndexStatus{
+		Total: len(systems) + 1,
+		Step:  1,
+	}
+
+	updated := make(map[string]struct{})
+	for _, system := range systems {
+		updated[system.Id] = struct{}{}
+	}
+
+	// keep systems which aren't being updated
+	var files [][2]string
+	previous := make(map[[2]string]struct{})
+	for _, f := range existing {
+		if _, ok := updated[f[0]]; ok {
+			previous[f] = struct{}{}
+		} else {
+			files = append(files, f)
+		}
+	}
+
+	scanned := scanFiles(idx.cfg, systems, &status, update)
+	for _, f := range scanned {
+		if _, ok := previous[f]; ok {
+			delete(previous, f)
+			status.Unchanged++
+		} else {
+			status.Added++
+		}
+	}
+	status.Removed = len(previous)
+
+	status.Step++
+	status.SystemId = ""
+	update(status)
+
+	err = idx.save(append(files, scanned...))
+	if err != nil {
+		return status.Files, fmt.Errorf("error writing index: %s", err)
+	}
+
+	return status.Files, nil
+}
+
+// Return every indexed file in the given systems which is given a score
+// greater than 0 by the score func.
+func (idx *listIndex) searchScored(
+	systems []games.System,
+	score func(string) float64,
+) ([]gamesdb.SearchResult, error) {
+	if !idx.exists() {
+		return nil, fmt.Errorf("index does not exist")
+	}
+
+	files, err := idx.files()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]struct{})
+	for _, system := range systems {
+		wanted[system.Id] = struct{}{}
+	}
+
+	var results []gamesdb.SearchResult
+	for _, f := range files {
+		if _, ok := wanted[f[0]]; !ok {
+			continue
+		}
+
+		result := gamesdb.NewSearchResult(f[0], f[1])
+		if s := score(result.Name); s > 0 {
+			result.Score = s
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+func (idx *listIndex) Search(systems []games.System, query string) ([]gamesdb.SearchResult, error) {
+	results, err := idx.searchScored(systems, gamesdb.FuzzyScorer(query))
+	if err != nil {
+		return nil, err
+	}
+
+	gamesdb.SortByScore(results)
+
+	return results, nil
+}
+
+func (idx *listIndex) SearchRegexp(systems []games.System, pattern string) ([]gamesdb.SearchResult, error) {
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.searchScored(systems, func(name string) float64 {
+		if r.MatchString(name) {
+			return 1
+		}
+		return 0
+	})
+}
+
+func (idx *listIndex) Releases(systemId string, key string) ([]gamesdb.SearchResult, error) {
+	if !idx.exists() {
+		return nil, fmt.Errorf("index does not exist")
+	}
+
+	files, err := idx.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []gamesdb.SearchResult
+	for _, f := range files {
+		if f[0] == systemId && games.DedupeKey(f[1]) == key {
+			results = append(results, gamesdb.NewSearchResult(f[0], f[1]))
+		}
+	}
+
+	return results, nil
+}
+
+func (idx *listIndex) counts() (map[string]int, error) {
+	if !idx.exists() {
+		return nil, fmt.Errorf("index does not exist")
+	}
+
+	files, err := idx.files()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, f := range files {
+		counts[f[0]]++
+	}
+
+	return counts, nil
+}
+
+func (idx *listIndex) Systems() ([]string, error) {
+	counts, err := idx.counts()
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.AlphaMapKeys(counts), nil
+}
+
+func (idx *listIndex) Stats() (Stats, error) {
+	counts, err := idx.counts()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return newStats(counts), nil
+}
+
+func (idx *listIndex) Random(systems []games.System, weight gamesdb.RandomWeight) (gamesdb.SearchResult, error) {
+	results, err := idx.searchScored(systems, func(string) float64 {
+		return 1
+	})
+	if err != nil {
+		return gamesdb.SearchResult{}, err
+	}
+
+	if weight == gamesdb.WeightGames {
+		return utils.RandomElem(results)
+	}
+
+	bySystem := make(map[string][]gamesdb.SearchResult)
+	for _, r := range results {
+		bySystem[r.SystemId] = append(bySystem[r.SystemId], r)
+	}
+
+	systemId, err := utils.RandomElem(utils.MapKeys(bySystem))
+	if err != nil {
+		return gamesdb.SearchResult{}, fmt.Errorf("no indexed games found")
+	}
+
+	return utils.RandomElem(bySystem[systemId])
+}
+
+func (idx *listIndex) List(systems []games.System, offset int, limit int) ([]gamesdb.SearchResult, int, error) {
+	results, err := idx.searchScored(systems, func(string) float64 {
+		return 1
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order := make(map[string]int)
+	for i, system := range systems {
+		order[system.Id] = i
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].SystemId != results[j].SystemId {
+			return order[results[i].SystemId] < order[results[j].SystemId]
+		}
+		return results[i].Name < results[j].Name
+	})
+
+	return Page(results, offset, limit), len(results), nil
+}
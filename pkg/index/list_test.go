@@ -0,0 +1,162 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+)
+
+func memoryIndex(files [][2]string) *listIndex {
+	return &listIndex{
+		exists: func() bool { return true },
+		load: func() ([][2]string, error) {
+			return files, nil
+		},
+		write: func(f [][2]string) error {
+			files = f
+			return nil
+		},
+	}
+}
+
+func TestListIndex(t *testing.T) {
+	idx := memoryIndex([][2]string{
+		{"SNES", "/media/fat/games/SNES/Super Metroid (USA).sfc"},
+		{"SNES", "/media/fat/games/SNES/Super Mario World (USA).sfc"},
+		{"Genesis", "/media/fat/games/Genesis/Sonic the Hedgehog (World).md"},
+	})
+
+	snes, genesis := games.Systems["SNES"], games.Systems["Genesis"]
+
+	results, err := idx.Search([]games.System{snes, genesis}, "super metriod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "Super Metroid (USA)" {
+		t.Errorf("Search() = %+v", results)
+	}
+	if results[0].Tags.Title != "Super Metroid" {
+		t.Errorf("Search() tags = %+v", results[0].Tags)
+	}
+
+	// an empty query lists every game in the systems
+	results, err = idx.Search([]games.System{snes}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Name != "Super Mario World (USA)" || results[1].Name != "Super Metroid (USA)" {
+		t.Errorf("Search() with an empty query = %+v", results)
+	}
+
+	results, err = idx.SearchRegexp([]games.System{genesis}, "(?i)^super")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("SearchRegexp() returned other systems: %+v", results)
+	}
+
+	results, err = idx.Releases("SNES", games.DedupeKey("Super Metroid (Japan).sfc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "Super Metroid (USA)" {
+		t.Errorf("Releases() = %+v", results)
+	}
+
+	_, err = idx.SearchRegexp([]games.System{snes}, "(super")
+	if err == nil {
+		t.Errorf("SearchRegexp() with an invalid expression returned no error")
+	}
+
+	systems, err := idx.Systems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(systems) != 2 || systems[0] != "Genesis" || systems[1] != "SNES" {
+		t.Errorf("Systems() = %v", systems)
+	}
+
+	stats, err := idx.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 3 || stats.Systems["SNES"] != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	game, err := idx.Random([]games.System{genesis}, gamesdb.WeightGames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.SystemId != "Genesis" {
+		t.Errorf("Random() = %+v", game)
+	}
+
+	page, total, err := idx.List([]games.System{snes, genesis}, 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(page) != 2 || page[0].Name != "Super Metroid (USA)" || page[1].SystemId != "Genesis" {
+		t.Errorf("List() = %+v, %d", page, total)
+	}
+}
+
+func TestListIndexCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.db")
+	if err := os.WriteFile(path, []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loads := 0
+	idx := memoryIndex([][2]string{{"SNES", "/media/fat/games/SNES/Super Metroid (USA).sfc"}})
+	idx.path = path
+	load := idx.load
+	idx.load = func() ([][2]string, error) {
+		loads++
+		return load()
+	}
+
+	snes := games.Systems["SNES"]
+	for i := 0; i < 3; i++ {
+		if _, err := idx.Random([]games.System{snes}, gamesdb.WeightGames); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("index loaded %d times, want 1", loads)
+	}
+
+	mtime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := idx.Random([]games.System{snes}, gamesdb.WeightGames); err != nil {
+		t.Fatal(err)
+	}
+	if loads != 2 {
+		t.Errorf("index loaded %d times after it changed, want 2", loads)
+	}
+}
+
+func TestPage(t *testing.T) {
+	results := make([]gamesdb.SearchResult, 5)
+	for i := range results {
+		results[i].Score = float64(i)
+	}
+
+	if got := Page(results, 2, 2); len(got) != 2 || got[0].Score != 2 {
+		t.Errorf("Page(2, 2) = %+v", got)
+	}
+	if got := Page(results, 4, 10); len(got) != 1 {
+		t.Errorf("Page(4, 10) = %+v", got)
+	}
+	if got := Page(results, 10, 10); got == nil || len(got) != 0 {
+		t.Errorf("Page(10, 10) = %+v", got)
+	}
+}
@@ -0,0 +1,123 @@
+package index
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+const maxPickAttempts = 100
+
+// Return every indexed release of the same game as the given result,
+// including the result itself. See games.DedupeKey.
+func releases(idx Index, game gamesdb.SearchResult) []gamesdb.SearchResult {
+	found := []gamesdb.SearchResult{game}
+
+	results, err := idx.Releases(game.SystemId, games.DedupeKey(game.Path))
+	if err != nil {
+		return found
+	}
+
+	for _, r := range results {
+		if r.Path != game.Path {
+			found = append(found, r)
+		}
+	}
+
+	return found
+}
+
+// PickGame returns a random game from the index which still exists on disk.
+// When a game has multiple releases, the best one is returned using the
+// region and language priority list (see games.Dedupe), and each game has
+// the same chance of being picked no matter how many releases it has.
+// Games are also skipped if the launchable func is set and returns false
+// for their path.
+func PickGame(
+	idx Index,
+	systems []games.System,
+	weight gamesdb.RandomWeight,
+	priority []string,
+	launchable func(path string) bool,
+) (gamesdb.SearchResult, error) {
+	for i := 0; i < maxPickAttempts; i++ {
+		game, err := idx.Random(systems, weight)
+		if err != nil {
+			return game, err
+		}
+
+		found := releases(idx, game)
+
+		// only keep a pick for 1 in N releases of a game so it isn't picked
+		// more often than games with a single release
+		if len(found) > 1 && utils.RandomInt(len(found)) != 0 {
+			continue
+		}
+
+		paths := make([]string, 0, len(found))
+		byPath := make(map[string]gamesdb.SearchResult)
+		for _, r := range found {
+			paths = append(paths, r.Path)
+			byPath[r.Path] = r
+		}
+
+		best := byPath[games.Dedupe(paths, priority)[0]]
+		if !games.FileExists(best.Path) || (launchable != nil && !launchable(best.Path)) {
+			continue
+		}
+
+		return best, nil
+	}
+
+	return gamesdb.SearchResult{}, fmt.Errorf("failed to find a random game")
+}
+
+// PickFilteredGame returns a random game from the index which matches the
+// filter and still exists on disk. Every game in the systems is read and
+// filtered first, so it's slower than PickGame but always finds a match if
+// there is one. Best versions are picked using the filter's regions when
+// its BestOnly option is set. Games are also skipped if the launchable func
+// is set and returns false for their path.
+func PickFilteredGame(
+	idx Index,
+	systems []games.System,
+	weight gamesdb.RandomWeight,
+	filter gamesdb.SearchFilter,
+	launchable func(path string) bool,
+) (gamesdb.SearchResult, error) {
+	all, _, err := idx.List(systems, 0, math.MaxInt32)
+	if err != nil {
+		return gamesdb.SearchResult{}, err
+	}
+
+	bySystem := make(map[string][]gamesdb.SearchResult)
+	for _, r := range gamesdb.FilterResults(all, filter) {
+		bySystem[r.SystemId] = append(bySystem[r.SystemId], r)
+	}
+
+	for i := 0; i < maxPickAttempts && len(bySystem) > 0; i++ {
+		var results []gamesdb.SearchResult
+		if weight == gamesdb.WeightGames {
+			for _, system := range systems {
+				results = append(results, bySystem[system.Id]...)
+			}
+		} else {
+			systemId, _ := utils.RandomElem(utils.MapKeys(bySystem))
+			results = bySystem[systemId]
+		}
+
+		game, err := utils.RandomElem(results)
+		if err != nil {
+			break
+		}
+
+		if games.FileExists(game.Path) && (launchable == nil || launchable(game.Path)) {
+			return game, nil
+		}
+	}
+
+	return gamesdb.SearchResult{}, fmt.Errorf("failed to find a random game")
+}
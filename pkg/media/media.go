@@ -0,0 +1,233 @@
+// Package media finds box art, screenshots and title screens for games in
+// local media folders, and creates resized thumbnails of them.
+package media
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+// Types of media which can be found for a game.
+const (
+	TypeBoxart = "boxart"
+	TypeSnap   = "snap"
+	TypeTitle  = "title"
+)
+
+var Types = []string{TypeBoxart, TypeSnap, TypeTitle}
+
+// Folder names used for each type of media by common frontends and media
+// packs, e.g. libretro-thumbnails and Skraper. Matched ignoring case.
+var typeFolders = map[string][]string{
+	TypeBoxart: {"boxart", "boxarts", "named_boxarts", "covers", "box2dfront"},
+	TypeSnap:   {"snap", "snaps", "named_snaps", "screenshots", "screenshot"},
+	TypeTitle:  {"titles", "named_titles", "titlescreens", "screenshottitle"},
+}
+
+var imageExts = []string{".png", ".jpg", ".jpeg"}
+
+// SafeName returns a game name with the characters which can't be used in
+// filenames replaced by underscores, as done by libretro-thumbnails.
+func SafeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune("&*/:`<>?\\|\"", r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func nameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// Index maps media types to the images found for them, by lowercase name
+// without file extension.
+type Index map[string]map[string]string
+
+// ReadFolders finds all images in media type subfolders of the given root
+// folders. If an image is found more than once, the first root it's in is
+// used. Roots which don't exist are skipped.
+func ReadFolders(roots []string) Index {
+	idx := make(Index)
+
+	for _, root := range roots {
+		dirs, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+
+		for _, dir := range dirs {
+			if !dir.IsDir() {
+				continue
+			}
+
+			mediaType := ""
+			for t, names := range typeFolders {
+				for _, name := range names {
+					if strings.EqualFold(dir.Name(), name) {
+						mediaType = t
+					}
+				}
+			}
+			if mediaType == "" {
+				continue
+			}
+
+			files, err := os.ReadDir(filepath.Join(root, dir.Name()))
+			if err != nil {
+				continue
+			}
+
+			for _, file := range files {
+				ext := strings.ToLower(filepath.Ext(file.Name()))
+				if file.IsDir() || !utils.Contains(imageExts, ext) {
+					continue
+				}
+
+				if idx[mediaType] == nil {
+					idx[mediaType] = make(map[string]string)
+				}
+
+				key := nameKey(strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())))
+				if _, ok := idx[mediaType][key]; !ok {
+					idx[mediaType][key] = filepath.Join(root, dir.Name(), file.Name())
+				}
+			}
+		}
+	}
+
+	return idx
+}
+
+// Match returns the path of every type of media found for a game, trying
+// each of the given names in order. Names are also tried with unsafe
+// characters replaced, see SafeName. Returns nil if nothing was found.
+func (idx Index) Match(names ...string) map[string]string {
+	var found map[string]string
+
+	for _, mediaType := range Types {
+		images := idx[mediaType]
+		if images == nil {
+			continue
+		}
+
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+
+			path, ok := images[nameKey(name)]
+			if !ok {
+				path, ok = images[nameKey(SafeName(name))]
+			}
+
+			if ok {
+				if found == nil {
+					found = make(map[string]string)
+				}
+				found[mediaType] = path
+				break
+			}
+		}
+	}
+
+	return found
+}
+
+// Return the path of a cached thumbnail for an image. The name includes the
+// modification time of the image so changed images get a new thumbnail.
+func thumbnailPath(path string, size int) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha1.Sum([]byte(fmt.Sprintf("%s:%d:%d", path, info.ModTime().UnixNano(), size)))
+	ext := ".jpg"
+	if strings.ToLower(filepath.Ext(path)) == ".png" {
+		ext = ".png"
+	}
+
+	return filepath.Join(config.ThumbnailsFolder, hex.EncodeToString(h[:])+ext), nil
+}
+
+// Thumbnail returns the path to a copy of an image resized to fit within
+// size by size pixels, creating it in the thumbnails folder if it hasn't
+// been already. Images which already fit are returned unchanged. PNG images
+// stay as PNG to keep transparency, everything else is saved as JPEG.
+func Thumbnail(path string, size int) (string, error) {
+	thumbPath, err := thumbnailPath(path, size)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(thumbPath); err == nil {
+		return thumbPath, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return "", fmt.Errorf("error decoding image: %s", err)
+	}
+
+	b := src.Bounds()
+	if b.Dx() <= size && b.Dy() <= size {
+		return path, nil
+	}
+
+	w, h := size, b.Dy()*size/b.Dx()
+	if b.Dy() > b.Dx() {
+		w, h = b.Dx()*size/b.Dy(), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, utils.Max([]int{w, 1}), utils.Max([]int{h, 1})))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+
+	err = os.MkdirAll(config.ThumbnailsFolder, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	// write to a temp file first so a half written thumbnail is never served
+	tmp, err := os.CreateTemp(config.ThumbnailsFolder, "thumb")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if filepath.Ext(thumbPath) == ".png" {
+		err = png.Encode(tmp, dst)
+	} else {
+		err = jpeg.Encode(tmp, dst, &jpeg.Options{Quality: 85})
+	}
+	tmp.Close()
+	if err != nil {
+		return "", fmt.Errorf("error encoding thumbnail: %s", err)
+	}
+
+	err = os.Rename(tmp.Name(), thumbPath)
+	if err != nil {
+		return "", err
+	}
+
+	return thumbPath, nil
+}
@@ -0,0 +1,52 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIndexMatch(t *testing.T) {
+	root := t.TempDir()
+	other := t.TempDir()
+
+	files := []string{
+		filepath.Join(root, "Named_Boxarts", "Super Mario World (USA).png"),
+		filepath.Join(root, "snap", "Super Mario World.jpg"),
+		filepath.Join(root, "titles", "Sonic _ Knuckles (World).png"),
+		filepath.Join(root, "titles", "notes.txt"),
+		filepath.Join(other, "boxart", "Super Mario World (USA).png"),
+	}
+	for _, f := range files {
+		err := os.MkdirAll(filepath.Dir(f), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(f, nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx := ReadFolders([]string{root, other, filepath.Join(root, "missing")})
+
+	got := idx.Match("super mario world (usa)", "Super Mario World")
+	want := map[string]string{
+		TypeBoxart: files[0],
+		TypeSnap:   files[1],
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Match() = %v, want %v", got, want)
+	}
+
+	got = idx.Match("Sonic & Knuckles (World)")
+	want = map[string]string{TypeTitle: files[2]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Match() = %v, want %v", got, want)
+	}
+
+	if got := idx.Match("Tetris"); got != nil {
+		t.Errorf("Match() = %v, want nil", got)
+	}
+}
@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Orientations of an arcade game's screen.
+const (
+	OrientationHorizontal = "horizontal"
+	OrientationVertical   = "vertical"
+)
+
+// ArcadeInfo is the MRA header of an arcade game joined with its ArcadeDB
+// entry, if it has one.
+type ArcadeInfo struct {
+	SetName      string `json:"setname"`
+	Rbf          string `json:"rbf"`
+	Name         string `json:"name"`
+	Year         int    `json:"year,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Category     string `json:"category,omitempty"`
+	// Maximum number of players, 0 if unknown.
+	Players int `json:"players,omitempty"`
+	// One of the orientation constants, blank if unknown.
+	Orientation string `json:"orientation,omitempty"`
+	Buttons     int    `json:"buttons,omitempty"`
+	Bootleg     bool   `json:"bootleg,omitempty"`
+	Homebrew    bool   `json:"homebrew,omitempty"`
+}
+
+// Return the orientation from a rotation in degrees or a description such
+// as "vertical (cw)".
+func parseOrientation(rotation string) string {
+	rotation = strings.ToLower(strings.TrimSpace(rotation))
+
+	if strings.Contains(rotation, OrientationVertical) {
+		return OrientationVertical
+	} else if strings.Contains(rotation, OrientationHorizontal) {
+		return OrientationHorizontal
+	}
+
+	switch ParsePlayers(rotation) {
+	case 90, 270:
+		return OrientationVertical
+	case 0, 180:
+		if rotation != "" {
+			return OrientationHorizontal
+		}
+	}
+
+	return ""
+}
+
+// Return true if an ArcadeDB flag column is set.
+func parseFlag(v string) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	return v != "" && v != "0" && v != "no" && v != "false"
+}
+
+// Return the first non-blank value.
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// NewArcadeInfo joins the header of an MRA with its ArcadeDB entry, which may
+// be nil. Values from the MRA are preferred, ArcadeDB fills in the rest.
+func NewArcadeInfo(mra MRA, entry *ArcadeDbEntry) ArcadeInfo {
+	if entry == nil {
+		entry = &ArcadeDbEntry{}
+	}
+
+	info := ArcadeInfo{
+		SetName:      mra.SetName,
+		Rbf:          mra.Rbf,
+		Name:         firstOf(mra.Name, entry.Name),
+		Year:         ParseYear(firstOf(mra.Year, entry.Year)),
+		Manufacturer: firstOf(mra.Manufacturer, entry.Manufacturer),
+		Category:     firstOf(mra.Category, entry.Category),
+		Players:      ParsePlayers(firstOf(mra.Players, entry.Players)),
+		Orientation:  parseOrientation(firstOf(mra.Rotation, entry.Rotation)),
+		Bootleg:      parseFlag(entry.Bootleg),
+		Homebrew:     parseFlag(entry.Homebrew),
+	}
+
+	if n, err := strconv.Atoi(strings.TrimSpace(entry.NumButtons)); err == nil {
+		info.Buttons = n
+	}
+
+	return info
+}
+
+// ArcadeDbIndex maps lowercase setnames to their ArcadeDB entry.
+type ArcadeDbIndex map[string]*ArcadeDbEntry
+
+// NewArcadeDbIndex creates a setname lookup index of ArcadeDB entries.
+func NewArcadeDbIndex(entries []ArcadeDbEntry) ArcadeDbIndex {
+	idx := make(ArcadeDbIndex)
+	for i := range entries {
+		idx[strings.ToLower(entries[i].Setname)] = &entries[i]
+	}
+	return idx
+}
+
+// Get returns the entry for a setname, or nil if there isn't one.
+func (idx ArcadeDbIndex) Get(setname string) *ArcadeDbEntry {
+	return idx[strings.ToLower(setname)]
+}
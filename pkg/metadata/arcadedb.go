@@ -44,6 +44,7 @@ type ArcadeDbEntry struct {
 	Linebreak1      string `csv:"linebreak1"`
 	Resolution      string `csv:"resolution"`
 	Flip            string `csv:"flip"`
+	Rotation        string `csv:"rotation"`
 	Linebreak2      string `csv:"linebreak2"`
 	Players         string `csv:"players"`
 	MoveInputs      string `csv:"move_inputs"`
@@ -0,0 +1,299 @@
+package metadata
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gocarina/gocsv"
+
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+// GameMetadata is descriptive information about a game which can't be read
+// from its filename.
+type GameMetadata struct {
+	Genre     string `json:"genre,omitempty"`
+	Year      int    `json:"year,omitempty"`
+	Developer string `json:"developer,omitempty"`
+	Publisher string `json:"publisher,omitempty"`
+	// Maximum number of players, 0 if unknown.
+	Players     int    `json:"players,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Entry is the metadata for a single game in a metadata file, keyed by the
+// game's filename and optionally a CRC32, MD5 or SHA1 hash of its ROM.
+type Entry struct {
+	Name string
+	Hash string
+	GameMetadata
+}
+
+// ParseYear returns the year at the start of a date such as "1994",
+// "1994-03-18" or "19940318T000000". Returns 0 if there is no year.
+func ParseYear(date string) int {
+	date = strings.TrimSpace(date)
+	if len(date) < 4 {
+		return 0
+	}
+
+	year, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+
+	return year
+}
+
+// ParsePlayers returns the maximum number of players from a value such as
+// "2", "1-4" or "1 - 2". Returns 0 if there is no number.
+func ParsePlayers(players string) int {
+	max := 0
+	for _, part := range strings.FieldsFunc(players, func(r rune) bool {
+		return r < '0' || r > '9'
+	}) {
+		if n, err := strconv.Atoi(part); err == nil && n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+type gamelistGame struct {
+	Path        string `xml:"path"`
+	Name        string `xml:"name"`
+	Desc        string `xml:"desc"`
+	ReleaseDate string `xml:"releasedate"`
+	Developer   string `xml:"developer"`
+	Publisher   string `xml:"publisher"`
+	Genre       string `xml:"genre"`
+	Players     string `xml:"players"`
+	Hash        string `xml:"hash"`
+}
+
+type gamelist struct {
+	Games []gamelistGame `xml:"game"`
+}
+
+// ReadGamelist parses an EmulationStation gamelist.xml file. Games are keyed
+// by the filename in their path, or their display name if they have no path.
+func ReadGamelist(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var gl gamelist
+	err = xml.NewDecoder(f).Decode(&gl)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(gl.Games))
+	for _, g := range gl.Games {
+		name := g.Name
+		if g.Path != "" {
+			name = filepath.Base(g.Path)
+		}
+
+		entries = append(entries, Entry{
+			Name: name,
+			Hash: g.Hash,
+			GameMetadata: GameMetadata{
+				Genre:       g.Genre,
+				Year:        ParseYear(g.ReleaseDate),
+				Developer:   g.Developer,
+				Publisher:   g.Publisher,
+				Players:     ParsePlayers(g.Players),
+				Description: g.Desc,
+			},
+		})
+	}
+
+	return entries, nil
+}
+
+// A JSON value which may be either a string or a number.
+type flexString string
+
+func (s *flexString) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case string:
+		*s = flexString(v)
+	case float64:
+		*s = flexString(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+
+	return nil
+}
+
+// A row in a CSV or JSON metadata file.
+type record struct {
+	Name        string     `csv:"name" json:"name"`
+	Hash        string     `csv:"hash" json:"hash"`
+	Genre       string     `csv:"genre" json:"genre"`
+	Year        flexString `csv:"year" json:"year"`
+	Developer   string     `csv:"developer" json:"developer"`
+	Publisher   string     `csv:"publisher" json:"publisher"`
+	Players     flexString `csv:"players" json:"players"`
+	Description string     `csv:"description" json:"description"`
+}
+
+func (r record) entry() Entry {
+	return Entry{
+		Name: r.Name,
+		Hash: r.Hash,
+		GameMetadata: GameMetadata{
+			Genre:       r.Genre,
+			Year:        ParseYear(string(r.Year)),
+			Developer:   r.Developer,
+			Publisher:   r.Publisher,
+			Players:     ParsePlayers(string(r.Players)),
+			Description: r.Description,
+		},
+	}
+}
+
+func readRecords(path string, decode func(io.Reader, *[]record) error) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []record
+	err = decode(f, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(records))
+	for _, r := range records {
+		entries = append(entries, r.entry())
+	}
+
+	return entries, nil
+}
+
+// ReadCsv parses a CSV metadata file. The first row is a header with any of
+// the columns name, hash, genre, year, developer, publisher, players and
+// description.
+func ReadCsv(path string) ([]Entry, error) {
+	return readRecords(path, func(r io.Reader, records *[]record) error {
+		return gocsv.Unmarshal(r, records)
+	})
+}
+
+// ReadJson parses a JSON metadata file. The file is an array of objects with
+// the same keys as the CSV columns, see ReadCsv.
+func ReadJson(path string) ([]Entry, error) {
+	return readRecords(path, func(r io.Reader, records *[]record) error {
+		return json.NewDecoder(r).Decode(records)
+	})
+}
+
+// ReadFolder parses every gamelist .xml, .csv and .json file in a folder,
+// sorted by filename. Files which can't be parsed are skipped.
+func ReadFolder(folder string) ([]Entry, error) {
+	files, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		var read func(string) ([]Entry, error)
+		switch strings.ToLower(filepath.Ext(file.Name())) {
+		case ".xml":
+			read = ReadGamelist
+		case ".csv":
+			read = ReadCsv
+		case ".json":
+			read = ReadJson
+		default:
+			continue
+		}
+
+		found, err := read(filepath.Join(folder, file.Name()))
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, found...)
+	}
+
+	return entries, nil
+}
+
+// Index allows looking up metadata by ROM checksum or filename.
+type Index struct {
+	byHash map[string]*GameMetadata
+	byName map[string]*GameMetadata
+}
+
+func nameKey(name string) string {
+	return strings.ToLower(utils.RemoveFileExt(filepath.Base(name)))
+}
+
+// NewIndex creates a lookup index of the given entries. If more than one
+// entry has the same name or hash, the last one is used.
+func NewIndex(entries []Entry) *Index {
+	idx := &Index{
+		byHash: make(map[string]*GameMetadata),
+		byName: make(map[string]*GameMetadata),
+	}
+
+	for i := range entries {
+		meta := &entries[i].GameMetadata
+		if entries[i].Hash != "" {
+			idx.byHash[strings.ToLower(entries[i].Hash)] = meta
+		}
+		if entries[i].Name != "" {
+			idx.byName[nameKey(entries[i].Name)] = meta
+		}
+	}
+
+	return idx
+}
+
+// Len returns the number of names and hashes in the index.
+func (idx *Index) Len() int {
+	return len(idx.byHash) + len(idx.byName)
+}
+
+// MatchHash returns the metadata for a ROM with any of the given checksums.
+// Blank checksums are ignored.
+func (idx *Index) MatchHash(hashes ...string) (*GameMetadata, bool) {
+	for _, h := range hashes {
+		if h == "" {
+			continue
+		}
+		if meta, ok := idx.byHash[strings.ToLower(h)]; ok {
+			return meta, true
+		}
+	}
+	return nil, false
+}
+
+// MatchName returns the metadata for a game with a name matching the given
+// path, ignoring case and file extension.
+func (idx *Index) MatchName(path string) (*GameMetadata, bool) {
+	meta, ok := idx.byName[nameKey(path)]
+	return meta, ok
+}
@@ -0,0 +1,121 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParsePlayers(t *testing.T) {
+	tests := map[string]int{
+		"":      0,
+		"1":     1,
+		"1-2":   2,
+		"1 - 4": 4,
+		"2+":    2,
+		"n/a":   0,
+	}
+
+	for in, want := range tests {
+		if got := ParsePlayers(in); got != want {
+			t.Errorf("ParsePlayers(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestReadFolder(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"gamelist.xml": `<?xml version="1.0"?>
+<gameList>
+	<game>
+		<path>./Super Mario World (USA).sfc</path>
+		<name>Super Mario World</name>
+		<releasedate>19901121T000000</releasedate>
+		<genre>Platform</genre>
+		<players>1-2</players>
+	</game>
+</gameList>`,
+		"games.csv":  "name,hash,year,players\nTetris (Japan),A1B2C3D4,1989,2\n",
+		"games.json": `[{"name":"Doom (USA)","year":1995,"players":"4","developer":"id"}]`,
+		"readme.txt": "ignored",
+	}
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := ReadFolder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewIndex(entries)
+
+	meta, ok := idx.MatchName("/media/fat/games/SNES/super mario world (usa).smc")
+	want := GameMetadata{Genre: "Platform", Year: 1990, Players: 2}
+	if !ok || !reflect.DeepEqual(*meta, want) {
+		t.Errorf("MatchName() = %v, want %v", meta, want)
+	}
+
+	meta, ok = idx.MatchHash("", "a1b2c3d4")
+	want = GameMetadata{Year: 1989, Players: 2}
+	if !ok || !reflect.DeepEqual(*meta, want) {
+		t.Errorf("MatchHash() = %v, want %v", meta, want)
+	}
+
+	meta, ok = idx.MatchName("Doom (USA).zip")
+	want = GameMetadata{Year: 1995, Players: 4, Developer: "id"}
+	if !ok || !reflect.DeepEqual(*meta, want) {
+		t.Errorf("MatchName() = %v, want %v", meta, want)
+	}
+}
+
+func TestNewArcadeInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1942.mra")
+	err := os.WriteFile(path, []byte(`<misterromdescription>
+	<name>1942 (Revision B) & more</name>
+	<setname>1942</setname>
+	<rbf>jt1942</rbf>
+	<year>1984</year>
+	<manufacturer>Capcom</manufacturer>
+	<rotation>vertical (cw)</rotation>
+	<rom index="0" zip="1942.zip" md5="none"><part name="srb-03.m3"/></rom>
+</misterromdescription>`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mra, err := ReadMra(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewArcadeDbIndex([]ArcadeDbEntry{{
+		Setname:    "1942",
+		Players:    "2",
+		NumButtons: "2",
+		Bootleg:    "",
+		Category:   "Shooter",
+	}})
+
+	got := NewArcadeInfo(mra, idx.Get(mra.SetName))
+	want := ArcadeInfo{
+		SetName:      "1942",
+		Rbf:          "jt1942",
+		Name:         "1942 (Revision B) & more",
+		Year:         1984,
+		Manufacturer: "Capcom",
+		Category:     "Shooter",
+		Players:      2,
+		Orientation:  OrientationVertical,
+		Buttons:      2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewArcadeInfo() = %+v, want %+v", got, want)
+	}
+}
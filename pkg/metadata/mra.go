@@ -0,0 +1,177 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"strings"
+)
+
+// MRAPart is a ROM file to load from a zip, or inline hex data if it has no
+// name or CRC.
+type MRAPart struct {
+	Crc  string `xml:"crc,attr,omitempty"`
+	Name string `xml:"name,attr,omitempty"`
+	// Overrides the zip of the ROM element.
+	Zip    string `xml:"zip,attr,omitempty"`
+	Repeat string `xml:"repeat,attr,omitempty"`
+	Offset string `xml:"offset,attr,omitempty"`
+	Length string `xml:"length,attr,omitempty"`
+	Map    string `xml:"map,attr,omitempty"`
+	Data   string `xml:",chardata"`
+}
+
+// MRARomItem is a part, interleave or patch in a ROM element. Items are kept
+// in a single list because the order they're loaded in matters.
+type MRARomItem struct {
+	XMLName xml.Name
+	MRAPart
+	// Set for interleave elements.
+	Output string    `xml:"output,attr,omitempty"`
+	Parts  []MRAPart `xml:"part"`
+}
+
+type MRARom struct {
+	Index int `xml:"index,attr"`
+	// One or more zip filenames separated by |.
+	Zip     string       `xml:"zip,attr,omitempty"`
+	Md5     string       `xml:"md5,attr,omitempty"`
+	Type    string       `xml:"type,attr,omitempty"`
+	Address string       `xml:"address,attr,omitempty"`
+	Items   []MRARomItem `xml:",any"`
+}
+
+// Zips returns every zip filename the ROM can be loaded from.
+func (r MRARom) Zips() []string {
+	var zips []string
+	for _, z := range strings.Split(r.Zip, "|") {
+		if z = strings.TrimSpace(z); z != "" {
+			zips = append(zips, z)
+		}
+	}
+	return zips
+}
+
+// Parts returns every part of the ROM loaded from a zip, including parts
+// inside interleaves. Inline data parts are skipped.
+func (r MRARom) Parts() []MRAPart {
+	var parts []MRAPart
+
+	add := func(p MRAPart) {
+		if p.Name != "" || p.Crc != "" {
+			parts = append(parts, p)
+		}
+	}
+
+	for _, item := range r.Items {
+		switch item.XMLName.Local {
+		case "part":
+			add(item.MRAPart)
+		case "interleave":
+			for _, p := range item.Parts {
+				add(p)
+			}
+		}
+	}
+
+	return parts
+}
+
+type MRADip struct {
+	Bits   string `xml:"bits,attr"`
+	Name   string `xml:"name,attr"`
+	Ids    string `xml:"ids,attr,omitempty"`
+	Values string `xml:"values,attr,omitempty"`
+}
+
+type MRASwitches struct {
+	Default  string   `xml:"default,attr,omitempty"`
+	Base     string   `xml:"base,attr,omitempty"`
+	PageId   string   `xml:"page_id,attr,omitempty"`
+	PageName string   `xml:"page_name,attr,omitempty"`
+	Dips     []MRADip `xml:"dip"`
+}
+
+type MRAButtons struct {
+	Names   string `xml:"names,attr"`
+	Default string `xml:"default,attr,omitempty"`
+}
+
+type MRANvram struct {
+	Index int `xml:"index,attr"`
+	Size  int `xml:"size,attr"`
+}
+
+// XMLElement is an element not covered by the MRA or MGL types, kept as is
+// so it isn't lost when the file is written back. They're written after all
+// other elements.
+type XMLElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+// MRA is an arcade game description, which loads ROMs from MAME zips into an
+// arcade core.
+type MRA struct {
+	XMLName      xml.Name      `xml:"misterromdescription"`
+	Name         string        `xml:"name"`
+	SetName      string        `xml:"setname,omitempty"`
+	Parent       string        `xml:"parent,omitempty"`
+	Rbf          string        `xml:"rbf"`
+	MameVersion  string        `xml:"mameversion,omitempty"`
+	Year         string        `xml:"year,omitempty"`
+	Manufacturer string        `xml:"manufacturer,omitempty"`
+	Category     string        `xml:"category,omitempty"`
+	Players      string        `xml:"players,omitempty"`
+	Joystick     string        `xml:"joystick,omitempty"`
+	Rotation     string        `xml:"rotation,omitempty"`
+	Region       string        `xml:"region,omitempty"`
+	Switches     []MRASwitches `xml:"switches"`
+	Buttons      *MRAButtons   `xml:"buttons"`
+	Roms         []MRARom      `xml:"rom"`
+	Nvram        *MRANvram     `xml:"nvram"`
+	Other        []XMLElement  `xml:",any"`
+}
+
+// ReadMra parses an MRA file. MRAs are often hand written, so the file
+// doesn't need to be strictly valid XML.
+func ReadMra(path string) (MRA, error) {
+	var mra MRA
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return mra, err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(file))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	err = decoder.Decode(&mra)
+	if err != nil {
+		return mra, err
+	}
+
+	// interleaves only contain whitespace between their parts
+	for i := range mra.Roms {
+		for j := range mra.Roms[i].Items {
+			item := &mra.Roms[i].Items[j]
+			if strings.TrimSpace(item.Data) == "" {
+				item.Data = ""
+			}
+		}
+	}
+
+	return mra, nil
+}
+
+// WriteMra writes an MRA file, replacing any existing file.
+func WriteMra(path string, mra MRA) error {
+	data, err := xml.MarshalIndent(mra, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
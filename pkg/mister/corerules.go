@@ -0,0 +1,252 @@
+package mister
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+// CoreRuleMgl replaces the MGL slot parameters of a game's file.
+type CoreRuleMgl struct {
+	Delay  int    `json:"delay"`
+	Method string `json:"method"`
+	Index  int    `json:"index"`
+}
+
+// CoreRule changes the core, setname or MGL slot used to launch the games it
+// matches. A rule must have at least one of path, glob, name or hash, and a
+// game must match all of them.
+type CoreRule struct {
+	// Only match games of this system ID.
+	System string `json:"system,omitempty"`
+	// Exact path of a single game.
+	Path string `json:"path,omitempty"`
+	// Glob pattern matched against the full path, or just the filename if
+	// it has no folders.
+	Glob string `json:"glob,omitempty"`
+	// Filename without its extension, case-insensitive.
+	Name string `json:"name,omitempty"`
+	// CRC32, MD5 or SHA1 of the game file. Games are hashed when launched,
+	// so this is best kept to small cartridge-based systems.
+	Hash           string       `json:"hash,omitempty"`
+	Rbf            string       `json:"rbf,omitempty"`
+	SetName        string       `json:"setName,omitempty"`
+	SetNameSameDir bool         `json:"setNameSameDir,omitempty"`
+	Mgl            *CoreRuleMgl `json:"mgl,omitempty"`
+}
+
+// The core rules file is a JSON file with a list of rules, the first rule
+// which matches a game is used. Example:
+//
+//	{
+//	    "rules": [
+//	        {"system": "N64", "glob": "*(Japan)*", "rbf": "_Console/N64_DualSDRAM"},
+//	        {"system": "PSX", "name": "Crash Bandicoot (USA)", "rbf": "_LLAPI/PSX_LLAPI"}
+//	    ]
+//	}
+type coreRulesFile struct {
+	Rules []CoreRule `json:"rules"`
+}
+
+// CoreRulesError contains every invalid rule found in a core rules file.
+// Valid rules in the file are still returned.
+type CoreRulesError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *CoreRulesError) Error() string {
+	return fmt.Sprintf(
+		"invalid rules in %s:\n- %s",
+		e.Path,
+		strings.Join(e.Problems, "\n- "),
+	)
+}
+
+func validateCoreRule(rule CoreRule) error {
+	if rule.Path == "" && rule.Glob == "" && rule.Name == "" && rule.Hash == "" {
+		return fmt.Errorf("one of path, glob, name or hash is required")
+	}
+
+	if rule.Rbf == "" && rule.SetName == "" && rule.Mgl == nil {
+		return fmt.Errorf("one of rbf, setName or mgl is required")
+	}
+
+	if rule.Glob != "" {
+		if _, err := filepath.Match(rule.Glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %s", rule.Glob, err)
+		}
+	}
+
+	if rule.Mgl != nil {
+		if rule.Mgl.Method != "f" && rule.Mgl.Method != "s" {
+			return fmt.Errorf("mgl method must be \"f\" or \"s\", got %q", rule.Mgl.Method)
+		}
+		if rule.Mgl.Delay < 0 || rule.Mgl.Index < 0 {
+			return fmt.Errorf("mgl delay and index must not be negative")
+		}
+	}
+
+	return nil
+}
+
+// ReadCoreRules reads a core rules file. A missing file is not an error. If
+// some rules are invalid, the valid ones are still returned along with a
+// *CoreRulesError describing the rest.
+func ReadCoreRules(path string) ([]CoreRule, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var crf coreRulesFile
+	err = json.Unmarshal(data, &crf)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", path, err)
+	}
+
+	var rules []CoreRule
+	var problems []string
+
+	for i, rule := range crf.Rules {
+		if err := validateCoreRule(rule); err != nil {
+			problems = append(problems, fmt.Sprintf("rule %d: %s", i+1, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	if len(problems) > 0 {
+		return rules, &CoreRulesError{
+			Path:     path,
+			Problems: problems,
+		}
+	}
+
+	return rules, nil
+}
+
+// WriteCoreRules writes a core rules file, replacing any existing file.
+func WriteCoreRules(path string, rules []CoreRule) error {
+	if rules == nil {
+		rules = make([]CoreRule, 0)
+	}
+
+	data, err := json.MarshalIndent(coreRulesFile{Rules: rules}, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// MatchCoreRule returns the first rule which matches a game, or nil if none
+// do.
+func MatchCoreRule(rules []CoreRule, systemId string, path string) *CoreRule {
+	name := utils.RemoveFileExt(filepath.Base(path))
+
+	var hashes *gamesdb.FileHashes
+	hashed := false
+
+	for i, rule := range rules {
+		if rule.System != "" && !strings.EqualFold(rule.System, systemId) {
+			continue
+		}
+
+		if rule.Path != "" && filepath.Clean(rule.Path) != filepath.Clean(path) {
+			continue
+		}
+
+		if rule.Glob != "" {
+			target := path
+			if !strings.Contains(rule.Glob, "/") {
+				target = filepath.Base(path)
+			}
+			if ok, _ := filepath.Match(rule.Glob, target); !ok {
+				continue
+			}
+		}
+
+		if rule.Name != "" && !strings.EqualFold(rule.Name, name) {
+			continue
+		}
+
+		if rule.Hash != "" {
+			// only hash the file once, and only if a rule needs it
+			if !hashed {
+				if h, err := gamesdb.HashFile(path); err == nil {
+					hashes = &h
+				}
+				hashed = true
+			}
+
+			hash := strings.ToLower(strings.TrimSpace(rule.Hash))
+			if hashes == nil || (hash != hashes.CRC32 && hash != hashes.MD5 && hash != hashes.SHA1) {
+				continue
+			}
+		}
+
+		return &rules[i]
+	}
+
+	return nil
+}
+
+// PinCore adds a rule for a single game to the core rules file, replacing
+// any existing rule for the same path. Pinned rules are checked before all
+// other rules.
+func PinCore(rulesFile string, rule CoreRule) error {
+	if rule.Path == "" {
+		return fmt.Errorf("pinned rule must have a path")
+	}
+
+	err := validateCoreRule(rule)
+	if err != nil {
+		return err
+	}
+
+	// invalid rules would be lost when the file is written back
+	rules, err := ReadCoreRules(rulesFile)
+	if err != nil {
+		return err
+	}
+
+	pinned := []CoreRule{rule}
+	for _, r := range rules {
+		if r.Path != rule.Path {
+			pinned = append(pinned, r)
+		}
+	}
+
+	return WriteCoreRules(rulesFile, pinned)
+}
+
+// UnpinCore removes every rule for a single game path from the core rules
+// file.
+func UnpinCore(rulesFile string, gamePath string) error {
+	rules, err := ReadCoreRules(rulesFile)
+	if err != nil {
+		return err
+	}
+
+	var kept []CoreRule
+	for _, r := range rules {
+		if r.Path != gamePath {
+			kept = append(kept, r)
+		}
+	}
+
+	return WriteCoreRules(rulesFile, kept)
+}
@@ -0,0 +1,56 @@
+package mister
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testCoreRules = `{
+    "rules": [
+        {"system": "N64", "glob": "*(Japan)*", "rbf": "_Console/N64_DualSDRAM"},
+        {"name": "crash bandicoot (usa)", "rbf": "_LLAPI/PSX_LLAPI"},
+        {"glob": "[", "rbf": "_Console/Broken"},
+        {"path": "/media/fat/games/PSX/Tekken 3 (USA).chd"},
+        {"glob": "/media/fat/games/PSX/*", "setName": "PSX2", "setNameSameDir": true}
+    ]
+}
+`
+
+func TestMatchCoreRule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cores.json")
+	err := os.WriteFile(path, []byte(testCoreRules), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ReadCoreRules(path)
+	var rulesErr *CoreRulesError
+	if !errors.As(err, &rulesErr) || len(rulesErr.Problems) != 2 {
+		t.Fatalf("ReadCoreRules() error = %v, want 2 problems", err)
+	}
+
+	tests := []struct {
+		system string
+		path   string
+		want   string
+	}{
+		{"N64", "/media/fat/games/N64/Mario Kart 64 (Japan).z64", "_Console/N64_DualSDRAM"},
+		{"N64", "/media/fat/games/N64/Mario Kart 64 (USA).z64", ""},
+		{"SNES", "/media/fat/games/SNES/Tetris (Japan).sfc", ""},
+		{"PSX", "/media/fat/games/PSX/Crash Bandicoot (USA).chd", "_LLAPI/PSX_LLAPI"},
+		{"PSX", "/media/fat/games/PSX/Tekken 3 (USA).chd", "PSX2"},
+	}
+
+	for _, tt := range tests {
+		rule := MatchCoreRule(rules, tt.system, tt.path)
+		got := ""
+		if rule != nil {
+			got = rule.Rbf + rule.SetName
+		}
+		if got != tt.want {
+			t.Errorf("MatchCoreRule(%s, %s) = %q, want %q", tt.system, tt.path, got, tt.want)
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package mister
 
 import (
-	"bytes"
-	"encoding/xml"
 	"fmt"
 	"github.com/wizzomafizzo/mrext/pkg/games"
 	"github.com/wizzomafizzo/mrext/pkg/utils"
@@ -196,44 +194,6 @@ func ReadRecent(path string) ([]RecentEntry, error) {
 	return recents, nil
 }
 
-type MGLFile struct {
-	XMLName xml.Name `xml:"file"`
-	Delay   int      `xml:"delay,attr"`
-	Type    string   `xml:"type,attr"`
-	Index   int      `xml:"index,attr"`
-	Path    string   `xml:"path,attr"`
-}
-
-type MGL struct {
-	XMLName xml.Name `xml:"mistergamedescription"`
-	Rbf     string   `xml:"rbf"`
-	SetName string   `xml:"setname"`
-	File    MGLFile  `xml:"file"`
-}
-
-func ReadMgl(path string) (MGL, error) {
-	var mgl MGL
-
-	if _, err := os.Stat(path); err != nil {
-		return mgl, err
-	}
-
-	file, err := os.ReadFile(path)
-	if err != nil {
-		return mgl, err
-	}
-
-	decoder := xml.NewDecoder(bytes.NewReader(file))
-	decoder.Strict = false
-
-	err = decoder.Decode(&mgl)
-	if err != nil {
-		return mgl, err
-	}
-
-	return mgl, nil
-}
-
 type MenuConfig struct {
 	BackgroundMode int
 }
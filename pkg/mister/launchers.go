@@ -15,52 +15,10 @@ import (
 
 	"github.com/wizzomafizzo/mrext/pkg/config"
 	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/index"
 )
 
-func GenerateMgl(cfg *config.UserConfig, system *games.System, path string, override string) (string, error) {
-	// override the system rbf with the user specified one
-	for _, setCore := range cfg.Systems.SetCore {
-		parts := s.SplitN(setCore, ":", 2)
-		if len(parts) != 2 {
-			continue
-		}
-
-		if s.EqualFold(parts[0], system.Id) {
-			system.Rbf = parts[1]
-			break
-		}
-	}
-
-	mgl := fmt.Sprintf("<mistergamedescription>\n\t<rbf>%s</rbf>\n", system.Rbf)
-
-	if system.SetName != "" {
-		sameDir := ""
-		if system.SetNameSameDir {
-			sameDir = " same_dir=\"1\""
-		}
-
-		mgl += fmt.Sprintf("\t<setname%s>%s</setname>\n", sameDir, system.SetName)
-	}
-
-	if path == "" {
-		mgl += "</mistergamedescription>"
-		return mgl, nil
-	} else if override != "" {
-		mgl += override
-		mgl += "</mistergamedescription>"
-		return mgl, nil
-	}
-
-	mglDef, err := games.PathToMglDef(*system, path)
-	if err != nil {
-		return "", err
-	}
-
-	mgl += fmt.Sprintf("<file delay=\"%d\" type=\"%s\" index=\"%d\" path=\"../../../../..%s\"/>\n", mglDef.Delay, mglDef.Method, mglDef.Index, path)
-	mgl += "</mistergamedescription>"
-	return mgl, nil
-}
-
 func writeTempFile(content string) (string, error) {
 	tmpFile, err := os.Create(config.LastLaunchFile)
 	if err != nil {
@@ -138,6 +96,10 @@ func LaunchGame(cfg *config.UserConfig, system games.System, path string) error
 		if err != nil {
 			return err
 		}
+
+		if ActiveGameEnabled() {
+			SetActiveGame(path)
+		}
 	case ".mgl":
 		err := launchFile(path)
 		if err != nil {
@@ -293,13 +255,11 @@ func LaunchCore(cfg *config.UserConfig, system games.System) error {
 		return LaunchGame(cfg, system, "")
 	}
 
-	var path string
-	rbfs := games.SystemsWithRbf()
-	if _, ok := rbfs[system.Id]; ok {
-		path = rbfs[system.Id].Path
-	} else {
+	rbf, ok := games.PreferredRbf(cfg, system)
+	if !ok {
 		return fmt.Errorf("no core found for system %s", system.Id)
 	}
+	path := rbf.Path
 
 	cmd, err := os.OpenFile(config.CmdInterface, os.O_RDWR, 0)
 	if err != nil {
@@ -340,6 +300,7 @@ func LaunchGenericFile(cfg *config.UserConfig, path string) error {
 		if err != nil {
 			return err
 		}
+		isGame = true
 	case ".mgl":
 		err = launchFile(path)
 		if err != nil {
@@ -392,7 +353,7 @@ func TryPickRandomGame(system *games.System, folder string) (string, error) {
 			validFiles = append(validFiles, file)
 		} else if utils.IsZip(file.Name()) {
 			validFiles = append(validFiles, file)
-		} else if games.MatchSystemFile(*system, file.Name()) {
+		} else if games.MatchSystemFile(*system, file.Name()) && !games.IsExtraDisc(system.Id, file.Name()) {
 			validFiles = append(validFiles, file)
 		}
 	}
@@ -424,7 +385,7 @@ func TryPickRandomGame(system *games.System, folder string) (string, error) {
 			return "", err
 		}
 		zipPath := filepath.Join(path, randomZip)
-		if games.MatchSystemFile(*system, zipPath) {
+		if games.MatchSystemFile(*system, zipPath) && !games.IsExtraDisc(system.Id, zipPath) {
 			return zipPath, nil
 		} else {
 			return "", fmt.Errorf("invalid file picked in %s", path)
@@ -434,9 +395,27 @@ func TryPickRandomGame(system *games.System, folder string) (string, error) {
 	}
 }
 
+// LaunchRandomGame launches a random game from one of the given systems. Each
+// system has an equal chance of being picked. The games index is used if it
+// exists, otherwise the games folders are read.
 func LaunchRandomGame(cfg *config.UserConfig, systems []games.System) error {
 	const maxTries = 100
 
+	// skip arcade games with missing ROMs
+	mras := NewMRAValidator(cfg)
+
+	if idx, err := index.New(cfg); err == nil && idx.Exists() {
+		game, err := index.PickGame(idx, systems, gamesdb.WeightSystems, cfg.Search.Regions, mras.Launchable)
+		if err == nil {
+			system, err := games.GetSystem(game.SystemId)
+			if err != nil {
+				return err
+			}
+
+			return LaunchGame(cfg, *system, game.Path)
+		}
+	}
+
 	populated := games.GetPopulatedGamesFolders(cfg, systems)
 	if len(populated) == 0 {
 		return fmt.Errorf("no populated games folders found")
@@ -462,6 +441,9 @@ func LaunchRandomGame(cfg *config.UserConfig, systems []games.System) error {
 			continue
 		}
 
+		// only pick from one release of each game
+		files = games.Dedupe(files, cfg.Search.Regions)
+
 		system, err := games.GetSystem(systemId)
 		if err != nil {
 			return err
@@ -470,6 +452,8 @@ func LaunchRandomGame(cfg *config.UserConfig, systems []games.System) error {
 		game, err := utils.RandomElem(files)
 		if err != nil {
 			return err
+		} else if !mras.Launchable(game) {
+			continue
 		}
 
 		return LaunchGame(cfg, *system, game)
@@ -492,7 +476,6 @@ func LaunchToken(cfg *config.UserConfig, manual bool, kbd input.Keyboard, text s
 		cmd, args := s.TrimSpace(parts[0]), s.TrimSpace(parts[1])
 
 		// TODO: search game file
-		// TODO: game file by hash
 
 		switch cmd {
 		case "system":
@@ -523,17 +506,30 @@ func LaunchToken(cfg *config.UserConfig, manual bool, kbd input.Keyboard, text s
 				return fmt.Errorf("no system specified")
 			}
 
-			if args == "all" {
-				return LaunchRandomGame(cfg, games.AllSystems())
+			// supports lists and groups, e.g. "all" or "@console,-@nintendo"
+			systems, err := games.LookupSystems(args)
+			if err != nil {
+				return err
 			}
 
-			// TODO: allow multiple systems
-			system, err := games.LookupSystem(args)
+			return LaunchRandomGame(cfg, systems)
+		case "hash":
+			// requires the gamesdb to be indexed with hash_files enabled
+			results, err := gamesdb.FindByHash(games.AllSystems(), args)
 			if err != nil {
 				return err
 			}
 
-			return LaunchRandomGame(cfg, []games.System{*system})
+			for _, result := range results {
+				system, err := games.GetSystem(result.SystemId)
+				if err != nil {
+					continue
+				}
+
+				return LaunchGame(cfg, *system, result.Path)
+			}
+
+			return fmt.Errorf("no game found with hash: %s", args)
 		case "ini":
 			inis, err := GetAllMisterIni()
 			if err != nil {
@@ -0,0 +1,249 @@
+package mister
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
+)
+
+// MGL file paths are relative to the core's games folder, this prefix
+// climbs back up to the root so absolute paths can be used.
+const mglPathPrefix = "../../../../.."
+
+// MGLFile is a file loaded into one of the core's slots.
+type MGLFile struct {
+	Delay int `xml:"delay,attr"`
+	// "f" to load the file, "s" to mount it.
+	Type  string `xml:"type,attr"`
+	Index int    `xml:"index,attr"`
+	Path  string `xml:"path,attr"`
+}
+
+// MGLSetName renames the core so it uses its own settings and games folder.
+type MGLSetName struct {
+	Name string `xml:",chardata"`
+	// Set to 1 to keep using the games folder of the original core.
+	SameDir int `xml:"same_dir,attr,omitempty"`
+}
+
+// MGLReset resets the core after all files have been loaded.
+type MGLReset struct {
+	Delay int        `xml:"delay,attr"`
+	Attrs []xml.Attr `xml:",any,attr"`
+}
+
+// MGL is a shortcut which launches a core with a setname, files and an
+// optional reset.
+type MGL struct {
+	XMLName xml.Name              `xml:"mistergamedescription"`
+	Rbf     string                `xml:"rbf"`
+	SetName *MGLSetName           `xml:"setname"`
+	Files   []MGLFile             `xml:"file"`
+	Reset   *MGLReset             `xml:"reset"`
+	Other   []metadata.XMLElement `xml:",any"`
+}
+
+// GameFile returns the path of the game the MGL launches, as written in the
+// file. When there are multiple files, the last one is the game and any
+// before it are extra disks. Blank if the MGL only launches a core.
+func (m MGL) GameFile() string {
+	if len(m.Files) == 0 {
+		return ""
+	}
+	return m.Files[len(m.Files)-1].Path
+}
+
+// Marshal returns the MGL as it's written to a file.
+func (m MGL) Marshal() ([]byte, error) {
+	data, err := xml.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func decodeMgl(data []byte) (MGL, error) {
+	var mgl MGL
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	err := decoder.Decode(&mgl)
+	return mgl, err
+}
+
+// ReadMgl parses an MGL file.
+func ReadMgl(path string) (MGL, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return MGL{}, err
+	}
+
+	return decodeMgl(file)
+}
+
+// WriteMgl writes an MGL file, replacing any existing file.
+func WriteMgl(path string, mgl MGL) error {
+	data, err := mgl.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Merge the elements of a system hook's MGL snippet into a generated MGL.
+// Files in the snippet replace the default game file.
+func applyMglOverride(mgl *MGL, override string) error {
+	snippet, err := decodeMgl([]byte("<mistergamedescription>" + override + "</mistergamedescription>"))
+	if err != nil {
+		return err
+	}
+
+	if snippet.Rbf != "" {
+		mgl.Rbf = snippet.Rbf
+	}
+	if snippet.SetName != nil {
+		mgl.SetName = snippet.SetName
+	}
+	mgl.Files = snippet.Files
+	if snippet.Reset != nil {
+		mgl.Reset = snippet.Reset
+	}
+	mgl.Other = append(mgl.Other, snippet.Other...)
+
+	return nil
+}
+
+// Apply the first matching rule from the core rules file to an MGL. Invalid
+// rules in the file are skipped.
+func applyCoreRule(mgl *MGL, systemId string, path string) error {
+	rules, err := ReadCoreRules(config.CoreRulesFile)
+	var rulesErr *CoreRulesError
+	if err != nil && !errors.As(err, &rulesErr) {
+		return err
+	}
+
+	rule := MatchCoreRule(rules, systemId, path)
+	if rule == nil {
+		return nil
+	}
+
+	if rule.Rbf != "" {
+		mgl.Rbf = rule.Rbf
+	}
+
+	if rule.SetName != "" {
+		mgl.SetName = &MGLSetName{Name: rule.SetName}
+		if rule.SetNameSameDir {
+			mgl.SetName.SameDir = 1
+		}
+	}
+
+	if rule.Mgl != nil && len(mgl.Files) > 0 {
+		file := &mgl.Files[len(mgl.Files)-1]
+		file.Delay = rule.Mgl.Delay
+		file.Type = rule.Mgl.Method
+		file.Index = rule.Mgl.Index
+	}
+
+	return nil
+}
+
+// NewMgl creates an MGL which launches a game with a system's core. If path
+// is blank, only the core is launched, and M3U playlists launch their first
+// disc. The override is an MGL snippet from a system hook, and replaces the
+// game file if not blank. Games matching a rule in the core rules file use
+// its core, setname and slot instead. See games.PreferredRbf for which core
+// is launched otherwise.
+func NewMgl(cfg *config.UserConfig, system *games.System, path string, override string) (MGL, error) {
+	// use an alternate core set if it's preferred, or the only one installed
+	if rbf, ok := games.PreferredRbf(cfg, *system); ok && rbf.Variant != "" {
+		system.Rbf = rbf.MglName
+	}
+
+	// override the system rbf with the user specified one
+	for _, setCore := range cfg.Systems.SetCore {
+		parts := strings.SplitN(setCore, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		if strings.EqualFold(parts[0], system.Id) {
+			system.Rbf = parts[1]
+			break
+		}
+	}
+
+	mgl := MGL{Rbf: system.Rbf}
+
+	if system.SetName != "" {
+		mgl.SetName = &MGLSetName{Name: system.SetName}
+		if system.SetNameSameDir {
+			mgl.SetName.SameDir = 1
+		}
+	}
+
+	if path == "" {
+		return mgl, nil
+	} else if strings.EqualFold(filepath.Ext(path), ".m3u") {
+		// multi-disc playlists launch their first disc
+		discs, err := games.ReadM3u(path)
+		if err != nil {
+			return mgl, err
+		} else if len(discs) == 0 {
+			return mgl, fmt.Errorf("no discs in playlist: %s", path)
+		}
+		path = discs[0]
+	}
+
+	if override != "" {
+		err := applyMglOverride(&mgl, override)
+		if err != nil {
+			return mgl, err
+		}
+	} else {
+		mglDef, err := games.PathToMglDef(*system, path)
+		if err != nil {
+			return mgl, err
+		}
+
+		mgl.Files = []MGLFile{{
+			Delay: mglDef.Delay,
+			Type:  mglDef.Method,
+			Index: mglDef.Index,
+			Path:  mglPathPrefix + path,
+		}}
+	}
+
+	err := applyCoreRule(&mgl, system.Id, path)
+	if err != nil {
+		return mgl, fmt.Errorf("error reading core rules: %s", err)
+	}
+
+	return mgl, nil
+}
+
+// GenerateMgl returns the contents of an MGL file which launches a game. See
+// NewMgl.
+func GenerateMgl(cfg *config.UserConfig, system *games.System, path string, override string) (string, error) {
+	mgl, err := NewMgl(cfg, system, path, override)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := mgl.Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,72 @@
+package mister
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+)
+
+const testMgl = `<mistergamedescription>
+	<rbf>_Computer/ao486</rbf>
+	<setname same_dir="1">ao486</setname>
+	<file delay="0" type="s" index="4" path="../../../../../media/fat/games/AO486/game.iso"></file>
+	<file delay="0" type="s" index="2" path="../../../../../media/fat/games/AO486/game.vhd"></file>
+	<reset delay="1"></reset>
+	<comment>edited by hand</comment>
+</mistergamedescription>
+`
+
+func TestReadMgl(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.mgl")
+	err := os.WriteFile(path, []byte(testMgl), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgl, err := ReadMgl(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mgl.Files) != 2 || mgl.Reset == nil || mgl.SetName.SameDir != 1 {
+		t.Errorf("ReadMgl() = %+v", mgl)
+	}
+	if mgl.GameFile() != "../../../../../media/fat/games/AO486/game.vhd" {
+		t.Errorf("GameFile() = %s", mgl.GameFile())
+	}
+
+	err = WriteMgl(path, mgl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != testMgl {
+		t.Errorf("WriteMgl() = %s, want %s", data, testMgl)
+	}
+}
+
+func TestNewMgl(t *testing.T) {
+	system, err := games.GetSystem("ao486")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	override := "\t<file delay=\"0\" type=\"s\" index=\"4\" path=\"../../../../../media/fat/games/AO486/game.iso\"/>\n" +
+		"\t<file delay=\"0\" type=\"s\" index=\"2\" path=\"../../../../../media/fat/games/AO486/game.vhd\"/>\n" +
+		"\t<reset delay=\"1\"/>\n"
+
+	mgl, err := NewMgl(&config.UserConfig{}, system, "/media/fat/games/AO486/game.vhd", override)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mgl.Rbf != system.Rbf || len(mgl.Files) != 2 || mgl.Reset == nil {
+		t.Errorf("NewMgl() = %+v", mgl)
+	}
+	if mgl.GameFile() != "../../../../../media/fat/games/AO486/game.vhd" {
+		t.Errorf("GameFile() = %s", mgl.GameFile())
+	}
+}
@@ -0,0 +1,175 @@
+package mister
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
+)
+
+// MRAMissingRom is a ROM zip or part which couldn't be found. Part is blank
+// if none of the zips were found.
+type MRAMissingRom struct {
+	Zip  string
+	Part string
+	Crc  string
+}
+
+func (m MRAMissingRom) String() string {
+	if m.Part == "" {
+		return fmt.Sprintf("missing zip: %s", m.Zip)
+	}
+	return fmt.Sprintf("missing part in %s: %s (%s)", m.Zip, m.Part, m.Crc)
+}
+
+type mraZip struct {
+	names map[string]struct{}
+	crcs  map[string]struct{}
+}
+
+// MRAValidator checks the ROMs of MRA files exist in the mame and hbmame
+// folders. Zips are only read once, so one validator should be used to
+// check many MRAs.
+type MRAValidator struct {
+	folders []string
+	zips    map[string]*mraZip
+}
+
+// NewMRAValidator creates a validator which looks for ROM zips in the mame
+// and hbmame folders of every games folder.
+func NewMRAValidator(cfg *config.UserConfig) *MRAValidator {
+	var folders []string
+	for _, folder := range games.GetGamesFolders(cfg) {
+		for _, sub := range []string{"mame", "hbmame"} {
+			if info, err := os.Stat(filepath.Join(folder, sub)); err == nil && info.IsDir() {
+				folders = append(folders, filepath.Join(folder, sub))
+			}
+		}
+	}
+
+	return &MRAValidator{
+		folders: folders,
+		zips:    make(map[string]*mraZip),
+	}
+}
+
+// Return the contents of the first matching zip found, or nil if it doesn't
+// exist.
+func (v *MRAValidator) findZip(name string) *mraZip {
+	if z, ok := v.zips[name]; ok {
+		return z
+	}
+
+	var found *mraZip
+	for _, folder := range v.folders {
+		r, err := zip.OpenReader(filepath.Join(folder, filepath.Base(name)))
+		if err != nil {
+			continue
+		}
+
+		found = &mraZip{
+			names: make(map[string]struct{}),
+			crcs:  make(map[string]struct{}),
+		}
+		for _, f := range r.File {
+			found.names[strings.ToLower(f.Name)] = struct{}{}
+			found.crcs[fmt.Sprintf("%08x", f.CRC32)] = struct{}{}
+		}
+		r.Close()
+
+		break
+	}
+
+	v.zips[name] = found
+	return found
+}
+
+// Validate returns every ROM zip and part needed by the MRA which can't be
+// found. Parts are matched by CRC32 first, like the MiSTer does, and then by
+// name. An MRA is launchable if nothing is returned.
+func (v *MRAValidator) Validate(mra metadata.MRA) []MRAMissingRom {
+	var missing []MRAMissingRom
+
+	for _, rom := range mra.Roms {
+		var found []*mraZip
+		for _, name := range rom.Zips() {
+			if z := v.findZip(name); z != nil {
+				found = append(found, z)
+			}
+		}
+
+		if len(rom.Zips()) > 0 && len(found) == 0 {
+			missing = append(missing, MRAMissingRom{Zip: rom.Zip})
+			continue
+		}
+
+		for _, part := range rom.Parts() {
+			zips := found
+			zipName := rom.Zip
+			if part.Zip != "" {
+				zipName = part.Zip
+				zips = nil
+				if z := v.findZip(part.Zip); z != nil {
+					zips = append(zips, z)
+				}
+			}
+
+			if !partInZips(part, zips) {
+				missing = append(missing, MRAMissingRom{
+					Zip:  zipName,
+					Part: part.Name,
+					Crc:  part.Crc,
+				})
+			}
+		}
+	}
+
+	return missing
+}
+
+func partInZips(part metadata.MRAPart, zips []*mraZip) bool {
+	for _, z := range zips {
+		if part.Crc != "" {
+			if _, ok := z.crcs[strings.ToLower(part.Crc)]; ok {
+				return true
+			}
+		}
+		if part.Name != "" {
+			if _, ok := z.names[strings.ToLower(part.Name)]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Launchable returns true if the file is an MRA with all its ROMs available,
+// or isn't an MRA.
+func (v *MRAValidator) Launchable(path string) bool {
+	if !strings.EqualFold(filepath.Ext(path), ".mra") {
+		return true
+	}
+
+	mra, err := metadata.ReadMra(path)
+	if err != nil {
+		return false
+	}
+
+	return len(v.Validate(mra)) == 0
+}
+
+// ValidateMra reads an MRA file and returns every ROM zip and part it needs
+// which can't be found. See MRAValidator.
+func ValidateMra(cfg *config.UserConfig, path string) ([]MRAMissingRom, error) {
+	mra, err := metadata.ReadMra(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMRAValidator(cfg).Validate(mra), nil
+}
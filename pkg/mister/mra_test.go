@@ -0,0 +1,93 @@
+package mister
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
+)
+
+const testMra = `<misterromdescription>
+	<name>1942 (Revision B)</name>
+	<setname>1942</setname>
+	<rbf>jt1942</rbf>
+	<rom index="0" zip="1942.zip|1942b.zip" md5="none">
+		<part crc="0cc4e161" name="renamed.bin"></part>
+		<interleave output="16">
+			<part name="srb-03.m3"></part>
+			<part crc="12345678" name="missing.bin"></part>
+		</interleave>
+		<part>00 01 02</part>
+	</rom>
+	<rom index="1" zip="other.zip">
+		<part name="other.bin"></part>
+	</rom>
+	<nvram index="2" size="128"></nvram>
+	<about author="jotego"></about>
+</misterromdescription>
+`
+
+func TestValidateMra(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.MkdirAll(filepath.Join(dir, "games", "mame"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(filepath.Join(dir, "games", "mame", "1942.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, data := range map[string]string{"srb-04.m4": "def", "srb-03.m3": "abc"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(data))
+	}
+	_ = zw.Close()
+	_ = f.Close()
+
+	path := filepath.Join(dir, "1942.mra")
+	err = os.WriteFile(path, []byte(testMra), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mra, err := metadata.ReadMra(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unknown elements are kept, after the known ones
+	err = metadata.WriteMra(path, mra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != testMra {
+		t.Errorf("WriteMra() = %s, want %s", data, testMra)
+	}
+
+	cfg := &config.UserConfig{}
+	cfg.Systems.GamesFolder = []string{dir}
+
+	got, err := ValidateMra(cfg, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []MRAMissingRom{
+		{Zip: "1942.zip|1942b.zip", Part: "missing.bin", Crc: "12345678"},
+		{Zip: "other.zip"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateMra() = %v, want %v", got, want)
+	}
+}
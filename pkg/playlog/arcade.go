@@ -0,0 +1,132 @@
+package playlog
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/wizzomafizzo/mrext/pkg/tracker"
+)
+
+// ArcadeSets returns every arcade set which has been launched from an MRA.
+func (p *Db) ArcadeSets() ([]tracker.ArcadeSet, error) {
+	rows, err := p.db.Query("select id, setname, parent, name from arcade_sets order by id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sets []tracker.ArcadeSet
+	for rows.Next() {
+		var as tracker.ArcadeSet
+		err = rows.Scan(&as.Id, &as.SetName, &as.Parent, &as.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		sets = append(sets, as)
+	}
+
+	return sets, rows.Err()
+}
+
+// ArcadeSetTime is the play time of a single arcade set.
+type ArcadeSetTime struct {
+	tracker.ArcadeSet
+	Time int
+}
+
+// ArcadeGroup is the total play time of a parent arcade set and its clones.
+type ArcadeGroup struct {
+	Parent string
+	Name   string
+	Time   int
+	Sets   []ArcadeSetTime
+}
+
+// ArcadeSetTimes returns the play time of every arcade game. Games which have
+// never been launched from an MRA only have their ID and name.
+func (p *Db) ArcadeSetTimes() ([]ArcadeSetTime, error) {
+	rows, err := p.db.Query(
+		`select g.id, g.name, g.time,
+			coalesce(a.setname, ''), coalesce(a.parent, ''), coalesce(a.name, '')
+		from game_times g left join arcade_sets a on a.id = g.id
+		where g.id like ? order by g.time desc`,
+		tracker.ArcadeSystem+"/%",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sets []ArcadeSetTime
+	for rows.Next() {
+		var st ArcadeSetTime
+		var gameName, setName string
+		err = rows.Scan(&st.Id, &gameName, &st.Time, &st.SetName, &st.Parent, &setName)
+		if err != nil {
+			return nil, err
+		}
+
+		if st.SetName == "" {
+			st.SetName = strings.TrimPrefix(st.Id, tracker.ArcadeSystem+"/")
+		}
+
+		st.Name = setName
+		if st.Name == "" {
+			st.Name = gameName
+		}
+
+		sets = append(sets, st)
+	}
+
+	return sets, rows.Err()
+}
+
+// GroupArcadeSets groups the play times of arcade sets by their parent set,
+// most played first. Groups are named after their parent if it's been played,
+// otherwise its setname.
+func GroupArcadeSets(sets []ArcadeSetTime) []ArcadeGroup {
+	groups := make(map[string]*ArcadeGroup)
+	var order []string
+
+	for _, st := range sets {
+		parent := st.Parent
+		if parent == "" {
+			parent = st.SetName
+		}
+		key := strings.ToLower(parent)
+
+		g, ok := groups[key]
+		if !ok {
+			g = &ArcadeGroup{Parent: parent}
+			groups[key] = g
+			order = append(order, key)
+		}
+
+		g.Time += st.Time
+		g.Sets = append(g.Sets, st)
+		if strings.EqualFold(st.SetName, parent) {
+			g.Name = st.Name
+		}
+	}
+
+	result := make([]ArcadeGroup, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		if g.Name == "" {
+			g.Name = g.Parent
+		}
+
+		sort.SliceStable(g.Sets, func(i, j int) bool {
+			return g.Sets[i].Time > g.Sets[j].Time
+		})
+
+		result = append(result, *g)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time > result[j].Time
+	})
+
+	return result
+}
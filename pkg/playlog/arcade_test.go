@@ -0,0 +1,74 @@
+package playlog
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/wizzomafizzo/mrext/pkg/tracker"
+)
+
+func TestArcadeGroups(t *testing.T) {
+	pl, err := Open(filepath.Join(t.TempDir(), "playlog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pl.Close()
+
+	for _, as := range []tracker.ArcadeSet{
+		{Id: "Arcade/sf2", SetName: "sf2", Name: "Street Fighter II"},
+		{Id: "Arcade/sf2ua", SetName: "sf2ua", Parent: "sf2", Name: "Street Fighter II (USA)"},
+		{Id: "Arcade/dkongj", SetName: "dkongj", Parent: "dkong", Name: "Donkey Kong (Japan)"},
+	} {
+		err = pl.UpdateArcadeSet(as)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, gt := range []tracker.GameTime{
+		{Id: "Arcade/sf2", Name: "Street Fighter II", Time: 100},
+		{Id: "Arcade/sf2ua", Name: "Street Fighter II (USA)", Time: 200},
+		{Id: "Arcade/dkongj", Name: "Donkey Kong (Japan)", Time: 250},
+		// matched by core name, never launched from an MRA
+		{Id: "Arcade/pacman", Name: "Pac-Man", Time: 50},
+		{Id: "SNES/zelda", Name: "Zelda", Time: 1000},
+	} {
+		err = pl.UpdateGame(gt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sets, err := pl.ArcadeSetTimes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := GroupArcadeSets(sets)
+
+	want := []struct {
+		parent string
+		name   string
+		time   int
+		sets   int
+	}{
+		{"sf2", "Street Fighter II", 300, 2},
+		{"dkong", "dkong", 250, 1},
+		{"pacman", "Pac-Man", 50, 1},
+	}
+
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(groups), len(want), groups)
+	}
+
+	for i, w := range want {
+		g := groups[i]
+		if g.Parent != w.parent || g.Name != w.name || g.Time != w.time || len(g.Sets) != w.sets {
+			t.Errorf("group %d = %+v, want %+v", i, g, w)
+		}
+	}
+
+	if groups[0].Sets[0].SetName != "sf2ua" {
+		t.Errorf("most played set = %s, want sf2ua", groups[0].Sets[0].SetName)
+	}
+}
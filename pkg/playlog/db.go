@@ -0,0 +1,1070 @@
+package playlog
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
+	"github.com/wizzomafizzo/mrext/pkg/tracker"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Db is the PlayLog database of events, sessions and play times. It
+// implements tracker.Db so a tracker can record to it directly.
+type Db struct {
+	db   *sql.DB
+	path string
+	lock *os.File
+}
+
+// Open opens a PlayLog database, creating or migrating its tables as needed.
+// The default database is at config.PlayLogDbFile.
+func Open(path string) (*Db, error) {
+	pldb := &Db{path: path}
+
+	// PlayLog and Remote may both open the database at the same time, so
+	// transactions must take the write lock straight away to wait for
+	// each other
+	db, err := sql.Open("sqlite3", path+"?_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+
+	pldb.db = db
+	err = pldb.setupDb()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return pldb, nil
+}
+
+func (p *Db) Close() error {
+	if p.lock != nil {
+		p.lock.Close()
+	}
+	return p.db.Close()
+}
+
+// LockRecording takes the lock on recording play times to the database, and
+// reports false if another process already holds it. Trackers keep their
+// play times in memory and save them whole, so only one may record at a time.
+// The lock is held until the database is closed or the process exits.
+func (p *Db) LockRecording() (bool, error) {
+	if p.lock != nil {
+		return true, nil
+	}
+
+	f, err := os.OpenFile(p.path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		f.Close()
+		return false, nil
+	} else if err != nil {
+		f.Close()
+		return false, err
+	}
+
+	p.lock = f
+	return true, nil
+}
+
+func (p *Db) NoResults(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
+
+func (p *Db) setupDb() error {
+	sqlEvents := `create table if not exists events (
+		timestamp timestamp not null,
+		action integer not null,
+		target text not null,
+		total_time integer not null
+	)`
+	_, err := p.db.Exec(sqlEvents)
+	if err != nil {
+		return err
+	}
+
+	sqlCoreTimes := `create table if not exists core_times (
+		name integer not null unique,
+		time integer not null
+	)`
+	_, err = p.db.Exec(sqlCoreTimes)
+	if err != nil {
+		return err
+	}
+
+	sqlGameTimes := `create table if not exists game_times (
+		id text not null unique,
+		path text not null,
+		name text not null,
+		folder text not null,
+		time integer not null
+	)`
+	_, err = p.db.Exec(sqlGameTimes)
+	if err != nil {
+		return err
+	}
+
+	sqlArcadeSets := `create table if not exists arcade_sets (
+		id text not null unique,
+		setname text not null,
+		parent text not null,
+		name text not null
+	)`
+	_, err = p.db.Exec(sqlArcadeSets)
+	if err != nil {
+		return err
+	}
+
+	// play times already added from each imported database, so importing
+	// a newer export from the same database only adds the difference
+	sqlImportedTimes := `create table if not exists imported_times (
+		source text not null,
+		type text not null,
+		id text not null,
+		time integer not null,
+		unique (source, type, id)
+	)`
+	_, err = p.db.Exec(sqlImportedTimes)
+	if err != nil {
+		return err
+	}
+
+	sqlInfo := `create table if not exists info (
+		key text not null unique,
+		value text not null
+	)`
+	_, err = p.db.Exec(sqlInfo)
+	if err != nil {
+		return err
+	}
+
+	err = p.setupSessions()
+	if err != nil {
+		return err
+	}
+
+	err = p.setupUniqueIndexes()
+	if err != nil {
+		return err
+	}
+
+	err = p.migrateGameIds()
+	if err != nil {
+		return err
+	}
+
+	return p.migrateArcadeIds()
+}
+
+// setupSessions creates the sessions table, and fills it from the events
+// table if it didn't already exist.
+func (p *Db) setupSessions() error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow(
+		"select count(*) from sqlite_master where type = 'table' and name = 'sessions'",
+	).Scan(&exists)
+	if err != nil {
+		return err
+	} else if exists > 0 {
+		return nil
+	}
+
+	sqlSessions := `create table sessions (
+		id integer primary key,
+		start_time timestamp not null,
+		end_time timestamp not null,
+		duration integer not null,
+		core text not null,
+		system text not null,
+		game_id text not null,
+		game_path text not null,
+		game_name text not null,
+		ended_by integer not null
+	)`
+	_, err = tx.Exec(sqlSessions)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := migrateSessions(tx)
+	if err != nil {
+		return fmt.Errorf("error migrating sessions: %s", err)
+	}
+
+	for _, s := range sessions {
+		_, err = insertSession(tx, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// setupUniqueIndexes stops the same session or event being stored twice,
+// which imports rely on to skip ones that already exist. Duplicates stored
+// before the indexes existed are removed first.
+func (p *Db) setupUniqueIndexes() error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow(
+		"select count(*) from sqlite_master where type = 'index' and name = 'events_unique'",
+	).Scan(&exists)
+	if err != nil {
+		return err
+	} else if exists > 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(
+		`delete from sessions where id not in (
+			select min(id) from sessions group by start_time, core, game_id
+		)`,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`delete from events where rowid not in (
+			select min(rowid) from events group by timestamp, action, target
+		)`,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("create unique index sessions_unique on sessions (start_time, core, game_id)")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("create unique index events_unique on events (timestamp, action, target)")
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// migrateSessions builds sessions from the existing events. Sessions with a
+// missing stop event are marked as ended by power loss with no duration,
+// because there's no way to know how long they really went for.
+func migrateSessions(tx *sql.Tx) ([]tracker.Session, error) {
+	rows, err := tx.Query(
+		"select timestamp, action, target from events where action != ? order by timestamp",
+		tracker.EventActionMenuNavigation,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []tracker.EventAction
+	for rows.Next() {
+		var ev tracker.EventAction
+		err = rows.Scan(&ev.Timestamp, &ev.Action, &ev.Target)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	rows.Close()
+
+	var sessions []tracker.Session
+	var core, game *tracker.Session
+
+	closeSession := func(s *tracker.Session, at time.Time, endedBy int) {
+		s.EndedBy = endedBy
+		if endedBy == tracker.SessionEndedByPowerLoss {
+			s.End = s.Start
+		} else {
+			s.End = at
+			s.Duration = int(at.Sub(s.Start).Seconds())
+		}
+		if s.Duration > 0 || endedBy == tracker.SessionEndedByPowerLoss {
+			sessions = append(sessions, *s)
+		}
+	}
+
+	for _, ev := range events {
+		switch ev.Action {
+		case tracker.EventActionCoreStart:
+			if game != nil {
+				closeSession(game, ev.Timestamp, tracker.SessionEndedByPowerLoss)
+				game = nil
+			}
+			if core != nil {
+				closeSession(core, ev.Timestamp, tracker.SessionEndedByPowerLoss)
+			}
+			core = &tracker.Session{
+				Start: ev.Timestamp,
+				Core:  ev.Target,
+			}
+		case tracker.EventActionCoreStop:
+			// cores only get their own session if no game was played
+			if core != nil && core.GameId == "" {
+				closeSession(core, ev.Timestamp, tracker.SessionEndedByStop)
+			}
+			core = nil
+		case tracker.EventActionGameStart:
+			if game != nil {
+				closeSession(game, ev.Timestamp, tracker.SessionEndedByGameSwitch)
+			}
+			game = &tracker.Session{
+				Start:  ev.Timestamp,
+				GameId: ev.Target,
+			}
+			if core != nil {
+				game.Core = core.Core
+				core.GameId = ev.Target
+			}
+		case tracker.EventActionGameStop:
+			if game != nil {
+				closeSession(game, ev.Timestamp, tracker.SessionEndedByStop)
+			}
+			game = nil
+		}
+	}
+
+	if game != nil {
+		closeSession(game, time.Time{}, tracker.SessionEndedByPowerLoss)
+	}
+	if core != nil && core.GameId == "" {
+		closeSession(core, time.Time{}, tracker.SessionEndedByPowerLoss)
+	}
+
+	for i, s := range sessions {
+		if s.GameId == "" {
+			continue
+		}
+
+		// game IDs are the system ID and filename
+		if system, _, ok := strings.Cut(s.GameId, "/"); ok {
+			sessions[i].System = system
+		}
+
+		var path, name string
+		err = tx.QueryRow(
+			"select path, name from game_times where id = ?",
+			s.GameId,
+		).Scan(&path, &name)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		sessions[i].GamePath = path
+		sessions[i].GameName = name
+	}
+
+	return sessions, nil
+}
+
+func (p *Db) GetCore(name string) (tracker.CoreTime, error) {
+	var core tracker.CoreTime
+
+	err := p.db.QueryRow("select name, time from core_times where name = ?", name).Scan(&core.Name, &core.Time)
+
+	if err != nil {
+		return core, err
+	}
+
+	return core, nil
+}
+
+func (p *Db) UpdateCore(core tracker.CoreTime) error {
+	_, err := p.db.Exec("insert or replace into core_times (name, time) values (?, ?)", core.Name, core.Time)
+	return err
+}
+
+func (p *Db) GetGame(id string) (tracker.GameTime, error) {
+	var game tracker.GameTime
+
+	err := p.db.QueryRow(
+		"select id, path, name, folder, time from game_times where id = ?",
+		id,
+	).Scan(
+		&game.Id,
+		&game.Path,
+		&game.Name,
+		&game.Folder,
+		&game.Time,
+	)
+
+	if err != nil {
+		return game, err
+	}
+
+	return game, nil
+}
+
+func (p *Db) UpdateGame(game tracker.GameTime) error {
+	_, err := p.db.Exec(
+		"insert or replace into game_times (id, path, name, folder, time) values (?, ?, ?, ?, ?)",
+		game.Id,
+		game.Path,
+		game.Name,
+		game.Folder,
+		game.Time,
+	)
+	return err
+}
+
+// renameGame moves a game's play time, events and sessions to a new ID. If
+// the new ID already has a play time, they're added together.
+func renameGame(tx *sql.Tx, oldId string, newId string) error {
+	var exists int
+	err := tx.QueryRow("select count(*) from game_times where id = ?", newId).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists > 0 {
+		_, err = tx.Exec(
+			`update game_times set time = time + coalesce((
+				select time from game_times where id = ?
+			), 0) where id = ?`,
+			oldId,
+			newId,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("delete from game_times where id = ?", oldId)
+	} else {
+		_, err = tx.Exec("update game_times set id = ? where id = ?", newId, oldId)
+	}
+	if err != nil {
+		return err
+	}
+
+	// events and sessions left behind are already stored under the new ID
+	_, err = tx.Exec(
+		"update or ignore events set target = ? where target = ? and (action = ? or action = ?)",
+		newId,
+		oldId,
+		tracker.EventActionGameStart,
+		tracker.EventActionGameStop,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"delete from events where target = ? and (action = ? or action = ?)",
+		oldId,
+		tracker.EventActionGameStart,
+		tracker.EventActionGameStop,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("update or ignore sessions set game_id = ? where game_id = ?", newId, oldId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("delete from sessions where game_id = ?", oldId)
+	return err
+}
+
+func (p *Db) RenameGame(oldId string, newId string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = renameGame(tx, oldId, newId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Game IDs used to be the system ID and filename of a game. This moves every
+// game to its tracker.GameIdentity ID, merging games which are now the same.
+// Only runs once, tracked by the database's user_version.
+func (p *Db) migrateGameIds() error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	err = tx.QueryRow("pragma user_version").Scan(&version)
+	if err != nil {
+		return err
+	} else if version >= 1 {
+		return nil
+	}
+
+	// sessions may have games which never had a play time saved
+	rows, err := tx.Query(
+		`select id, path from game_times
+		union select game_id, game_path from sessions where game_id != ''`,
+	)
+	if err != nil {
+		return err
+	}
+
+	paths := make(map[string]string)
+	for rows.Next() {
+		var id, path string
+		err = rows.Scan(&id, &path)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		if _, ok := paths[id]; !ok {
+			paths[id] = path
+		}
+	}
+	rows.Close()
+
+	for oldId, newId := range gameIdRenames(paths) {
+		err = renameGame(tx, oldId, newId)
+		if err != nil {
+			return fmt.Errorf("error migrating game %s: %s", oldId, err)
+		}
+	}
+
+	_, err = tx.Exec("pragma user_version = 1")
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// gameIdRenames returns the tracker.GameIdentity ID of each game stored
+// under its system ID and filename, keyed by the old ID. Games are given as
+// their old IDs and paths, and a missing path uses the filename instead. Games
+// with the same ID as before aren't included.
+func gameIdRenames(paths map[string]string) map[string]string {
+	// without a gamesdb, games are only identified by their filename
+	fl, _ := gamesdb.OpenFileLookup()
+	defer fl.Close()
+
+	renames := make(map[string]string)
+	for id, path := range paths {
+		systemId, filename, ok := strings.Cut(id, "/")
+		if !ok {
+			continue
+		} else if path == "" {
+			path = filename
+		}
+
+		newId := tracker.NewGameIdentityWith(fl, systemId, path).Id
+		if newId != id {
+			renames[id] = newId
+		}
+	}
+
+	resolved := make(map[string]string)
+	for oldId, newId := range renames {
+		// the new ID may be the old ID of another game
+		for seen := 0; seen < len(renames); seen++ {
+			next, ok := renames[newId]
+			if !ok {
+				break
+			}
+			newId = next
+		}
+		if newId != oldId {
+			resolved[oldId] = newId
+		}
+	}
+
+	return resolved
+}
+
+// readArcadeNames returns the lowercase setname of every game in the
+// ArcadeDB, which were the only arcade games recorded before MRAs were read.
+var readArcadeNames = func() (map[string]bool, error) {
+	entries, err := metadata.ReadArcadeDb()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		names[strings.ToLower(entry.Setname)] = true
+	}
+
+	return names, nil
+}
+
+// isLegacyArcadeId reports whether a game ID is an arcade game stored under
+// the name of its core. Every other game ID has a slash, but sessions built
+// from old events don't have a system, so those must be a known setname.
+func isLegacyArcadeId(id string, system string, known map[string]bool) bool {
+	if id == "" || strings.Contains(id, "/") {
+		return false
+	}
+	return system == tracker.ArcadeSystem || known[strings.ToLower(id)]
+}
+
+// Arcade games used to be stored under the name of their core, which is
+// usually their setname. This moves them to their tracker.ArcadeGameId ID.
+// Only runs once, tracked by the database's user_version. If the ArcadeDB
+// can't be read, games not known to be arcade games are left until it can.
+func (p *Db) migrateArcadeIds() error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	err = tx.QueryRow("pragma user_version").Scan(&version)
+	if err != nil {
+		return err
+	} else if version >= 2 {
+		return nil
+	}
+
+	known, err := readArcadeNames()
+	complete := err == nil
+	if known == nil {
+		known = make(map[string]bool)
+	}
+
+	rows, err := tx.Query("select setname from arcade_sets")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var setName string
+		err = rows.Scan(&setName)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		known[strings.ToLower(setName)] = true
+	}
+	rows.Close()
+
+	rows, err = tx.Query(
+		`select id, '' from game_times where instr(id, '/') = 0
+		union select game_id, system from sessions where game_id != '' and instr(game_id, '/') = 0`,
+	)
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id, system string
+		err = rows.Scan(&id, &system)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if isLegacyArcadeId(id, system, known) {
+			ids[id] = true
+		}
+	}
+	rows.Close()
+
+	for id := range ids {
+		newId := tracker.ArcadeGameId(id)
+
+		err = renameGame(tx, id, newId)
+		if err != nil {
+			return fmt.Errorf("error migrating arcade game %s: %s", id, err)
+		}
+
+		_, err = tx.Exec(
+			"update sessions set system = ? where game_id = ? and system = ''",
+			tracker.ArcadeSystem,
+			newId,
+		)
+		if err != nil {
+			return fmt.Errorf("error migrating arcade game %s: %s", id, err)
+		}
+	}
+
+	if complete {
+		_, err = tx.Exec("pragma user_version = 2")
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p *Db) UpdateArcadeSet(as tracker.ArcadeSet) error {
+	_, err := p.db.Exec(
+		"insert or replace into arcade_sets (id, setname, parent, name) values (?, ?, ?, ?)",
+		as.Id,
+		as.SetName,
+		as.Parent,
+		as.Name,
+	)
+	return err
+}
+
+func (p *Db) AddEvent(event tracker.EventAction) error {
+	_, err := p.db.Exec(
+		"insert into events (timestamp, action, target, total_time) values (?, ?, ?, ?)",
+		event.Timestamp,
+		event.Action,
+		event.Target,
+		event.TotalTime,
+	)
+	return err
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func insertSession(db execer, s tracker.Session) (int64, error) {
+	result, err := db.Exec(
+		`insert into sessions (
+			start_time, end_time, duration, core, system,
+			game_id, game_path, game_name, ended_by
+		) values (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.Start,
+		s.End,
+		s.Duration,
+		s.Core,
+		s.System,
+		s.GameId,
+		s.GamePath,
+		s.GameName,
+		s.EndedBy,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (p *Db) AddSession(s tracker.Session) (int64, error) {
+	return insertSession(p.db, s)
+}
+
+func (p *Db) UpdateSession(s tracker.Session) error {
+	_, err := p.db.Exec(
+		`update sessions set
+			end_time = ?, duration = ?, system = ?, game_id = ?,
+			game_path = ?, game_name = ?, ended_by = ?
+		where id = ?`,
+		s.End,
+		s.Duration,
+		s.System,
+		s.GameId,
+		s.GamePath,
+		s.GameName,
+		s.EndedBy,
+		s.Id,
+	)
+	return err
+}
+
+func scanSessions(rows *sql.Rows) ([]tracker.Session, error) {
+	defer rows.Close()
+
+	var sessions []tracker.Session
+	for rows.Next() {
+		var s tracker.Session
+		err := rows.Scan(
+			&s.Id,
+			&s.Start,
+			&s.End,
+			&s.Duration,
+			&s.Core,
+			&s.System,
+			&s.GameId,
+			&s.GamePath,
+			&s.GameName,
+			&s.EndedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+const sessionColumns = `id, start_time, end_time, duration, core, system,
+	game_id, game_path, game_name, ended_by`
+
+// SessionsBetween returns all sessions started between two times, oldest
+// first. A zero end time has no limit.
+func (p *Db) SessionsBetween(from time.Time, to time.Time) ([]tracker.Session, error) {
+	if to.IsZero() {
+		to = time.Now().AddDate(100, 0, 0)
+	}
+
+	// timestamps are stored as text in local time, so must be compared in
+	// the same time zone
+	from = from.Local()
+	to = to.Local()
+
+	rows, err := p.db.Query(
+		"select "+sessionColumns+" from sessions where start_time >= ? and start_time < ? order by start_time",
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// RecentSessions returns the last n sessions, newest first.
+func (p *Db) RecentSessions(n int) ([]tracker.Session, error) {
+	rows, err := p.db.Query(
+		"select "+sessionColumns+" from sessions order by start_time desc limit ?",
+		n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+func (p *Db) LongestSessions(n int) ([]tracker.Session, error) {
+	rows, err := p.db.Query(
+		"select "+sessionColumns+" from sessions order by duration desc limit ?",
+		n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// SystemTime is the total play time of all games in a system.
+type SystemTime struct {
+	System string
+	Time   int
+}
+
+// TopSystems returns the n systems with the most game play time. A negative
+// n returns every system.
+func (p *Db) TopSystems(n int) ([]SystemTime, error) {
+	// game IDs start with the system ID and a slash, except games with an
+	// unknown system which are their full path starting with a slash, so
+	// they're counted under an empty system
+	rows, err := p.db.Query(
+		`select substr(id, 1, instr(id, '/') - 1) as system, sum(time) as total
+		from game_times group by system order by total desc limit ?`,
+		n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var systems []SystemTime
+	for rows.Next() {
+		var system SystemTime
+		err = rows.Scan(&system.System, &system.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		systems = append(systems, system)
+	}
+
+	return systems, nil
+}
+
+// TopCores returns the n cores with the most play time. A negative n returns
+// every core.
+func (p *Db) TopCores(n int) ([]tracker.CoreTime, error) {
+	rows, err := p.db.Query("select name, time from core_times order by time desc limit ?", n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cores []tracker.CoreTime
+	for rows.Next() {
+		var core tracker.CoreTime
+		err = rows.Scan(&core.Name, &core.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		cores = append(cores, core)
+	}
+
+	return cores, nil
+}
+
+// TopGames returns the n games with the most play time. A negative n returns
+// every game.
+func (p *Db) TopGames(n int) ([]tracker.GameTime, error) {
+	rows, err := p.db.Query("select id, path, name, folder, time from game_times order by time desc limit ?", n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []tracker.GameTime
+	for rows.Next() {
+		var game tracker.GameTime
+		err = rows.Scan(&game.Id, &game.Path, &game.Name, &game.Folder, &game.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		games = append(games, game)
+	}
+
+	return games, nil
+}
+
+func (p *Db) FixPowerLoss() (bool, error) {
+	// FIXME: repeating a lot of code here?
+	var lastEvent tracker.EventAction
+	fixed := false
+
+	// cores
+	err := p.db.QueryRow(
+		"select timestamp, action, target, total_time from events where action = ? or action = ? order by timestamp desc",
+		tracker.EventActionCoreStart,
+		tracker.EventActionCoreStop,
+	).Scan(
+		&lastEvent.Timestamp,
+		&lastEvent.Action,
+		&lastEvent.Target,
+		&lastEvent.TotalTime,
+	)
+	if p.NoResults(err) {
+		// skip
+	} else if err != nil {
+		return fixed, err
+	} else if lastEvent.Action == tracker.EventActionCoreStart {
+		newEvent := tracker.EventAction{
+			Timestamp: lastEvent.Timestamp.Add(time.Second),
+			Action:    tracker.EventActionCoreStop,
+			Target:    lastEvent.Target,
+			TotalTime: lastEvent.TotalTime,
+		}
+
+		ct, err := p.GetCore(lastEvent.Target)
+		if p.NoResults(err) {
+			err := p.AddEvent(newEvent)
+			if err != nil {
+				return fixed, err
+			}
+			fixed = true
+		} else if err != nil {
+			return fixed, err
+		} else {
+			offset := ct.Time - lastEvent.TotalTime
+			if offset > 0 {
+				newEvent.TotalTime = ct.Time
+				newEvent.Timestamp = lastEvent.Timestamp.Add(time.Second * time.Duration(offset))
+			}
+			err := p.AddEvent(newEvent)
+			if err != nil {
+				return fixed, err
+			}
+			fixed = true
+		}
+	}
+
+	// games
+	err = p.db.QueryRow(
+		"select timestamp, action, target, total_time from events where action = ? or action = ? order by timestamp desc",
+		tracker.EventActionGameStart,
+		tracker.EventActionGameStop,
+	).Scan(
+		&lastEvent.Timestamp,
+		&lastEvent.Action,
+		&lastEvent.Target,
+		&lastEvent.TotalTime,
+	)
+	if p.NoResults(err) {
+		// skip
+	} else if err != nil {
+		return fixed, err
+	} else if lastEvent.Action == tracker.EventActionGameStart {
+		newEvent := tracker.EventAction{
+			Timestamp: lastEvent.Timestamp.Add(time.Second),
+			Action:    tracker.EventActionGameStop,
+			Target:    lastEvent.Target,
+			TotalTime: lastEvent.TotalTime,
+		}
+
+		gt, err := p.GetGame(lastEvent.Target)
+		if p.NoResults(err) {
+			err := p.AddEvent(newEvent)
+			if err != nil {
+				return fixed, err
+			}
+			fixed = true
+		} else if err != nil {
+			return fixed, err
+		} else {
+			offset := gt.Time - lastEvent.TotalTime
+			if offset > 0 {
+				newEvent.TotalTime = gt.Time
+				newEvent.Timestamp = lastEvent.Timestamp.Add(time.Second * time.Duration(offset))
+			}
+			err := p.AddEvent(newEvent)
+			if err != nil {
+				return fixed, err
+			}
+			fixed = true
+		}
+	}
+
+	// sessions
+	result, err := p.db.Exec(
+		"update sessions set ended_by = ? where ended_by = ?",
+		tracker.SessionEndedByPowerLoss,
+		tracker.SessionActive,
+	)
+	if err != nil {
+		return fixed, err
+	}
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		fixed = true
+	}
+
+	return fixed, nil
+}
@@ -0,0 +1,264 @@
+package playlog
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/tracker"
+)
+
+func TestMigrateSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "playlog.db")
+
+	// database from before sessions were added
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := &Db{db: db}
+	_, err = db.Exec(`create table events (
+		timestamp timestamp not null,
+		action integer not null,
+		target text not null,
+		total_time integer not null
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.Local)
+	events := []struct {
+		minutes int
+		action  int
+		target  string
+	}{
+		{0, tracker.EventActionCoreStart, "SNES"},
+		{1, tracker.EventActionGameStart, "SNES/Super Metroid.sfc"},
+		{31, tracker.EventActionGameStart, "SNES/Zelda.sfc"},
+		{91, tracker.EventActionCoreStop, "SNES"},
+		{91, tracker.EventActionGameStop, "SNES/Zelda.sfc"},
+		{100, tracker.EventActionCoreStart, "Minimig"},
+		{110, tracker.EventActionCoreStop, "Minimig"},
+		{120, tracker.EventActionCoreStart, "NES"},
+	}
+	for _, ev := range events {
+		err = old.AddEvent(tracker.EventAction{
+			Timestamp: start.Add(time.Duration(ev.minutes) * time.Minute),
+			Action:    ev.action,
+			Target:    ev.target,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	pl, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pl.Close()
+
+	sessions, err := pl.SessionsBetween(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		core     string
+		gameId   string
+		duration int
+		endedBy  int
+	}{
+		{"SNES", "SNES/super metroid", 30 * 60, tracker.SessionEndedByGameSwitch},
+		{"SNES", "SNES/zelda", 60 * 60, tracker.SessionEndedByStop},
+		{"Minimig", "", 10 * 60, tracker.SessionEndedByStop},
+		{"NES", "", 0, tracker.SessionEndedByPowerLoss},
+	}
+
+	if len(sessions) != len(want) {
+		t.Fatalf("got %d sessions, want %d", len(sessions), len(want))
+	}
+
+	for i, w := range want {
+		s := sessions[i]
+		if s.Core != w.core || s.GameId != w.gameId || s.Duration != w.duration || s.EndedBy != w.endedBy {
+			t.Errorf("session %d = %+v, want %+v", i, s, w)
+		}
+	}
+}
+
+func TestMigrateGameIds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "playlog.db")
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`create table game_times (
+		id text not null unique,
+		path text not null,
+		name text not null,
+		folder text not null,
+		time integer not null
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := &Db{db: db}
+	for _, gt := range []tracker.GameTime{
+		{Id: "SNES/Zelda (USA).sfc", Path: "/media/fat/games/SNES/Zelda (USA).sfc", Time: 100},
+		{Id: "SNES/ZELDA (USA).zip", Path: "/media/usb0/games/SNES/ZELDA (USA).zip", Time: 50},
+		{Id: "NES/Zelda (USA).nes", Path: "/media/fat/games/NES/Zelda (USA).nes", Time: 10},
+	} {
+		err = old.UpdateGame(gt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	pl, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pl.Close()
+
+	games, err := pl.TopGames(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(games) != 2 ||
+		games[0].Id != "SNES/zelda (usa)" || games[0].Time != 150 ||
+		games[1].Id != "NES/zelda (usa)" || games[1].Time != 10 {
+		t.Errorf("games = %+v, want SNES games merged", games)
+	}
+}
+
+func TestLockRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "playlog.db")
+
+	first, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	if locked, err := first.LockRecording(); err != nil || !locked {
+		t.Fatalf("first LockRecording() = %v, %v, want true", locked, err)
+	}
+	if locked, err := second.LockRecording(); err != nil || locked {
+		t.Fatalf("second LockRecording() = %v, %v, want false", locked, err)
+	}
+
+	err = first.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked, err := second.LockRecording(); err != nil || !locked {
+		t.Errorf("LockRecording() after close = %v, %v, want true", locked, err)
+	}
+}
+
+func TestMigrateArcadeIds(t *testing.T) {
+	old := readArcadeNames
+	readArcadeNames = func() (map[string]bool, error) {
+		return map[string]bool{"sf2": true}, nil
+	}
+	t.Cleanup(func() {
+		readArcadeNames = old
+	})
+
+	path := filepath.Join(t.TempDir(), "playlog.db")
+
+	// database from before sessions were added, when arcade games were
+	// stored under their core name
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{
+		`create table events (
+			timestamp timestamp not null,
+			action integer not null,
+			target text not null,
+			total_time integer not null
+		)`,
+		`create table game_times (
+			id text not null unique,
+			path text not null,
+			name text not null,
+			folder text not null,
+			time integer not null
+		)`,
+	} {
+		_, err = db.Exec(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pre := &Db{db: db}
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.Local)
+	for i, ev := range []struct {
+		action int
+		target string
+	}{
+		{tracker.EventActionCoreStart, "sf2"},
+		{tracker.EventActionGameStart, "sf2"},
+		{tracker.EventActionGameStop, "sf2"},
+		{tracker.EventActionCoreStop, "sf2"},
+	} {
+		err = pre.AddEvent(tracker.EventAction{
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Action:    ev.action,
+			Target:    ev.target,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = pre.UpdateGame(tracker.GameTime{Id: "sf2", Name: "Street Fighter II", Time: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	pl, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pl.Close()
+
+	games, err := pl.TopGames(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 || games[0].Id != "Arcade/sf2" || games[0].Time != 60 {
+		t.Errorf("games = %+v, want Arcade/sf2", games)
+	}
+
+	sessions, err := pl.SessionsBetween(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].GameId != "Arcade/sf2" || sessions[0].System != tracker.ArcadeSystem {
+		t.Errorf("sessions = %+v, want Arcade/sf2 in the Arcade system", sessions)
+	}
+
+	var version int
+	err = pl.db.QueryRow("pragma user_version").Scan(&version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Errorf("user_version = %d, want 2", version)
+	}
+}
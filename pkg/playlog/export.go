@@ -0,0 +1,722 @@
+package playlog
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/tracker"
+)
+
+const exportVersion = 2
+
+type ExportGame struct {
+	Id     string `json:"id"`
+	Path   string `json:"path"`
+	Name   string `json:"name"`
+	Folder string `json:"folder"`
+	Time   int    `json:"time"`
+}
+
+type ExportCore struct {
+	Name string `json:"name"`
+	Time int    `json:"time"`
+}
+
+type ExportArcadeSet struct {
+	Id      string `json:"id"`
+	SetName string `json:"setName"`
+	Parent  string `json:"parent"`
+	Name    string `json:"name"`
+}
+
+type ExportSession struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration int       `json:"duration"`
+	Core     string    `json:"core"`
+	System   string    `json:"system"`
+	GameId   string    `json:"gameId"`
+	GamePath string    `json:"gamePath"`
+	GameName string    `json:"gameName"`
+	EndedBy  int       `json:"endedBy"`
+}
+
+type ExportEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Action    int       `json:"action"`
+	Target    string    `json:"target"`
+	TotalTime int       `json:"totalTime"`
+}
+
+// Export is the full contents of a PlayLog database, in a form which can be
+// imported into another.
+type Export struct {
+	Version    int               `json:"version"`
+	Source     string            `json:"source"` // ID of the exported database
+	Games      []ExportGame      `json:"games"`
+	Cores      []ExportCore      `json:"cores"`
+	ArcadeSets []ExportArcadeSet `json:"arcadeSets"`
+	Sessions   []ExportSession   `json:"sessions"`
+	Events     []ExportEvent     `json:"events"`
+}
+
+// ImportResult counts the rows added or merged by an import. Existing games
+// and cores are only counted if play time was added to them.
+type ImportResult struct {
+	Games      int
+	Cores      int
+	ArcadeSets int
+	Sessions   int
+	Events     int
+}
+
+// sourceId returns the random ID of the database, which is included in its
+// exports. It's created the first time the database is exported.
+func (p *Db) sourceId() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = p.db.Exec(
+		"insert or ignore into info (key, value) values ('source', ?)",
+		hex.EncodeToString(b),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+	err = p.db.QueryRow("select value from info where key = 'source'").Scan(&id)
+	return id, err
+}
+
+// Export reads every game, core, arcade set, session and event in the
+// database.
+func (p *Db) Export() (Export, error) {
+	source, err := p.sourceId()
+	if err != nil {
+		return Export{}, fmt.Errorf("error reading database id: %s", err)
+	}
+
+	e := Export{
+		Version:    exportVersion,
+		Source:     source,
+		Games:      make([]ExportGame, 0),
+		Cores:      make([]ExportCore, 0),
+		ArcadeSets: make([]ExportArcadeSet, 0),
+		Sessions:   make([]ExportSession, 0),
+		Events:     make([]ExportEvent, 0),
+	}
+
+	gts, err := p.TopGames(-1)
+	if err != nil {
+		return e, fmt.Errorf("error reading games: %s", err)
+	}
+	for _, gt := range gts {
+		e.Games = append(e.Games, ExportGame(gt))
+	}
+
+	cts, err := p.TopCores(-1)
+	if err != nil {
+		return e, fmt.Errorf("error reading cores: %s", err)
+	}
+	for _, ct := range cts {
+		e.Cores = append(e.Cores, ExportCore(ct))
+	}
+
+	sets, err := p.ArcadeSets()
+	if err != nil {
+		return e, fmt.Errorf("error reading arcade sets: %s", err)
+	}
+	for _, as := range sets {
+		e.ArcadeSets = append(e.ArcadeSets, ExportArcadeSet(as))
+	}
+
+	sessions, err := p.SessionsBetween(time.Time{}, time.Time{})
+	if err != nil {
+		return e, fmt.Errorf("error reading sessions: %s", err)
+	}
+	for _, s := range sessions {
+		e.Sessions = append(e.Sessions, ExportSession{
+			Start:    s.Start,
+			End:      s.End,
+			Duration: s.Duration,
+			Core:     s.Core,
+			System:   s.System,
+			GameId:   s.GameId,
+			GamePath: s.GamePath,
+			GameName: s.GameName,
+			EndedBy:  s.EndedBy,
+		})
+	}
+
+	rows, err := p.db.Query("select timestamp, action, target, total_time from events order by timestamp")
+	if err != nil {
+		return e, fmt.Errorf("error reading events: %s", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ev ExportEvent
+		err = rows.Scan(&ev.Timestamp, &ev.Action, &ev.Target, &ev.TotalTime)
+		if err != nil {
+			return e, fmt.Errorf("error reading events: %s", err)
+		}
+		e.Events = append(e.Events, ev)
+	}
+
+	return e, rows.Err()
+}
+
+// Import merges an export into the database. Play times of games and cores
+// are added to any existing times, and arcade sets replace existing ones.
+// Sessions and events which already exist are skipped. Games stored under
+// IDs from older versions of PlayLog are moved to their current IDs.
+//
+// The play times imported from each database are remembered, so importing
+// the same export again, or a newer export of the same database, only adds
+// time played since the last import. Exports without a source database are
+// only recognised if they're exactly the same.
+func (p *Db) Import(e Export) (ImportResult, error) {
+	var result ImportResult
+
+	if e.Version > exportVersion {
+		return result, fmt.Errorf("unsupported export version: %d", e.Version)
+	}
+
+	source := e.Source
+	if source == "" {
+		source = exportHash(e)
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	// an export of this database already has all its play times
+	var own string
+	err = tx.QueryRow("select value from info where key = 'source'").Scan(&own)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return result, err
+	}
+	self := own != "" && own == source
+
+	renames := importRenames(e)
+	rename := func(id string) string {
+		if newId, ok := renames[id]; ok {
+			return newId
+		}
+		return id
+	}
+
+	for _, g := range e.Games {
+		added := 0
+		if !self {
+			added, err = importTime(tx, source, "game", g.Id, g.Time)
+			if err != nil {
+				return result, fmt.Errorf("error importing game %s: %s", g.Id, err)
+			}
+		}
+
+		res, err := tx.Exec(
+			`insert into game_times (id, path, name, folder, time) values (?, ?, ?, ?, ?)
+			on conflict (id) do update set time = time + excluded.time where excluded.time > 0`,
+			rename(g.Id),
+			g.Path,
+			g.Name,
+			g.Folder,
+			added,
+		)
+		if err != nil {
+			return result, fmt.Errorf("error importing game %s: %s", g.Id, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			result.Games++
+		}
+	}
+
+	for _, c := range e.Cores {
+		added := 0
+		if !self {
+			added, err = importTime(tx, source, "core", c.Name, c.Time)
+			if err != nil {
+				return result, fmt.Errorf("error importing core %s: %s", c.Name, err)
+			}
+		}
+
+		res, err := tx.Exec(
+			`insert into core_times (name, time) values (?, ?)
+			on conflict (name) do update set time = time + excluded.time where excluded.time > 0`,
+			c.Name,
+			added,
+		)
+		if err != nil {
+			return result, fmt.Errorf("error importing core %s: %s", c.Name, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			result.Cores++
+		}
+	}
+
+	for _, as := range e.ArcadeSets {
+		_, err = tx.Exec(
+			"insert or replace into arcade_sets (id, setname, parent, name) values (?, ?, ?, ?)",
+			as.Id,
+			as.SetName,
+			as.Parent,
+			as.Name,
+		)
+		if err != nil {
+			return result, fmt.Errorf("error importing arcade set %s: %s", as.Id, err)
+		}
+		result.ArcadeSets++
+	}
+
+	for _, s := range e.Sessions {
+		gameId := rename(s.GameId)
+		system := s.System
+		if system == "" && strings.HasPrefix(gameId, tracker.ArcadeSystem+"/") {
+			// sessions built from old events have no system
+			system = tracker.ArcadeSystem
+		}
+
+		// timestamps are stored as text in local time
+		res, err := tx.Exec(
+			`insert or ignore into sessions (
+				start_time, end_time, duration, core, system,
+				game_id, game_path, game_name, ended_by
+			) values (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			s.Start.Local(),
+			s.End.Local(),
+			s.Duration,
+			s.Core,
+			system,
+			gameId,
+			s.GamePath,
+			s.GameName,
+			s.EndedBy,
+		)
+		if err != nil {
+			return result, fmt.Errorf("error importing session: %s", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			result.Sessions++
+		}
+	}
+
+	for _, ev := range e.Events {
+		target := ev.Target
+		if ev.Action == tracker.EventActionGameStart || ev.Action == tracker.EventActionGameStop {
+			target = rename(target)
+		}
+
+		res, err := tx.Exec(
+			"insert or ignore into events (timestamp, action, target, total_time) values (?, ?, ?, ?)",
+			ev.Timestamp.Local(),
+			ev.Action,
+			target,
+			ev.TotalTime,
+		)
+		if err != nil {
+			return result, fmt.Errorf("error importing event: %s", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			result.Events++
+		}
+	}
+
+	return result, tx.Commit()
+}
+
+// importTime records the total play time of a game or core in an imported
+// database, and returns how much of it hasn't been imported before.
+func importTime(tx *sql.Tx, source string, kind string, id string, total int) (int, error) {
+	var imported int
+	err := tx.QueryRow(
+		"select time from imported_times where source = ? and type = ? and id = ?",
+		source,
+		kind,
+		id,
+	).Scan(&imported)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		"insert or replace into imported_times (source, type, id, time) values (?, ?, ?, ?)",
+		source,
+		kind,
+		id,
+		total,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if total < imported {
+		// the other database's play times were reset
+		return 0, nil
+	}
+	return total - imported, nil
+}
+
+// exportHash identifies an export which has no source database by its play
+// times.
+func exportHash(e Export) string {
+	h := sha1.New()
+	for _, g := range e.Games {
+		fmt.Fprintf(h, "game\x00%s\x00%d\n", g.Id, g.Time)
+	}
+	for _, c := range e.Cores {
+		fmt.Fprintf(h, "core\x00%s\x00%d\n", c.Name, c.Time)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// importRenames returns the current IDs of games in an export which are
+// stored under the IDs of older versions of PlayLog, keyed by the old ID.
+// Games used to be the system ID and filename, and arcade games the name of
+// their core.
+func importRenames(e Export) map[string]string {
+	paths := make(map[string]string)
+	addPath := func(id string, path string) {
+		_, filename, ok := strings.Cut(id, "/")
+		if ok && path != "" && filename == filepath.Base(path) {
+			paths[id] = path
+		}
+	}
+	for _, g := range e.Games {
+		addPath(g.Id, g.Path)
+	}
+	for _, s := range e.Sessions {
+		if _, ok := paths[s.GameId]; !ok {
+			addPath(s.GameId, s.GamePath)
+		}
+	}
+
+	renames := gameIdRenames(paths)
+
+	known, _ := readArcadeNames()
+	for _, g := range e.Games {
+		if isLegacyArcadeId(g.Id, "", known) {
+			renames[g.Id] = tracker.ArcadeGameId(g.Id)
+		}
+	}
+	for _, s := range e.Sessions {
+		if isLegacyArcadeId(s.GameId, s.System, known) {
+			renames[s.GameId] = tracker.ArcadeGameId(s.GameId)
+		}
+	}
+
+	return renames
+}
+
+func WriteJson(path string, e Export) error {
+	data, err := json.MarshalIndent(e, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func ReadJson(path string) (Export, error) {
+	var e Export
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return e, err
+	}
+
+	err = json.Unmarshal(data, &e)
+	if err != nil {
+		return e, fmt.Errorf("error parsing %s: %s", path, err)
+	}
+
+	return e, nil
+}
+
+// CSV exports are a folder with a file for each table. Missing files are
+// skipped on import.
+var (
+	infoCsvHeader       = []string{"version", "source"}
+	gamesCsvHeader      = []string{"id", "path", "name", "folder", "time"}
+	coresCsvHeader      = []string{"name", "time"}
+	arcadeSetsCsvHeader = []string{"id", "setname", "parent", "name"}
+	sessionsCsvHeader   = []string{
+		"start", "end", "duration", "core", "system",
+		"game_id", "game_path", "game_name", "ended_by",
+	}
+	eventsCsvHeader = []string{"timestamp", "action", "target", "total_time"}
+)
+
+func writeCsv(path string, header []string, records [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+
+	err = w.Write(header)
+	if err != nil {
+		return err
+	}
+
+	err = w.WriteAll(records)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func WriteCsv(dir string, e Export) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	info := [][]string{{strconv.Itoa(e.Version), e.Source}}
+	err = writeCsv(filepath.Join(dir, "info.csv"), infoCsvHeader, info)
+	if err != nil {
+		return err
+	}
+
+	var games [][]string
+	for _, g := range e.Games {
+		games = append(games, []string{g.Id, g.Path, g.Name, g.Folder, strconv.Itoa(g.Time)})
+	}
+	err = writeCsv(filepath.Join(dir, "games.csv"), gamesCsvHeader, games)
+	if err != nil {
+		return err
+	}
+
+	var cores [][]string
+	for _, c := range e.Cores {
+		cores = append(cores, []string{c.Name, strconv.Itoa(c.Time)})
+	}
+	err = writeCsv(filepath.Join(dir, "cores.csv"), coresCsvHeader, cores)
+	if err != nil {
+		return err
+	}
+
+	var sets [][]string
+	for _, as := range e.ArcadeSets {
+		sets = append(sets, []string{as.Id, as.SetName, as.Parent, as.Name})
+	}
+	err = writeCsv(filepath.Join(dir, "arcade_sets.csv"), arcadeSetsCsvHeader, sets)
+	if err != nil {
+		return err
+	}
+
+	var sessions [][]string
+	for _, s := range e.Sessions {
+		sessions = append(sessions, []string{
+			s.Start.Format(time.RFC3339Nano),
+			s.End.Format(time.RFC3339Nano),
+			strconv.Itoa(s.Duration),
+			s.Core,
+			s.System,
+			s.GameId,
+			s.GamePath,
+			s.GameName,
+			strconv.Itoa(s.EndedBy),
+		})
+	}
+	err = writeCsv(filepath.Join(dir, "sessions.csv"), sessionsCsvHeader, sessions)
+	if err != nil {
+		return err
+	}
+
+	var events [][]string
+	for _, ev := range e.Events {
+		events = append(events, []string{
+			ev.Timestamp.Format(time.RFC3339Nano),
+			strconv.Itoa(ev.Action),
+			ev.Target,
+			strconv.Itoa(ev.TotalTime),
+		})
+	}
+	return writeCsv(filepath.Join(dir, "events.csv"), eventsCsvHeader, events)
+}
+
+// readCsv reads every record of a CSV file after checking its header. A
+// missing file has no records.
+func readCsv(path string, header []string) ([][]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(header)
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", path, err)
+	} else if len(records) == 0 {
+		return nil, nil
+	}
+
+	for i, col := range header {
+		if records[0][i] != col {
+			return nil, fmt.Errorf("error parsing %s: expected column %q, got %q", path, col, records[0][i])
+		}
+	}
+
+	return records[1:], nil
+}
+
+// csvParser parses fields of a CSV record and keeps the first error.
+type csvParser struct {
+	path string
+	line int
+	err  error
+}
+
+func (c *csvParser) int(s string) int {
+	v, err := strconv.Atoi(s)
+	if err != nil && c.err == nil {
+		c.err = fmt.Errorf("error parsing %s line %d: %s", c.path, c.line, err)
+	}
+	return v
+}
+
+func (c *csvParser) time(s string) time.Time {
+	v, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil && c.err == nil {
+		c.err = fmt.Errorf("error parsing %s line %d: %s", c.path, c.line, err)
+	}
+	return v
+}
+
+func ReadCsv(dir string) (Export, error) {
+	// exports without an info file are from before it was added
+	e := Export{Version: 1}
+
+	path := filepath.Join(dir, "info.csv")
+	records, err := readCsv(path, infoCsvHeader)
+	if err != nil {
+		return e, err
+	}
+	for i, r := range records {
+		p := csvParser{path: path, line: i + 2}
+		e.Version = p.int(r[0])
+		e.Source = r[1]
+		if p.err != nil {
+			return e, p.err
+		}
+	}
+
+	path = filepath.Join(dir, "games.csv")
+	records, err = readCsv(path, gamesCsvHeader)
+	if err != nil {
+		return e, err
+	}
+	for i, r := range records {
+		p := csvParser{path: path, line: i + 2}
+		e.Games = append(e.Games, ExportGame{
+			Id:     r[0],
+			Path:   r[1],
+			Name:   r[2],
+			Folder: r[3],
+			Time:   p.int(r[4]),
+		})
+		if p.err != nil {
+			return e, p.err
+		}
+	}
+
+	path = filepath.Join(dir, "cores.csv")
+	records, err = readCsv(path, coresCsvHeader)
+	if err != nil {
+		return e, err
+	}
+	for i, r := range records {
+		p := csvParser{path: path, line: i + 2}
+		e.Cores = append(e.Cores, ExportCore{
+			Name: r[0],
+			Time: p.int(r[1]),
+		})
+		if p.err != nil {
+			return e, p.err
+		}
+	}
+
+	path = filepath.Join(dir, "arcade_sets.csv")
+	records, err = readCsv(path, arcadeSetsCsvHeader)
+	if err != nil {
+		return e, err
+	}
+	for _, r := range records {
+		e.ArcadeSets = append(e.ArcadeSets, ExportArcadeSet{
+			Id:      r[0],
+			SetName: r[1],
+			Parent:  r[2],
+			Name:    r[3],
+		})
+	}
+
+	path = filepath.Join(dir, "sessions.csv")
+	records, err = readCsv(path, sessionsCsvHeader)
+	if err != nil {
+		return e, err
+	}
+	for i, r := range records {
+		p := csvParser{path: path, line: i + 2}
+		e.Sessions = append(e.Sessions, ExportSession{
+			Start:    p.time(r[0]),
+			End:      p.time(r[1]),
+			Duration: p.int(r[2]),
+			Core:     r[3],
+			System:   r[4],
+			GameId:   r[5],
+			GamePath: r[6],
+			GameName: r[7],
+			EndedBy:  p.int(r[8]),
+		})
+		if p.err != nil {
+			return e, p.err
+		}
+	}
+
+	path = filepath.Join(dir, "events.csv")
+	records, err = readCsv(path, eventsCsvHeader)
+	if err != nil {
+		return e, err
+	}
+	for i, r := range records {
+		p := csvParser{path: path, line: i + 2}
+		e.Events = append(e.Events, ExportEvent{
+			Timestamp: p.time(r[0]),
+			Action:    p.int(r[1]),
+			Target:    r[2],
+			TotalTime: p.int(r[3]),
+		})
+		if p.err != nil {
+			return e, p.err
+		}
+	}
+
+	return e, nil
+}
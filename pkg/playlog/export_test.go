@@ -0,0 +1,127 @@
+package playlog
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/tracker"
+)
+
+func TestExportImport(t *testing.T) {
+	dir := t.TempDir()
+
+	src, err := Open(filepath.Join(dir, "src.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	ts := time.Date(2023, 1, 1, 12, 0, 0, 0, time.Local)
+	_ = src.UpdateGame(tracker.GameTime{Id: "SNES/Zelda.sfc", Path: "/games/SNES/Zelda.sfc", Name: "Zelda", Folder: "SNES", Time: 600})
+	_ = src.UpdateCore(tracker.CoreTime{Name: "SNES", Time: 700})
+	_ = src.UpdateArcadeSet(tracker.ArcadeSet{Id: "Arcade/sf2ua", SetName: "sf2ua", Parent: "sf2", Name: "Street Fighter II (USA)"})
+	_, _ = src.AddSession(tracker.Session{Start: ts, End: ts.Add(10 * time.Minute), Duration: 600, Core: "SNES", GameId: "SNES/Zelda.sfc"})
+	_ = src.AddEvent(tracker.EventAction{Timestamp: ts, Action: tracker.EventActionGameStart, Target: "SNES/Zelda.sfc"})
+
+	e, err := src.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csvDir := filepath.Join(dir, "csv")
+	err = WriteCsv(csvDir, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromCsv, err := ReadCsv(csvDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// times are compared as JSON since their locations differ
+	got, _ := json.Marshal(fromCsv)
+	want, _ := json.Marshal(e)
+	if string(got) != string(want) {
+		t.Errorf("ReadCsv() = %s, want %s", got, want)
+	}
+
+	dst, err := Open(filepath.Join(dir, "dst.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	// the same export twice only counts once
+	for i := 0; i < 2; i++ {
+		_, err = dst.Import(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	merged, err := dst.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the old game ID is moved to its current one
+	if len(merged.Games) != 1 || merged.Games[0].Id != "SNES/zelda" || merged.Games[0].Time != 600 {
+		t.Errorf("games = %+v, want SNES/zelda imported once", merged.Games)
+	}
+	if len(merged.Cores) != 1 || merged.Cores[0].Time != 700 {
+		t.Errorf("cores = %+v, want imported once", merged.Cores)
+	}
+	if len(merged.ArcadeSets) != 1 || merged.ArcadeSets[0] != e.ArcadeSets[0] {
+		t.Errorf("arcade sets = %+v, want %+v", merged.ArcadeSets, e.ArcadeSets)
+	}
+	if len(merged.Sessions) != 1 || len(merged.Events) != 1 ||
+		merged.Sessions[0].GameId != "SNES/zelda" || merged.Events[0].Target != "SNES/zelda" {
+		t.Errorf("sessions = %+v, events = %+v, want duplicates skipped and IDs moved", merged.Sessions, merged.Events)
+	}
+
+	// a newer export of the same database only adds the new play time
+	_ = src.UpdateGame(tracker.GameTime{Id: "SNES/Zelda.sfc", Path: "/games/SNES/Zelda.sfc", Name: "Zelda", Folder: "SNES", Time: 900})
+	e, err = src.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dst.Import(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an export without a source is only recognised if it's the same
+	e.Source = ""
+	for i := 0; i < 2; i++ {
+		_, err = dst.Import(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	games, err := dst.TopGames(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 || games[0].Time != 1800 {
+		t.Errorf("games = %+v, want 900 from each source", games)
+	}
+
+	// importing a database's own export changes nothing
+	own, err := dst.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dst.Import(own)
+	if err != nil {
+		t.Fatal(err)
+	}
+	games, err = dst.TopGames(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 || games[0].Time != 1800 {
+		t.Errorf("games = %+v, want own export skipped", games)
+	}
+}
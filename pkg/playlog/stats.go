@@ -0,0 +1,129 @@
+package playlog
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/tracker"
+)
+
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// StartOfWeek returns the start of the monday before a time.
+func StartOfWeek(t time.Time) time.Time {
+	day := StartOfDay(t)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func StartOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// SessionName returns the game name of a session, or its core if no game
+// was played.
+func SessionName(s tracker.Session) string {
+	if s.GameName != "" {
+		return s.GameName
+	} else if s.GameId != "" {
+		return s.GameId
+	} else {
+		return s.Core
+	}
+}
+
+// Period is a group of sessions which started in the same day, week or
+// month.
+type Period struct {
+	Start    time.Time
+	Sessions []tracker.Session
+	Total    int
+}
+
+// GroupSessions splits sessions, which must be sorted oldest first, into
+// periods using the given function to find the start of each session's
+// period, in local time. Periods with no sessions are not included.
+func GroupSessions(sessions []tracker.Session, periodStart func(time.Time) time.Time) []Period {
+	var periods []Period
+
+	for _, s := range sessions {
+		start := periodStart(s.Start.Local())
+		if len(periods) == 0 || !periods[len(periods)-1].Start.Equal(start) {
+			periods = append(periods, Period{Start: start})
+		}
+
+		p := &periods[len(periods)-1]
+		p.Sessions = append(p.Sessions, s)
+		p.Total += s.Duration
+	}
+
+	return periods
+}
+
+// Total is the combined play time of every session of a game, or of a core
+// when no game was played.
+type Total struct {
+	Core     string
+	System   string
+	GameId   string
+	GamePath string
+	GameName string
+	Time     int
+	Sessions int
+}
+
+// Name returns the game name of a total, or its core if it's not a game.
+func (t Total) Name() string {
+	return SessionName(tracker.Session{
+		Core:     t.Core,
+		GameId:   t.GameId,
+		GameName: t.GameName,
+	})
+}
+
+// MostPlayed returns the n games or cores with the most play time in a list
+// of sessions. A negative n returns all of them.
+func MostPlayed(sessions []tracker.Session, n int) []Total {
+	totals := make(map[string]*Total)
+	var keys []string
+
+	for _, s := range sessions {
+		key := "core:" + s.Core
+		if s.GameId != "" {
+			key = "game:" + s.GameId
+		}
+
+		t, ok := totals[key]
+		if !ok {
+			t = &Total{
+				Core:     s.Core,
+				System:   s.System,
+				GameId:   s.GameId,
+				GamePath: s.GamePath,
+				GameName: s.GameName,
+			}
+			totals[key] = t
+			keys = append(keys, key)
+		}
+
+		t.Time += s.Duration
+		t.Sessions++
+	}
+
+	var result []Total
+	for _, key := range keys {
+		result = append(result, *totals[key])
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time > result[j].Time
+	})
+
+	if n >= 0 && len(result) > n {
+		result = result[:n]
+	}
+
+	return result
+}
@@ -12,6 +12,11 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+func Exists() bool {
+	_, err := os.Stat(config.SearchSqliteFile)
+	return err == nil
+}
+
 func setupDb(db *sql.DB) error {
 	sqlStmt := `create table if not exists games (
 		path text not null,
@@ -30,7 +35,7 @@ func setupDb(db *sql.DB) error {
 }
 
 func getDb() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", config.SearchDbFile)
+	db, err := sql.Open("sqlite3", config.SearchSqliteFile)
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +44,7 @@ func getDb() (*sql.DB, error) {
 }
 
 func Generate(files [][2]string, statusFn func(count int)) error {
-	tmpDbPath := filepath.Join(os.TempDir(), filepath.Base(config.SearchDbFile))
+	tmpDbPath := filepath.Join(os.TempDir(), filepath.Base(config.SearchSqliteFile))
 	if err := os.Remove(tmpDbPath); err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -77,11 +82,11 @@ func Generate(files [][2]string, statusFn func(count int)) error {
 	insertStmt.Close()
 	db.Close()
 
-	if err := os.Remove(config.SearchDbFile); err != nil && !os.IsNotExist(err) {
+	if err := os.Remove(config.SearchSqliteFile); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	utils.MoveFile(tmpDbPath, config.SearchDbFile)
+	utils.MoveFile(tmpDbPath, config.SearchSqliteFile)
 
 	return nil
 }
@@ -139,3 +144,58 @@ func SearchGames(name string) ([]Game, error) {
 
 	return games, nil
 }
+
+// AllGames returns every game in the index.
+func AllGames() ([]Game, error) {
+	db, err := getDb()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("select path, system, name from games")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	games := []Game{}
+	for rows.Next() {
+		var game Game
+		err := rows.Scan(&game.Path, &game.System, &game.Name)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, game)
+	}
+
+	return games, rows.Err()
+}
+
+// SystemCounts returns the number of games in the index for each system.
+func SystemCounts() (map[string]int, error) {
+	db, err := getDb()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("select system, count(*) from games group by system")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var system string
+		var count int
+		err := rows.Scan(&system, &count)
+		if err != nil {
+			return nil, err
+		}
+		counts[system] = count
+	}
+
+	return counts, rows.Err()
+}
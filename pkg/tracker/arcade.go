@@ -0,0 +1,161 @@
+package tracker
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/wizzomafizzo/mrext/pkg/games"
+	"github.com/wizzomafizzo/mrext/pkg/metadata"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+// ArcadeSet is the MAME set of an arcade game launched from an MRA file.
+// Clones of a game share the same parent set.
+type ArcadeSet struct {
+	Id      string
+	SetName string
+	Parent  string // empty if the set is a parent
+	Name    string
+}
+
+// ArcadeGameId returns the game ID play times of an arcade set are stored
+// under.
+func ArcadeGameId(setName string) string {
+	return ArcadeSystem + "/" + normaliseGameName(setName)
+}
+
+// mraLaunchesCore reports whether an MRA loads the given core. The core name
+// of an MRA is its setname, or the name of its RBF if it doesn't have one.
+func mraLaunchesCore(mra metadata.MRA, coreName string) bool {
+	if mra.SetName != "" {
+		return strings.EqualFold(mra.SetName, coreName)
+	}
+	return strings.EqualFold(filepath.Base(mra.Rbf), coreName)
+}
+
+// lookupArcadeName returns the name mapping of an ArcadeDB setname, ignoring
+// the active game.
+func (tr *Tracker) lookupArcadeName(setName string) NameMapping {
+	for _, mapping := range tr.NameMap {
+		if mapping.System == ArcadeSystem && strings.EqualFold(mapping.CoreName, setName) {
+			return mapping
+		}
+	}
+
+	return NameMapping{}
+}
+
+// readArcadeSet returns the set an MRA file launches, named with its
+// ArcadeDB title if it has one. MRAs without a setname use their filename.
+func (tr *Tracker) readArcadeSet(path string) (ArcadeSet, metadata.MRA, error) {
+	filename := utils.RemoveFileExt(filepath.Base(path))
+
+	mra, err := metadata.ReadMra(path)
+	if err != nil {
+		return ArcadeSet{}, mra, err
+	}
+
+	setName := mra.SetName
+	if setName == "" {
+		setName = filename
+	}
+
+	set := ArcadeSet{
+		Id:      ArcadeGameId(setName),
+		SetName: setName,
+		Parent:  mra.Parent,
+		Name:    tr.lookupArcadeName(setName).ArcadeName,
+	}
+
+	if set.Name == "" {
+		set.Name = mra.Name
+	}
+	if set.Name == "" {
+		set.Name = filename
+	}
+
+	return set, mra, nil
+}
+
+// launchedArcadeSet returns the set of the active game if it's an MRA which
+// loads the given core. MRAs are usually resolved before their core starts.
+func (tr *Tracker) launchedArcadeSet(coreName string) (ArcadeSet, bool) {
+	if tr.ActiveGame == "" || filepath.Ext(strings.ToLower(tr.ActiveGamePath)) != ".mra" {
+		return ArcadeSet{}, false
+	}
+
+	set, mra, err := tr.readArcadeSet(tr.ActiveGamePath)
+	if err != nil || set.Id != tr.ActiveGame || !mraLaunchesCore(mra, coreName) {
+		return ArcadeSet{}, false
+	}
+
+	return set, true
+}
+
+// setArcadeGame sets an arcade set as the active game. The path is the MRA
+// it was launched from, if known.
+func (tr *Tracker) setArcadeGame(set ArcadeSet, path string) {
+	tr.stopGame()
+
+	tr.ActiveGame = set.Id
+	tr.ActiveGameName = set.Name
+	tr.ActiveGamePath = path
+
+	if _, ok := tr.GameTimes[set.Id]; !ok {
+		var folder string
+		if system, err := games.GetSystem(ArcadeSystem); err == nil && len(system.Folder) > 0 {
+			folder = system.Folder[0]
+		}
+
+		gt, err := tr.Db.GetGame(set.Id)
+		if tr.Db.NoResults(err) {
+			tr.GameTimes[set.Id] = GameTime{
+				Id:     set.Id,
+				Path:   path,
+				Name:   set.Name,
+				Folder: folder,
+				Time:   0,
+			}
+		} else if err != nil {
+			tr.Logger.Error("error loading game time: %s", err)
+		} else {
+			tr.GameTimes[set.Id] = gt
+		}
+	}
+
+	tr.addEvent(EventActionGameStart, set.Id)
+}
+
+// loadArcadeGame sets the set of a launched MRA file as the active game.
+func (tr *Tracker) loadArcadeGame(path string) {
+	set, _, err := tr.readArcadeSet(path)
+	if err != nil {
+		tr.Logger.Error("error reading mra: %s", err)
+		filename := utils.RemoveFileExt(filepath.Base(path))
+		set = ArcadeSet{
+			Id:      ArcadeGameId(filename),
+			SetName: filename,
+			Name:    filename,
+		}
+	} else {
+		err = tr.Db.UpdateArcadeSet(set)
+		if err != nil {
+			tr.Logger.Error("error saving arcade set: %s", err)
+		}
+	}
+
+	if set.Id == tr.ActiveGame {
+		// the core started first and was already matched to this set
+		tr.ActiveGamePath = path
+		if gt, ok := tr.GameTimes[set.Id]; ok && gt.Path == "" {
+			gt.Path = path
+			tr.GameTimes[set.Id] = gt
+		}
+		return
+	}
+
+	tr.ActiveSystem = ArcadeSystem
+	tr.ActiveSystemName = ArcadeSystem
+	tr.setArcadeGame(set, path)
+	tr.startGameSession()
+}
@@ -40,15 +40,21 @@ func loadRecent(tr *Tracker, filename string) error {
 	newest := recents[0]
 
 	if strings.HasSuffix(filename, "cores_recent.cfg") {
-		// main menu's recent file, written when launching mgls
-		if strings.HasSuffix(strings.ToLower(newest.Name), ".mgl") {
+		// main menu's recent file, written when launching mgls and mras
+		if strings.HasSuffix(strings.ToLower(newest.Name), ".mra") {
+			// resolved to its setname when the active game is loaded
+			err = mister.SetActiveGame(filepath.Join(newest.Directory, newest.Name))
+			if err != nil {
+				return fmt.Errorf("error setting active game: %w", err)
+			}
+		} else if strings.HasSuffix(strings.ToLower(newest.Name), ".mgl") {
 			mglPath := mister.ResolvePath(filepath.Join(newest.Directory, newest.Name))
 			mgl, err := mister.ReadMgl(mglPath)
 			if err != nil {
 				return fmt.Errorf("error reading mgl file: %w", err)
 			}
 
-			err = mister.SetActiveGame(mgl.File.Path)
+			err = mister.SetActiveGame(mgl.GameFile())
 			if err != nil {
 				return fmt.Errorf("error setting active game: %w", err)
 			}
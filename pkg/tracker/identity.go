@@ -0,0 +1,127 @@
+package tracker
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wizzomafizzo/mrext/pkg/gamesdb"
+	"github.com/wizzomafizzo/mrext/pkg/utils"
+)
+
+// GameIdentity is the ID play times of a game are stored under. The same
+// game launched from a zip, an MGL or a different folder should always end
+// up with the same ID.
+type GameIdentity struct {
+	// SHA1 of the game file if it's been hashed in the gamesdb, otherwise
+	// the same as NameId. Always prefixed with the system ID.
+	Id string
+	// Normalised system ID and canonical name of the game.
+	NameId string
+	// Other IDs the game may have been stored under before, like before it
+	// was hashed or matched to a DAT file. Most specific first.
+	Aliases []string
+}
+
+// normaliseGameName lowercases a name and collapses its whitespace, so
+// different case or spacing in a filename doesn't change its ID.
+func normaliseGameName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// NewGameIdentity returns the identity of a game file. Games with an unknown
+// system use their full path instead of a name, so games with the same
+// filename in different folders don't get merged.
+func NewGameIdentity(systemId string, path string) GameIdentity {
+	if systemId == "" {
+		return NewGameIdentityWith(nil, systemId, path)
+	}
+
+	// without a gamesdb, games are only identified by their filename
+	fl, _ := gamesdb.OpenFileLookup()
+	defer fl.Close()
+
+	return NewGameIdentityWith(fl, systemId, path)
+}
+
+// NewGameIdentityWith returns the identity of a game file, looking it up in
+// an already open gamesdb. A nil lookup treats every file as not indexed.
+func NewGameIdentityWith(fl *gamesdb.FileLookup, systemId string, path string) GameIdentity {
+	filename := utils.RemoveFileExt(filepath.Base(path))
+
+	if systemId == "" {
+		noExt := strings.TrimSuffix(path, filepath.Ext(path))
+		id := "/" + strings.TrimPrefix(normaliseGameName(noExt), "/")
+		return GameIdentity{Id: id, NameId: id}
+	}
+
+	prefix := systemId + "/"
+	fileId := prefix + normaliseGameName(filename)
+
+	ident := GameIdentity{
+		Id:     fileId,
+		NameId: fileId,
+	}
+
+	result, hashes, ok := fl.Lookup(systemId, path)
+	if !ok {
+		return ident
+	}
+
+	if result.Canonical != "" {
+		ident.NameId = prefix + normaliseGameName(result.Canonical)
+		ident.Id = ident.NameId
+		if fileId != ident.NameId {
+			ident.Aliases = append(ident.Aliases, fileId)
+		}
+	}
+
+	if hashes != nil && hashes.SHA1 != "" {
+		ident.Id = prefix + "sha1:" + hashes.SHA1
+		ident.Aliases = append([]string{ident.NameId}, ident.Aliases...)
+	}
+
+	return ident
+}
+
+// cachedIdentity is a game identity and the time of the gamesdb it was
+// looked up in.
+type cachedIdentity struct {
+	ident   GameIdentity
+	modTime time.Time
+}
+
+// gameIdentity returns the identity of a game file. Identities are cached
+// until the gamesdb changes, so it's only opened the first time a game is
+// loaded after each index update. If the gamesdb can't be opened, usually
+// because it's being indexed, the last identity of the game is kept instead
+// of falling back to its filename.
+func (tr *Tracker) gameIdentity(systemId string, path string) GameIdentity {
+	if systemId == "" {
+		return NewGameIdentityWith(nil, systemId, path)
+	}
+
+	key := systemId + ":" + path
+	modTime := gamesdb.DbModTime()
+
+	cached, ok := tr.identities[key]
+	if ok && cached.modTime.Equal(modTime) {
+		return cached.ident
+	}
+
+	fl, err := gamesdb.OpenFileLookup()
+	if err != nil && !modTime.IsZero() {
+		tr.Logger.Warn("error opening gamesdb: %s", err)
+		if ok {
+			return cached.ident
+		}
+		// not cached, so it's looked up again next time
+		return NewGameIdentityWith(nil, systemId, path)
+	}
+	defer fl.Close()
+
+	ident := NewGameIdentityWith(fl, systemId, path)
+	tr.identities[key] = cachedIdentity{ident: ident, modTime: modTime}
+
+	return ident
+}
@@ -0,0 +1,24 @@
+package tracker
+
+import "testing"
+
+func TestNewGameIdentity(t *testing.T) {
+	tests := []struct {
+		system string
+		path   string
+		want   string
+	}{
+		{"SNES", "/media/fat/games/SNES/Super Metroid (USA).sfc", "SNES/super metroid (usa)"},
+		{"SNES", "/media/usb0/games/SNES/Super Metroid (USA).zip/SUPER METROID (USA).SFC", "SNES/super metroid (usa)"},
+		{"SNES", "/media/fat/games/SNES/Super  Metroid  (USA).smc", "SNES/super metroid (usa)"},
+		{"Genesis", "/media/fat/games/Genesis/Super Metroid (USA).sfc", "Genesis/super metroid (usa)"},
+		{"", "/media/fat/games/Unknown/v1.0/Game.bin", "/media/fat/games/unknown/v1.0/game"},
+	}
+
+	for _, tt := range tests {
+		got := NewGameIdentity(tt.system, tt.path)
+		if got.Id != tt.want || got.NameId != tt.want {
+			t.Errorf("NewGameIdentity(%s, %s) = %+v, want %s", tt.system, tt.path, got, tt.want)
+		}
+	}
+}
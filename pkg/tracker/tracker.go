@@ -1,7 +1,6 @@
 package tracker
 
 import (
-	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -26,6 +25,15 @@ const (
 	EventActionMenuNavigation
 )
 
+// Reasons a play session ended.
+const (
+	SessionActive = iota
+	SessionEndedByStop
+	SessionEndedByCoreSwitch
+	SessionEndedByGameSwitch
+	SessionEndedByPowerLoss
+)
+
 const ArcadeSystem = "Arcade"
 
 type EventAction struct {
@@ -58,6 +66,21 @@ type GameTime struct {
 	Time   int
 }
 
+// Session is a continuous period of playing a single game, or a core with
+// no game loaded.
+type Session struct {
+	Id       int64
+	Start    time.Time
+	End      time.Time
+	Duration int // seconds
+	Core     string
+	System   string
+	GameId   string
+	GamePath string
+	GameName string
+	EndedBy  int
+}
+
 type NameMapping struct {
 	CoreName   string
 	System     string
@@ -72,6 +95,12 @@ type Db interface {
 	GetCore(name string) (CoreTime, error)
 	UpdateGame(gt GameTime) error
 	GetGame(id string) (GameTime, error)
+	// RenameGame moves all play times and history of a game to a new ID.
+	RenameGame(oldId string, newId string) error
+	// UpdateArcadeSet saves the setname and parent of an arcade game.
+	UpdateArcadeSet(as ArcadeSet) error
+	AddSession(s Session) (int64, error)
+	UpdateSession(s Session) error
 	NoResults(err error) bool
 }
 
@@ -89,7 +118,9 @@ type Tracker struct {
 	Events           []EventAction
 	CoreTimes        map[string]CoreTime
 	GameTimes        map[string]GameTime
+	ActiveSession    *Session
 	NameMap          []NameMapping
+	identities       map[string]cachedIdentity
 }
 
 func generateNameMap(logger *service.Logger) []NameMapping {
@@ -157,6 +188,7 @@ func NewTracker(logger *service.Logger, cfg *config.UserConfig, db Db) (*Tracker
 		CoreTimes:        map[string]CoreTime{},
 		GameTimes:        map[string]GameTime{},
 		NameMap:          nameMap,
+		identities:       map[string]cachedIdentity{},
 	}, nil
 }
 
@@ -169,7 +201,11 @@ func (tr *Tracker) ReloadNameMap() {
 	tr.NameMap = nameMap
 }
 
+// LookupName returns the name mapping of a core. Cores used by more than one
+// system are matched by the system of the given game. If the game doesn't
+// belong to any of them, cores used by a single system still match it.
 func (tr *Tracker) LookupName(name string, game string) NameMapping {
+	var matches []NameMapping
 	for _, mapping := range tr.NameMap {
 		if len(mapping.CoreName) != len(name) {
 			continue
@@ -179,6 +215,8 @@ func (tr *Tracker) LookupName(name string, game string) NameMapping {
 			continue
 		}
 
+		matches = append(matches, mapping)
+
 		sys, err := games.BestSystemMatch(tr.Config, game)
 		if err != nil {
 			continue
@@ -191,9 +229,20 @@ func (tr *Tracker) LookupName(name string, game string) NameMapping {
 		return mapping
 	}
 
+	if len(matches) == 1 {
+		return matches[0]
+	}
+
 	return NameMapping{}
 }
 
+// gameSystem returns the system ID a game ID starts with. Games with an
+// unknown system have no system.
+func gameSystem(id string) string {
+	system, _, _ := strings.Cut(id, "/")
+	return system
+}
+
 func (tr *Tracker) execHook(bin string, arg string) {
 	if bin == "" {
 		return
@@ -276,6 +325,85 @@ func (tr *Tracker) addEvent(action int, target string) {
 	tr.Logger.Info("%s: %s (%ds)", actionLabel, target, totalTime)
 }
 
+// setSessionGame sets the active game as the game being played in a session.
+func (tr *Tracker) setSessionGame(s *Session) {
+	s.System = tr.ActiveSystem
+	s.GameId = tr.ActiveGame
+	s.GameName = tr.ActiveGameName
+	if gt, ok := tr.GameTimes[tr.ActiveGame]; ok {
+		s.GamePath = gt.Path
+	}
+}
+
+// startSession begins a new play session for the active core, and optionally
+// the active game.
+func (tr *Tracker) startSession(withGame bool) {
+	s := &Session{
+		Start:  time.Now(),
+		End:    time.Now(),
+		Core:   tr.ActiveCore,
+		System: tr.ActiveSystem,
+	}
+
+	if withGame && tr.ActiveGame != "" {
+		tr.setSessionGame(s)
+	}
+
+	tr.ActiveSession = s
+}
+
+func (tr *Tracker) saveSession() {
+	s := tr.ActiveSession
+	if s == nil {
+		return
+	}
+
+	if s.Id == 0 {
+		id, err := tr.Db.AddSession(*s)
+		if err != nil {
+			tr.Logger.Error("error saving session: %s", err)
+			return
+		}
+		s.Id = id
+	} else {
+		err := tr.Db.UpdateSession(*s)
+		if err != nil {
+			tr.Logger.Error("error updating session: %s", err)
+		}
+	}
+}
+
+// endSession saves and clears the active session. Sessions which didn't last
+// a full second are discarded, they're usually a core and game being loaded
+// in an unexpected order.
+func (tr *Tracker) endSession(endedBy int) {
+	s := tr.ActiveSession
+	if s == nil {
+		return
+	}
+
+	s.End = time.Now()
+	s.EndedBy = endedBy
+	if s.Duration > 0 || s.Id != 0 {
+		tr.saveSession()
+		tr.Logger.Info("session ended: %s %s (%ds)", s.Core, s.GameId, s.Duration)
+	}
+
+	tr.ActiveSession = nil
+}
+
+// startGameSession adds the active game to the active session if its core
+// was started just before it, otherwise a new session is started.
+func (tr *Tracker) startGameSession() {
+	s := tr.ActiveSession
+	if s != nil && s.GameId == "" && s.Core == tr.ActiveCore {
+		tr.setSessionGame(s)
+	} else {
+		tr.endSession(SessionEndedByGameSwitch)
+		tr.startSession(true)
+	}
+}
+
 func (tr *Tracker) stopCore() bool {
 	if tr.ActiveCore != "" {
 		if ct, ok := tr.CoreTimes[tr.ActiveCore]; ok && ct.Time > 0 {
@@ -287,10 +415,10 @@ func (tr *Tracker) stopCore() bool {
 
 		tr.addEvent(EventActionCoreStop, tr.ActiveCore)
 
-		if tr.ActiveCore == ArcadeSystem {
-			tr.ActiveGame = ""
-			tr.ActiveGameName = ""
-			tr.addEvent(EventActionGameStop, ArcadeSystem)
+		// arcade games matched by core name have no MRA in ACTIVEGAME to
+		// stop them
+		if tr.ActiveSystem == ArcadeSystem && tr.ActiveGamePath == "" {
+			tr.stopGame()
 		}
 
 		tr.ActiveCore = ""
@@ -328,6 +456,7 @@ func (tr *Tracker) LoadCore() {
 
 	if err != nil {
 		tr.Logger.Error("error reading core name: %s", err)
+		tr.endSession(SessionEndedByStop)
 		tr.stopCore()
 		return
 	}
@@ -340,7 +469,18 @@ func (tr *Tracker) LoadCore() {
 		coreName = ""
 	}
 
+	tr.loadCore(coreName)
+}
+
+// loadCore sets a core as active, or stops the active core if the name is
+// blank.
+func (tr *Tracker) loadCore(coreName string) {
 	if coreName != tr.ActiveCore {
+		if coreName == "" {
+			tr.endSession(SessionEndedByStop)
+		} else {
+			tr.endSession(SessionEndedByCoreSwitch)
+		}
 		tr.stopCore()
 
 		tr.ActiveCore = coreName
@@ -350,15 +490,29 @@ func (tr *Tracker) LoadCore() {
 		}
 
 		result := tr.LookupName(coreName, tr.ActiveGamePath)
+		_, launched := tr.launchedArcadeSet(coreName)
+		if launched {
+			result = NameMapping{
+				CoreName: coreName,
+				System:   ArcadeSystem,
+				Name:     ArcadeSystem,
+			}
+		} else if result == (NameMapping{}) {
+			// arcade cores are named after the setname of the MRA which
+			// launched them
+			result = tr.lookupArcadeName(coreName)
+		}
+
 		if result != (NameMapping{}) {
 			tr.ActiveSystem = result.System
 			tr.ActiveSystemName = result.Name
 
-			if result.System == ArcadeSystem {
-				mister.SetActiveGame(coreName)
-				tr.ActiveGame = coreName
-				tr.ActiveGameName = result.ArcadeName
-				tr.addEvent(EventActionGameStart, coreName)
+			if result.System == ArcadeSystem && !launched {
+				tr.setArcadeGame(ArcadeSet{
+					Id:      ArcadeGameId(coreName),
+					SetName: coreName,
+					Name:    result.ArcadeName,
+				}, "")
 			} else if result.System == "" {
 				tr.ActiveSystem = coreName
 				tr.ActiveSystemName = coreName
@@ -383,6 +537,14 @@ func (tr *Tracker) LoadCore() {
 		}
 
 		tr.addEvent(EventActionCoreStart, coreName)
+
+		// a game left over from the previous core is only kept if it
+		// belongs to this core's system
+		keepGame := tr.ActiveSystem != "" && gameSystem(tr.ActiveGame) == tr.ActiveSystem
+		if !keepGame && tr.ActiveSystem != "" {
+			tr.stopGame()
+		}
+		tr.startSession(keepGame)
 	}
 }
 
@@ -405,6 +567,32 @@ func (tr *Tracker) stopGame() bool {
 	}
 }
 
+// getGameTime loads the stored play time of a game. Any play time stored
+// under one of the game's aliases is added to it, which happens when a game
+// is hashed or matched to a DAT file, or was played while the gamesdb
+// couldn't be read.
+func (tr *Tracker) getGameTime(ident GameIdentity) (GameTime, error) {
+	for _, alias := range ident.Aliases {
+		_, err := tr.Db.GetGame(alias)
+		if tr.Db.NoResults(err) {
+			continue
+		} else if err != nil {
+			return GameTime{}, err
+		}
+
+		err = tr.Db.RenameGame(alias, ident.Id)
+		if err != nil {
+			return GameTime{}, err
+		}
+		tr.Logger.Info("moved game time from %s to %s", alias, ident.Id)
+
+		// it was saved when it stopped, so nothing is lost
+		delete(tr.GameTimes, alias)
+	}
+
+	return tr.Db.GetGame(ident.Id)
+}
+
 // Load the current running game and set it as active.
 func (tr *Tracker) loadGame() {
 	tr.mu.Lock()
@@ -413,13 +601,22 @@ func (tr *Tracker) loadGame() {
 	activeGame, err := mister.GetActiveGame()
 	if err != nil {
 		tr.Logger.Error("error getting active game: %s", err)
-		tr.stopGame()
+		if tr.stopGame() {
+			tr.endSession(SessionEndedByStop)
+		}
 		return
 	} else if activeGame == "" {
-		tr.stopGame()
+		if tr.stopGame() {
+			tr.endSession(SessionEndedByStop)
+		}
 		return
 	}
 
+	tr.loadGameFile(activeGame)
+}
+
+// loadGameFile sets the game at a path from ACTIVEGAME as active.
+func (tr *Tracker) loadGameFile(activeGame string) {
 	path := mister.ResolvePath(activeGame)
 	filename := filepath.Base(path)
 	name := utils.RemoveFileExt(filename)
@@ -429,22 +626,28 @@ func (tr *Tracker) loadGame() {
 		if err != nil {
 			tr.Logger.Error("error reading mgl: %s", err)
 		} else {
-			path = mister.ResolvePath(mgl.File.Path)
+			path = mister.ResolvePath(mgl.GameFile())
 			tr.Logger.Info("mgl path: %s", path)
 		}
 	}
 
+	if filepath.Ext(strings.ToLower(path)) == ".mra" {
+		tr.loadArcadeGame(path)
+		return
+	}
+
 	system, err := games.BestSystemMatch(tr.Config, path)
 	if err != nil {
 		tr.Logger.Error("error finding system for game: %s", err)
 	}
 
 	var folder string
-	if err != nil && len(system.Folder) > 0 {
+	if err == nil && len(system.Folder) > 0 {
 		folder = system.Folder[0]
 	}
 
-	id := fmt.Sprintf("%s/%s", system.Id, filename)
+	ident := tr.gameIdentity(system.Id, path)
+	id := ident.Id
 
 	if id != tr.ActiveGame {
 		tr.stopGame()
@@ -457,18 +660,13 @@ func (tr *Tracker) loadGame() {
 		if result != (NameMapping{}) {
 			tr.ActiveSystem = result.System
 			tr.ActiveSystemName = result.Name
-
-			if result.System == ArcadeSystem {
-				tr.ActiveGame = tr.ActiveCore
-				tr.ActiveGameName = result.ArcadeName
-			}
 		} else {
 			tr.ActiveSystem = ""
 			tr.ActiveSystemName = ""
 		}
 
 		if _, ok := tr.GameTimes[id]; !ok {
-			gt, err := tr.Db.GetGame(id)
+			gt, err := tr.getGameTime(ident)
 			if tr.Db.NoResults(err) {
 				tr.GameTimes[id] = GameTime{
 					Id:     id,
@@ -485,12 +683,14 @@ func (tr *Tracker) loadGame() {
 		}
 
 		tr.addEvent(EventActionGameStart, id)
+		tr.startGameSession()
 	}
 }
 
 func (tr *Tracker) StopAll() {
 	tr.mu.Lock()
 	defer tr.mu.Unlock()
+	tr.endSession(SessionEndedByStop)
 	tr.stopCore()
 	tr.stopGame()
 }
@@ -533,6 +733,15 @@ func (tr *Tracker) tick(saveInterval int) {
 			tr.GameTimes[tr.ActiveGame] = gt
 		}
 	}
+
+	if tr.ActiveSession != nil {
+		tr.ActiveSession.Duration++
+
+		if saveInterval > 0 && tr.ActiveSession.Duration%saveSeconds == 0 {
+			tr.ActiveSession.End = time.Now()
+			tr.saveSession()
+		}
+	}
 }
 
 // StartTicker starts the thread for updating core/game play times.
@@ -0,0 +1,151 @@
+package tracker
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/wizzomafizzo/mrext/pkg/config"
+	"github.com/wizzomafizzo/mrext/pkg/service"
+)
+
+// memoryDb stores play times and sessions in memory.
+type memoryDb struct {
+	cores    map[string]CoreTime
+	games    map[string]GameTime
+	sessions []Session
+}
+
+func newMemoryDb() *memoryDb {
+	return &memoryDb{
+		cores: make(map[string]CoreTime),
+		games: make(map[string]GameTime),
+	}
+}
+
+func (m *memoryDb) FixPowerLoss() (bool, error) { return false, nil }
+
+func (m *memoryDb) AddEvent(EventAction) error { return nil }
+
+func (m *memoryDb) UpdateCore(ct CoreTime) error {
+	m.cores[ct.Name] = ct
+	return nil
+}
+
+func (m *memoryDb) GetCore(name string) (CoreTime, error) {
+	ct, ok := m.cores[name]
+	if !ok {
+		return ct, sql.ErrNoRows
+	}
+	return ct, nil
+}
+
+func (m *memoryDb) UpdateGame(gt GameTime) error {
+	m.games[gt.Id] = gt
+	return nil
+}
+
+func (m *memoryDb) GetGame(id string) (GameTime, error) {
+	gt, ok := m.games[id]
+	if !ok {
+		return gt, sql.ErrNoRows
+	}
+	return gt, nil
+}
+
+func (m *memoryDb) RenameGame(oldId string, newId string) error {
+	gt, ok := m.games[oldId]
+	if !ok {
+		return nil
+	}
+	delete(m.games, oldId)
+
+	if existing, ok := m.games[newId]; ok {
+		existing.Time += gt.Time
+		m.games[newId] = existing
+	} else {
+		gt.Id = newId
+		m.games[newId] = gt
+	}
+
+	return nil
+}
+
+func (m *memoryDb) UpdateArcadeSet(ArcadeSet) error { return nil }
+
+func (m *memoryDb) AddSession(s Session) (int64, error) {
+	m.sessions = append(m.sessions, s)
+	return int64(len(m.sessions)), nil
+}
+
+func (m *memoryDb) UpdateSession(s Session) error {
+	m.sessions[s.Id-1] = s
+	return nil
+}
+
+func (m *memoryDb) NoResults(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
+
+func newTestTracker(t *testing.T, db Db) *Tracker {
+	t.Helper()
+
+	logger := service.NewLogger("tracker_test")
+	tr, err := NewTracker(logger, &config.UserConfig{}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tr
+}
+
+func TestLoadCoreOtherSystem(t *testing.T) {
+	tr := newTestTracker(t, newMemoryDb())
+
+	tr.loadCore("SNES")
+	tr.loadGameFile("/media/fat/games/SNES/Zelda (USA).sfc")
+	if tr.ActiveSession == nil || tr.ActiveSession.GameId != "SNES/zelda (usa)" {
+		t.Fatalf("session = %+v, want SNES game", tr.ActiveSession)
+	}
+
+	// ACTIVEGAME still has the SNES game when the Genesis core starts
+	tr.loadCore("MegaDrive")
+
+	s := tr.ActiveSession
+	if s == nil || s.Core != "MegaDrive" || s.System != "Genesis" || s.GameId != "" {
+		t.Errorf("session = %+v, want Genesis core with no game", s)
+	}
+	if tr.ActiveGame != "" {
+		t.Errorf("active game = %q, want SNES game stopped", tr.ActiveGame)
+	}
+}
+
+func TestGetGameTimeAliases(t *testing.T) {
+	db := newMemoryDb()
+	tr := newTestTracker(t, db)
+
+	ident := GameIdentity{
+		Id:      "SNES/sha1:abc",
+		NameId:  "SNES/zelda (usa)",
+		Aliases: []string{"SNES/zelda (usa)"},
+	}
+
+	// played once while the gamesdb couldn't be read
+	db.games["SNES/sha1:abc"] = GameTime{Id: "SNES/sha1:abc", Time: 100}
+	db.games["SNES/zelda (usa)"] = GameTime{Id: "SNES/zelda (usa)", Time: 30}
+	tr.GameTimes["SNES/zelda (usa)"] = db.games["SNES/zelda (usa)"]
+
+	gt, err := tr.getGameTime(ident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gt.Id != "SNES/sha1:abc" || gt.Time != 130 {
+		t.Errorf("getGameTime() = %+v, want alias time added", gt)
+	}
+	if _, ok := db.games["SNES/zelda (usa)"]; ok {
+		t.Errorf("alias row was not removed")
+	}
+	if _, ok := tr.GameTimes["SNES/zelda (usa)"]; ok {
+		t.Errorf("alias was not removed from loaded game times")
+	}
+}
@@ -297,3 +297,10 @@ func (idx *Index) Total() int {
 func (idx *Index) Systems() []string {
 	return utils.SortedMapKeys(idx.files)
 }
+
+// SystemFiles returns every indexed file for a system.
+func (idx *Index) SystemFiles(system string) []SearchResult {
+	return idx.searchSystemByNameGeneric(func(string, string) bool {
+		return true
+	}, system, "")
+}
@@ -142,6 +142,11 @@ func RandomElem[T any](xs []T) (T, error) {
 	}
 }
 
+// RandomInt returns a random number from 0 up to, but not including, n.
+func RandomInt(n int) int {
+	return r.Intn(n)
+}
+
 // MapKeys returns a list of all keys in a map.
 func MapKeys[K comparable, V any](m map[K]V) []K {
 	keys := make([]K, len(m))